@@ -1,18 +1,40 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/diwise/api-temperature/internal/pkg/application"
+	"github.com/diwise/api-temperature/internal/pkg/application/alerting"
+	"github.com/diwise/api-temperature/internal/pkg/application/anomaly"
+	"github.com/diwise/api-temperature/internal/pkg/application/querycache"
+	"github.com/diwise/api-temperature/internal/pkg/application/validation"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/auth"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/deviceregistry"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/messaging/broker"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/messaging/deadletter"
 	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/database"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/tracing"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	"github.com/diwise/api-temperature/pkg/infrastructure/messaging/commands"
+	diwisetelemetry "github.com/diwise/api-temperature/pkg/infrastructure/messaging/telemetry"
 
 	"github.com/diwise/messaging-golang/pkg/messaging"
 	"github.com/diwise/messaging-golang/pkg/messaging/telemetry"
 )
 
+//shutdownTimeout is how long we wait for messages and requests to be handled on shutdown,
+//which needs to be shorter than the termination grace period of the pod
+const shutdownTimeout = 20 * time.Second
+
 func main() {
 
 	serviceName := "api-temperature"
@@ -20,33 +42,258 @@ func main() {
 	logger := log.With().Str("service", strings.ToLower(serviceName)).Logger()
 	logger.Info().Msg("starting up ...")
 
-	config := messaging.LoadConfiguration(serviceName, logger)
-	messenger, _ := messaging.Initialize(config)
+	// The configuration is read from an optional file and the environment ...
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Fatal().Err(err).Str("step", "configuration").Msg("startup failed")
+	}
+
+	// Spans are exported via OTLP, or to stdout for local testing, as configured by the environment ...
+	shutdownTracing, err := tracing.Init(context.Background(), serviceName, logger)
+	if err != nil {
+		logger.Fatal().Err(err).Str("step", "tracing").Msg("startup failed")
+	}
+
+	// Dependencies that are starting up at the same time as us get some time to become available,
+	// but if they never do we exit with an error rather than hang or limp along without them
+	messagingConfig := messaging.LoadConfiguration(serviceName, logger)
 
-	defer messenger.Close()
+	// messaging.Initialize retries forever, so we make sure that the broker can be reached first
+	if err := waitForBroker(messagingConfig, cfg.Messaging, logger); err != nil {
+		logger.Fatal().Err(err).Str("step", "messaging").Str("host", messagingConfig.Host).Msg("startup failed")
+	}
+
+	messenger, err := messaging.Initialize(messagingConfig)
+	if err != nil {
+		logger.Fatal().Err(err).Str("step", "messaging").Str("host", messagingConfig.Host).Msg("startup failed")
+	}
 
 	// Make sure that we have a proper connection to the database ...
-	db, _ := database.NewDatabaseConnection(database.NewPostgreSQLConnector(logger))
+	db, err := database.NewDatabaseConnection(database.NewPostgreSQLConnector(logger, cfg.Database))
+	if err != nil {
+		logger.Fatal().Err(err).Str("step", "database").Msg("startup failed")
+	}
 
-	// ... before we start listening for temperature telemetry
-	messenger.RegisterTopicMessageHandler(
-		(&telemetry.Temperature{}).TopicName(),
-		application.NewTemperatureReceiver(db),
-	)
-	messenger.RegisterTopicMessageHandler(
-		(&telemetry.WaterTemperature{}).TopicName(),
-		application.NewWaterTempReceiver(db),
+	// ... with a schema that we are able to work with
+	if err := db.MigrationStatus(); err != nil {
+		logger.Fatal().Err(err).Str("step", "migrations").Msg("startup failed")
+	}
+
+	// Recent query results are cached, and dropped as soon as readings that they could contain are stored
+	queryCache := querycache.New(cfg.QueryCache)
+	db = querycache.InvalidatingDatastore(db, queryCache)
+
+	// Messages that we fail to handle are retried and then routed to a dead letter exchange ...
+	deadletters, err := createDeadLetterStore(messagingConfig, cfg.Messaging.Port, logger)
+	if err != nil {
+		logger.Fatal().Err(err).Str("step", "deadletters").Msg("startup failed")
+	}
+
+	failures := application.NewFailureHandler(application.DefaultRetryPolicy(), deadletters, logger)
+
+	// ... and keep track of the messages that are being handled, so that they can finish on shutdown
+	drainer := application.NewDrainer(deadletters)
+
+	// Alert rules are kept in the database and can be managed via the admin API ...
+	alerts, err := alerting.NewEngine(db)
+	if err != nil {
+		logger.Fatal().Err(err).Str("step", "alerting").Msg("startup failed")
+	}
+
+	// Incoming readings are checked for plausibility before they are stored, and
+	// analysed for signs of sensor malfunction after they have been stored ...
+	ingester := application.NewIngester(
+		db,
+		validation.NewValidator(cfg.ValidationConfig()),
+		anomaly.NewDetector(anomaly.DefaultConfig()),
+		alerts,
+		messenger,
+		cfg.Precision,
 	)
 
+	// ... which works without history as well, so a failure to seed the detector is not fatal
+	anomalySeeding := "ok"
+	if err := ingester.SeedAnomalyDetector(48*time.Hour, logger); err != nil {
+		logger.Warn().Err(err).Str("step", "anomalies").Msg("failed to seed anomaly detector")
+		anomalySeeding = "failed: " + err.Error()
+	}
+
+	// ... before we start listening for temperature telemetry of every kind
+	temperatureTopics := []struct {
+		topic string
+		kind  string
+	}{
+		{(&telemetry.Temperature{}).TopicName(), validation.KindAir},
+		{(&telemetry.WaterTemperature{}).TopicName(), validation.KindWater},
+		{(&diwisetelemetry.SoilTemperature{}).TopicName(), validation.KindSoil},
+		{(&diwisetelemetry.SurfaceTemperature{}).TopicName(), validation.KindSurface},
+		{(&diwisetelemetry.IndoorTemperature{}).TopicName(), validation.KindIndoor},
+	}
+
+	for _, t := range temperatureTopics {
+		messenger.RegisterTopicMessageHandler(
+			t.topic,
+			drainer.Topic(failures.Wrap(t.topic, application.NewTemperatureReceiver(ingester, t.kind))),
+		)
+	}
+
+	temperatureCommands := []struct {
+		contentType string
+		kind        string
+	}{
+		{commands.StoreTemperatureUpdateType, validation.KindAir},
+		{commands.StoreWaterTemperatureUpdateType, validation.KindWater},
+		{commands.StoreSoilTemperatureUpdateType, validation.KindSoil},
+		{commands.StoreSurfaceTemperatureUpdateType, validation.KindSurface},
+		{commands.StoreIndoorTemperatureUpdateType, validation.KindIndoor},
+	}
+
+	for _, c := range temperatureCommands {
+		messenger.RegisterCommandHandler(
+			c.contentType,
+			drainer.Command(application.NewStoreTemperatureCommandHandler(ingester, messenger, c.kind)),
+		)
+	}
+
 	messenger.RegisterCommandHandler(
-		commands.StoreTemperatureUpdateType,
-		application.NewStoreTemperatureCommandHandler(db, messenger),
+		commands.ImportTemperatureSeriesType,
+		drainer.Command(application.NewImportTemperatureSeriesCommandHandler(ingester, messenger)),
 	)
 
-	messenger.RegisterCommandHandler(
-		commands.StoreWaterTemperatureUpdateType,
-		application.NewStoreWaterTemperatureCommandHandler(db, messenger),
+	// The readiness probe checks that the channel of the dead letter store to the broker is still
+	// open, unless messaging has been disabled. The messenger exits the service if its connection
+	// is closed, so it does not need to be checked as well.
+	var brokerChannel application.BrokerChannel
+	if messagingConfig.Host != "" {
+		brokerChannel = deadletters
+	}
+
+	// Old readings are deleted in the background, if a retention period has been configured
+	retentionCtx, stopRetention := context.WithCancel(context.Background())
+	application.StartRetention(retentionCtx, db, cfg.Retention, logger)
+
+	// Requests are only authenticated if auth has been enabled, to keep local testing simple
+	var authenticator auth.Authenticator
+	if cfg.Auth.Enabled {
+		authenticator, err = auth.NewAuthenticator(cfg.Auth)
+		if err != nil {
+			logger.Fatal().Err(err).Str("step", "auth").Msg("startup failed")
+		}
+	}
+
+	// Readings are enriched with the names, owners, sites and models of their devices, if the
+	// device registry has been enabled. The registry is asked again at every refresh interval.
+	devicesCtx, stopDevices := context.WithCancel(context.Background())
+	var devices *deviceregistry.Client
+	if cfg.DeviceRegistry.Enabled {
+		devices = deviceregistry.NewClient(cfg.DeviceRegistry)
+		devices.Start(devicesCtx, logger)
+	}
+
+	server := application.CreateRouterAndStartServing(
+		logger, cfg, authenticator, db, queryCache, devices, failures, alerts,
+		application.DefaultDependencies(db, brokerChannel),
 	)
 
-	application.CreateRouterAndStartServing(logger, db)
+	topicNames := []string{}
+	for _, t := range temperatureTopics {
+		topicNames = append(topicNames, t.topic)
+	}
+
+	messagingHost := messagingConfig.Host
+	if messagingHost == "" {
+		messagingHost = "disabled"
+	}
+
+	logger.Info().
+		Dict("database", zerolog.Dict().
+			Str("host", cfg.Database.Host).
+			Str("name", cfg.Database.Name)).
+		Dict("messaging", zerolog.Dict().
+			Str("host", messagingHost).
+			Strs("topics", topicNames).
+			Int("commands", len(temperatureCommands)+1)).
+		Str("anomalySeeding", anomalySeeding).
+		Dur("retention", cfg.Retention.MaxAge).
+		Strs("corsAllowedOrigins", cfg.CORS.AllowedOrigins).
+		Dict("auth", zerolog.Dict().
+			Bool("enabled", cfg.Auth.Enabled).
+			Str("mode", cfg.Auth.Mode).
+			Bool("allowAnonymousRead", cfg.Auth.AllowAnonymousRead)).
+		Bool("rateLimit", cfg.RateLimit.Enabled).
+		Bool("queryCache", cfg.QueryCache.Enabled).
+		Bool("deviceRegistry", cfg.DeviceRegistry.Enabled).
+		Str("listenAddress", server.Addr).
+		Msg("startup complete")
+
+	// Serve until we are asked to stop, by Kubernetes or by someone pressing Ctrl+C ...
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	sig := <-stop
+
+	logger.Info().Str("signal", sig.String()).Msg("shutting down ...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// ... refuse the messages that arrive from now on, and let the ones that are being handled
+	// finish or go to the dead letter store. The messenger can not stop consuming without closing
+	// its connection, so it is closed once the handlers are done publishing events and replies.
+	drainer.Stop()
+	failures.Stop()
+
+	if err := drainer.Drain(ctx); err != nil {
+		logger.Error().Err(err).Msg("gave up waiting for messages to be handled")
+	}
+
+	messenger.Close()
+	drainer.Release()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error().Err(err).Msg("failed to shut down the http server gracefully")
+	}
+
+	stopRetention()
+	stopDevices()
+
+	if err := deadletters.Close(); err != nil {
+		logger.Error().Err(err).Msg("failed to close the dead letter store")
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error().Err(err).Msg("failed to flush traces")
+	}
+
+	if err := db.Close(); err != nil {
+		logger.Error().Err(err).Msg("failed to close the database connection pool")
+	}
+
+	logger.Info().Msg("shut down complete")
+}
+
+func createDeadLetterStore(messagingConfig messaging.Config, port int, logger zerolog.Logger) (deadletter.Store, error) {
+	if messagingConfig.Host == "" {
+		logger.Info().Msg("messaging disabled, keeping dead letters in memory")
+		return deadletter.NewInMemoryStore(), nil
+	}
+
+	return deadletter.NewAMQPStore(messagingConfig, port, logger)
+}
+
+//waitForBroker dials the message broker until it answers or the policy gives up
+func waitForBroker(messagingConfig messaging.Config, cfg config.Messaging, logger zerolog.Logger) error {
+	if messagingConfig.Host == "" {
+		return nil
+	}
+
+	log := logger.With().Str("host", messagingConfig.Host).Logger()
+	connectionString := broker.ConnectionString(messagingConfig, cfg.Port)
+
+	return cfg.Connect.Do(context.Background(), log, "connect to the message broker", func() error {
+		conn, err := amqp.Dial(connectionString)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
 }
@@ -48,25 +48,41 @@ type ComplexityRoot struct {
 		ID func(childComplexity int) int
 	}
 
+	DeviceMetadata struct {
+		Model func(childComplexity int) int
+		Name  func(childComplexity int) int
+		Owner func(childComplexity int) int
+		Site  func(childComplexity int) int
+	}
+
 	Entity struct {
 		FindDeviceByID func(childComplexity int, id string) int
 	}
 
 	Origin struct {
-		Device func(childComplexity int) int
-		Pos    func(childComplexity int) int
+		Device         func(childComplexity int) int
+		DeviceMetadata func(childComplexity int) int
+		Pos            func(childComplexity int) int
+	}
+
+	Quality struct {
+		Reason func(childComplexity int) int
+		Status func(childComplexity int) int
 	}
 
 	Query struct {
-		Temperatures       func(childComplexity int) int
+		Temperatures       func(childComplexity int, quality []QualityStatus, minDepth *float64, maxDepth *float64, calibration *CalibrationSelection, unit *TemperatureUnit) int
 		__resolve__service func(childComplexity int) int
 		__resolve_entities func(childComplexity int, representations []map[string]interface{}) int
 	}
 
 	Temperature struct {
-		From func(childComplexity int) int
-		Temp func(childComplexity int) int
-		When func(childComplexity int) int
+		Depth    func(childComplexity int) int
+		From     func(childComplexity int) int
+		Quality  func(childComplexity int) int
+		Temp     func(childComplexity int) int
+		UnitCode func(childComplexity int) int
+		When     func(childComplexity int) int
 	}
 
 	WGS84Position struct {
@@ -83,7 +99,7 @@ type EntityResolver interface {
 	FindDeviceByID(ctx context.Context, id string) (*Device, error)
 }
 type QueryResolver interface {
-	Temperatures(ctx context.Context) ([]*Temperature, error)
+	Temperatures(ctx context.Context, quality []QualityStatus, minDepth *float64, maxDepth *float64, calibration *CalibrationSelection, unit *TemperatureUnit) ([]*Temperature, error)
 }
 
 type executableSchema struct {
@@ -108,6 +124,34 @@ func (e *executableSchema) Complexity(typeName, field string, childComplexity in
 
 		return e.complexity.Device.ID(childComplexity), true
 
+	case "DeviceMetadata.model":
+		if e.complexity.DeviceMetadata.Model == nil {
+			break
+		}
+
+		return e.complexity.DeviceMetadata.Model(childComplexity), true
+
+	case "DeviceMetadata.name":
+		if e.complexity.DeviceMetadata.Name == nil {
+			break
+		}
+
+		return e.complexity.DeviceMetadata.Name(childComplexity), true
+
+	case "DeviceMetadata.owner":
+		if e.complexity.DeviceMetadata.Owner == nil {
+			break
+		}
+
+		return e.complexity.DeviceMetadata.Owner(childComplexity), true
+
+	case "DeviceMetadata.site":
+		if e.complexity.DeviceMetadata.Site == nil {
+			break
+		}
+
+		return e.complexity.DeviceMetadata.Site(childComplexity), true
+
 	case "Entity.findDeviceByID":
 		if e.complexity.Entity.FindDeviceByID == nil {
 			break
@@ -127,6 +171,13 @@ func (e *executableSchema) Complexity(typeName, field string, childComplexity in
 
 		return e.complexity.Origin.Device(childComplexity), true
 
+	case "Origin.deviceMetadata":
+		if e.complexity.Origin.DeviceMetadata == nil {
+			break
+		}
+
+		return e.complexity.Origin.DeviceMetadata(childComplexity), true
+
 	case "Origin.pos":
 		if e.complexity.Origin.Pos == nil {
 			break
@@ -134,12 +185,31 @@ func (e *executableSchema) Complexity(typeName, field string, childComplexity in
 
 		return e.complexity.Origin.Pos(childComplexity), true
 
+	case "Quality.reason":
+		if e.complexity.Quality.Reason == nil {
+			break
+		}
+
+		return e.complexity.Quality.Reason(childComplexity), true
+
+	case "Quality.status":
+		if e.complexity.Quality.Status == nil {
+			break
+		}
+
+		return e.complexity.Quality.Status(childComplexity), true
+
 	case "Query.temperatures":
 		if e.complexity.Query.Temperatures == nil {
 			break
 		}
 
-		return e.complexity.Query.Temperatures(childComplexity), true
+		args, err := ec.field_Query_temperatures_args(context.TODO(), rawArgs)
+		if err != nil {
+			return 0, false
+		}
+
+		return e.complexity.Query.Temperatures(childComplexity, args["quality"].([]QualityStatus), args["minDepth"].(*float64), args["maxDepth"].(*float64), args["calibration"].(*CalibrationSelection), args["unit"].(*TemperatureUnit)), true
 
 	case "Query._service":
 		if e.complexity.Query.__resolve__service == nil {
@@ -160,6 +230,13 @@ func (e *executableSchema) Complexity(typeName, field string, childComplexity in
 
 		return e.complexity.Query.__resolve_entities(childComplexity, args["representations"].([]map[string]interface{})), true
 
+	case "Temperature.depth":
+		if e.complexity.Temperature.Depth == nil {
+			break
+		}
+
+		return e.complexity.Temperature.Depth(childComplexity), true
+
 	case "Temperature.from":
 		if e.complexity.Temperature.From == nil {
 			break
@@ -167,6 +244,13 @@ func (e *executableSchema) Complexity(typeName, field string, childComplexity in
 
 		return e.complexity.Temperature.From(childComplexity), true
 
+	case "Temperature.quality":
+		if e.complexity.Temperature.Quality == nil {
+			break
+		}
+
+		return e.complexity.Temperature.Quality(childComplexity), true
+
 	case "Temperature.temp":
 		if e.complexity.Temperature.Temp == nil {
 			break
@@ -174,6 +258,13 @@ func (e *executableSchema) Complexity(typeName, field string, childComplexity in
 
 		return e.complexity.Temperature.Temp(childComplexity), true
 
+	case "Temperature.unitCode":
+		if e.complexity.Temperature.UnitCode == nil {
+			break
+		}
+
+		return e.complexity.Temperature.UnitCode(childComplexity), true
+
 	case "Temperature.when":
 		if e.complexity.Temperature.When == nil {
 			break
@@ -252,11 +343,18 @@ func (ec *executionContext) introspectType(name string) (*introspection.Type, er
 }
 
 var sources = []*ast.Source{
-	&ast.Source{Name: "api/graphql-spec/schema.graphql", Input: `
+	{Name: "api/graphql-spec/schema.graphql", Input: `
 extend type Device @key(fields: "id") {
   id: ID! @external
 }
 
+type DeviceMetadata {
+  name: String
+  owner: String
+  site: String
+  model: String
+}
+
 type WGS84Position {
   lon: Float!
   lat: Float!
@@ -265,6 +363,7 @@ type WGS84Position {
 type Origin {
   device: Device
   pos: WGS84Position
+  deviceMetadata: DeviceMetadata
 }
 
 scalar DateTime
@@ -274,17 +373,44 @@ interface Telemetry {
   when: DateTime!
 }
 
+enum QualityStatus {
+  RAW
+  GOOD
+  SUSPECT
+  BAD
+  MANUALLY_CORRECTED
+}
+
+enum CalibrationSelection {
+  CORRECTED
+  RAW
+}
+
+enum TemperatureUnit {
+  CEL
+  FAH
+  KEL
+}
+
+type Quality {
+  status: QualityStatus!
+  reason: String
+}
+
 type Temperature implements Telemetry {
   from: Origin!
   when: DateTime!
   temp: Float!
+  depth: Float!
+  unitCode: TemperatureUnit!
+  quality: Quality!
 }
 
 type Query @extends {
-  temperatures: [Temperature]!
+  temperatures(quality: [QualityStatus!], minDepth: Float, maxDepth: Float, calibration: CalibrationSelection = CORRECTED, unit: TemperatureUnit = CEL): [Temperature]!
 }
 `, BuiltIn: false},
-	&ast.Source{Name: "federation/directives.graphql", Input: `
+	{Name: "federation/directives.graphql", Input: `
 scalar _Any
 scalar _FieldSet
 
@@ -294,7 +420,7 @@ directive @provides(fields: _FieldSet!) on FIELD_DEFINITION
 directive @key(fields: _FieldSet!) on OBJECT | INTERFACE
 directive @extends on OBJECT
 `, BuiltIn: true},
-	&ast.Source{Name: "federation/entity.graphql", Input: `
+	{Name: "federation/entity.graphql", Input: `
 # a union of all types that use the @key directive
 union _Entity = Device
 
@@ -325,6 +451,7 @@ func (ec *executionContext) field_Entity_findDeviceByID_args(ctx context.Context
 	args := map[string]interface{}{}
 	var arg0 string
 	if tmp, ok := rawArgs["id"]; ok {
+		ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("id"))
 		arg0, err = ec.unmarshalNID2string(ctx, tmp)
 		if err != nil {
 			return nil, err
@@ -339,6 +466,7 @@ func (ec *executionContext) field_Query___type_args(ctx context.Context, rawArgs
 	args := map[string]interface{}{}
 	var arg0 string
 	if tmp, ok := rawArgs["name"]; ok {
+		ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("name"))
 		arg0, err = ec.unmarshalNString2string(ctx, tmp)
 		if err != nil {
 			return nil, err
@@ -353,6 +481,7 @@ func (ec *executionContext) field_Query__entities_args(ctx context.Context, rawA
 	args := map[string]interface{}{}
 	var arg0 []map[string]interface{}
 	if tmp, ok := rawArgs["representations"]; ok {
+		ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("representations"))
 		arg0, err = ec.unmarshalN_Any2ᚕmapᚄ(ctx, tmp)
 		if err != nil {
 			return nil, err
@@ -362,11 +491,63 @@ func (ec *executionContext) field_Query__entities_args(ctx context.Context, rawA
 	return args, nil
 }
 
+func (ec *executionContext) field_Query_temperatures_args(ctx context.Context, rawArgs map[string]interface{}) (map[string]interface{}, error) {
+	var err error
+	args := map[string]interface{}{}
+	var arg0 []QualityStatus
+	if tmp, ok := rawArgs["quality"]; ok {
+		ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("quality"))
+		arg0, err = ec.unmarshalOQualityStatus2ᚕgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐQualityStatusᚄ(ctx, tmp)
+		if err != nil {
+			return nil, err
+		}
+	}
+	args["quality"] = arg0
+	var arg1 *float64
+	if tmp, ok := rawArgs["minDepth"]; ok {
+		ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("minDepth"))
+		arg1, err = ec.unmarshalOFloat2ᚖfloat64(ctx, tmp)
+		if err != nil {
+			return nil, err
+		}
+	}
+	args["minDepth"] = arg1
+	var arg2 *float64
+	if tmp, ok := rawArgs["maxDepth"]; ok {
+		ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("maxDepth"))
+		arg2, err = ec.unmarshalOFloat2ᚖfloat64(ctx, tmp)
+		if err != nil {
+			return nil, err
+		}
+	}
+	args["maxDepth"] = arg2
+	var arg3 *CalibrationSelection
+	if tmp, ok := rawArgs["calibration"]; ok {
+		ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("calibration"))
+		arg3, err = ec.unmarshalOCalibrationSelection2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐCalibrationSelection(ctx, tmp)
+		if err != nil {
+			return nil, err
+		}
+	}
+	args["calibration"] = arg3
+	var arg4 *TemperatureUnit
+	if tmp, ok := rawArgs["unit"]; ok {
+		ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("unit"))
+		arg4, err = ec.unmarshalOTemperatureUnit2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐTemperatureUnit(ctx, tmp)
+		if err != nil {
+			return nil, err
+		}
+	}
+	args["unit"] = arg4
+	return args, nil
+}
+
 func (ec *executionContext) field___Type_enumValues_args(ctx context.Context, rawArgs map[string]interface{}) (map[string]interface{}, error) {
 	var err error
 	args := map[string]interface{}{}
 	var arg0 bool
 	if tmp, ok := rawArgs["includeDeprecated"]; ok {
+		ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("includeDeprecated"))
 		arg0, err = ec.unmarshalOBoolean2bool(ctx, tmp)
 		if err != nil {
 			return nil, err
@@ -381,6 +562,7 @@ func (ec *executionContext) field___Type_fields_args(ctx context.Context, rawArg
 	args := map[string]interface{}{}
 	var arg0 bool
 	if tmp, ok := rawArgs["includeDeprecated"]; ok {
+		ctx := graphql.WithPathContext(ctx, graphql.NewPathWithField("includeDeprecated"))
 		arg0, err = ec.unmarshalOBoolean2bool(ctx, tmp)
 		if err != nil {
 			return nil, err
@@ -406,10 +588,11 @@ func (ec *executionContext) _Device_id(ctx context.Context, field graphql.Collec
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "Device",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "Device",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -432,6 +615,134 @@ func (ec *executionContext) _Device_id(ctx context.Context, field graphql.Collec
 	return ec.marshalNID2string(ctx, field.Selections, res)
 }
 
+func (ec *executionContext) _DeviceMetadata_name(ctx context.Context, field graphql.CollectedField, obj *DeviceMetadata) (ret graphql.Marshaler) {
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	fc := &graphql.FieldContext{
+		Object:     "DeviceMetadata",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
+	}
+
+	ctx = graphql.WithFieldContext(ctx, fc)
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.Name, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		return graphql.Null
+	}
+	res := resTmp.(*string)
+	fc.Result = res
+	return ec.marshalOString2ᚖstring(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) _DeviceMetadata_owner(ctx context.Context, field graphql.CollectedField, obj *DeviceMetadata) (ret graphql.Marshaler) {
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	fc := &graphql.FieldContext{
+		Object:     "DeviceMetadata",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
+	}
+
+	ctx = graphql.WithFieldContext(ctx, fc)
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.Owner, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		return graphql.Null
+	}
+	res := resTmp.(*string)
+	fc.Result = res
+	return ec.marshalOString2ᚖstring(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) _DeviceMetadata_site(ctx context.Context, field graphql.CollectedField, obj *DeviceMetadata) (ret graphql.Marshaler) {
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	fc := &graphql.FieldContext{
+		Object:     "DeviceMetadata",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
+	}
+
+	ctx = graphql.WithFieldContext(ctx, fc)
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.Site, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		return graphql.Null
+	}
+	res := resTmp.(*string)
+	fc.Result = res
+	return ec.marshalOString2ᚖstring(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) _DeviceMetadata_model(ctx context.Context, field graphql.CollectedField, obj *DeviceMetadata) (ret graphql.Marshaler) {
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	fc := &graphql.FieldContext{
+		Object:     "DeviceMetadata",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
+	}
+
+	ctx = graphql.WithFieldContext(ctx, fc)
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.Model, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		return graphql.Null
+	}
+	res := resTmp.(*string)
+	fc.Result = res
+	return ec.marshalOString2ᚖstring(ctx, field.Selections, res)
+}
+
 func (ec *executionContext) _Entity_findDeviceByID(ctx context.Context, field graphql.CollectedField) (ret graphql.Marshaler) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -440,10 +751,11 @@ func (ec *executionContext) _Entity_findDeviceByID(ctx context.Context, field gr
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "Entity",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "Entity",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: true,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -470,7 +782,7 @@ func (ec *executionContext) _Entity_findDeviceByID(ctx context.Context, field gr
 	}
 	res := resTmp.(*Device)
 	fc.Result = res
-	return ec.marshalNDevice2ᚖgithubᚗcomᚋiotᚑforᚑtillgenglighetᚋapiᚑtemperatureᚋinternalᚋpkgᚋgraphqlᚐDevice(ctx, field.Selections, res)
+	return ec.marshalNDevice2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐDevice(ctx, field.Selections, res)
 }
 
 func (ec *executionContext) _Origin_device(ctx context.Context, field graphql.CollectedField, obj *Origin) (ret graphql.Marshaler) {
@@ -481,10 +793,11 @@ func (ec *executionContext) _Origin_device(ctx context.Context, field graphql.Co
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "Origin",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "Origin",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -501,7 +814,7 @@ func (ec *executionContext) _Origin_device(ctx context.Context, field graphql.Co
 	}
 	res := resTmp.(*Device)
 	fc.Result = res
-	return ec.marshalODevice2ᚖgithubᚗcomᚋiotᚑforᚑtillgenglighetᚋapiᚑtemperatureᚋinternalᚋpkgᚋgraphqlᚐDevice(ctx, field.Selections, res)
+	return ec.marshalODevice2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐDevice(ctx, field.Selections, res)
 }
 
 func (ec *executionContext) _Origin_pos(ctx context.Context, field graphql.CollectedField, obj *Origin) (ret graphql.Marshaler) {
@@ -512,10 +825,11 @@ func (ec *executionContext) _Origin_pos(ctx context.Context, field graphql.Colle
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "Origin",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "Origin",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -532,7 +846,106 @@ func (ec *executionContext) _Origin_pos(ctx context.Context, field graphql.Colle
 	}
 	res := resTmp.(*WGS84Position)
 	fc.Result = res
-	return ec.marshalOWGS84Position2ᚖgithubᚗcomᚋiotᚑforᚑtillgenglighetᚋapiᚑtemperatureᚋinternalᚋpkgᚋgraphqlᚐWGS84Position(ctx, field.Selections, res)
+	return ec.marshalOWGS84Position2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐWGS84Position(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) _Origin_deviceMetadata(ctx context.Context, field graphql.CollectedField, obj *Origin) (ret graphql.Marshaler) {
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	fc := &graphql.FieldContext{
+		Object:     "Origin",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
+	}
+
+	ctx = graphql.WithFieldContext(ctx, fc)
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.DeviceMetadata, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		return graphql.Null
+	}
+	res := resTmp.(*DeviceMetadata)
+	fc.Result = res
+	return ec.marshalODeviceMetadata2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐDeviceMetadata(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) _Quality_status(ctx context.Context, field graphql.CollectedField, obj *Quality) (ret graphql.Marshaler) {
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	fc := &graphql.FieldContext{
+		Object:     "Quality",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
+	}
+
+	ctx = graphql.WithFieldContext(ctx, fc)
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.Status, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		if !graphql.HasFieldError(ctx, fc) {
+			ec.Errorf(ctx, "must not be null")
+		}
+		return graphql.Null
+	}
+	res := resTmp.(QualityStatus)
+	fc.Result = res
+	return ec.marshalNQualityStatus2githubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐQualityStatus(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) _Quality_reason(ctx context.Context, field graphql.CollectedField, obj *Quality) (ret graphql.Marshaler) {
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	fc := &graphql.FieldContext{
+		Object:     "Quality",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
+	}
+
+	ctx = graphql.WithFieldContext(ctx, fc)
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.Reason, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		return graphql.Null
+	}
+	res := resTmp.(*string)
+	fc.Result = res
+	return ec.marshalOString2ᚖstring(ctx, field.Selections, res)
 }
 
 func (ec *executionContext) _Query_temperatures(ctx context.Context, field graphql.CollectedField) (ret graphql.Marshaler) {
@@ -543,16 +956,24 @@ func (ec *executionContext) _Query_temperatures(ctx context.Context, field graph
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "Query",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "Query",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: true,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
+	rawArgs := field.ArgumentMap(ec.Variables)
+	args, err := ec.field_Query_temperatures_args(ctx, rawArgs)
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	fc.Args = args
 	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
 		ctx = rctx // use context from middleware stack in children
-		return ec.resolvers.Query().Temperatures(rctx)
+		return ec.resolvers.Query().Temperatures(rctx, args["quality"].([]QualityStatus), args["minDepth"].(*float64), args["maxDepth"].(*float64), args["calibration"].(*CalibrationSelection), args["unit"].(*TemperatureUnit))
 	})
 	if err != nil {
 		ec.Error(ctx, err)
@@ -566,7 +987,7 @@ func (ec *executionContext) _Query_temperatures(ctx context.Context, field graph
 	}
 	res := resTmp.([]*Temperature)
 	fc.Result = res
-	return ec.marshalNTemperature2ᚕᚖgithubᚗcomᚋiotᚑforᚑtillgenglighetᚋapiᚑtemperatureᚋinternalᚋpkgᚋgraphqlᚐTemperature(ctx, field.Selections, res)
+	return ec.marshalNTemperature2ᚕᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐTemperature(ctx, field.Selections, res)
 }
 
 func (ec *executionContext) _Query__entities(ctx context.Context, field graphql.CollectedField) (ret graphql.Marshaler) {
@@ -577,10 +998,11 @@ func (ec *executionContext) _Query__entities(ctx context.Context, field graphql.
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "Query",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "Query",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -618,10 +1040,11 @@ func (ec *executionContext) _Query__service(ctx context.Context, field graphql.C
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "Query",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "Query",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -652,10 +1075,11 @@ func (ec *executionContext) _Query___type(ctx context.Context, field graphql.Col
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "Query",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "Query",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -690,10 +1114,11 @@ func (ec *executionContext) _Query___schema(ctx context.Context, field graphql.C
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "Query",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "Query",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -721,10 +1146,11 @@ func (ec *executionContext) _Temperature_from(ctx context.Context, field graphql
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "Temperature",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "Temperature",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -744,7 +1170,7 @@ func (ec *executionContext) _Temperature_from(ctx context.Context, field graphql
 	}
 	res := resTmp.(*Origin)
 	fc.Result = res
-	return ec.marshalNOrigin2ᚖgithubᚗcomᚋiotᚑforᚑtillgenglighetᚋapiᚑtemperatureᚋinternalᚋpkgᚋgraphqlᚐOrigin(ctx, field.Selections, res)
+	return ec.marshalNOrigin2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐOrigin(ctx, field.Selections, res)
 }
 
 func (ec *executionContext) _Temperature_when(ctx context.Context, field graphql.CollectedField, obj *Temperature) (ret graphql.Marshaler) {
@@ -755,10 +1181,11 @@ func (ec *executionContext) _Temperature_when(ctx context.Context, field graphql
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "Temperature",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "Temperature",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -789,10 +1216,11 @@ func (ec *executionContext) _Temperature_temp(ctx context.Context, field graphql
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "Temperature",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "Temperature",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -815,6 +1243,111 @@ func (ec *executionContext) _Temperature_temp(ctx context.Context, field graphql
 	return ec.marshalNFloat2float64(ctx, field.Selections, res)
 }
 
+func (ec *executionContext) _Temperature_depth(ctx context.Context, field graphql.CollectedField, obj *Temperature) (ret graphql.Marshaler) {
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	fc := &graphql.FieldContext{
+		Object:     "Temperature",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
+	}
+
+	ctx = graphql.WithFieldContext(ctx, fc)
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.Depth, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		if !graphql.HasFieldError(ctx, fc) {
+			ec.Errorf(ctx, "must not be null")
+		}
+		return graphql.Null
+	}
+	res := resTmp.(float64)
+	fc.Result = res
+	return ec.marshalNFloat2float64(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) _Temperature_unitCode(ctx context.Context, field graphql.CollectedField, obj *Temperature) (ret graphql.Marshaler) {
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	fc := &graphql.FieldContext{
+		Object:     "Temperature",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
+	}
+
+	ctx = graphql.WithFieldContext(ctx, fc)
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.UnitCode, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		if !graphql.HasFieldError(ctx, fc) {
+			ec.Errorf(ctx, "must not be null")
+		}
+		return graphql.Null
+	}
+	res := resTmp.(TemperatureUnit)
+	fc.Result = res
+	return ec.marshalNTemperatureUnit2githubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐTemperatureUnit(ctx, field.Selections, res)
+}
+
+func (ec *executionContext) _Temperature_quality(ctx context.Context, field graphql.CollectedField, obj *Temperature) (ret graphql.Marshaler) {
+	defer func() {
+		if r := recover(); r != nil {
+			ec.Error(ctx, ec.Recover(ctx, r))
+			ret = graphql.Null
+		}
+	}()
+	fc := &graphql.FieldContext{
+		Object:     "Temperature",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
+	}
+
+	ctx = graphql.WithFieldContext(ctx, fc)
+	resTmp, err := ec.ResolverMiddleware(ctx, func(rctx context.Context) (interface{}, error) {
+		ctx = rctx // use context from middleware stack in children
+		return obj.Quality, nil
+	})
+	if err != nil {
+		ec.Error(ctx, err)
+		return graphql.Null
+	}
+	if resTmp == nil {
+		if !graphql.HasFieldError(ctx, fc) {
+			ec.Errorf(ctx, "must not be null")
+		}
+		return graphql.Null
+	}
+	res := resTmp.(*Quality)
+	fc.Result = res
+	return ec.marshalNQuality2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐQuality(ctx, field.Selections, res)
+}
+
 func (ec *executionContext) _WGS84Position_lon(ctx context.Context, field graphql.CollectedField, obj *WGS84Position) (ret graphql.Marshaler) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -823,10 +1356,11 @@ func (ec *executionContext) _WGS84Position_lon(ctx context.Context, field graphq
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "WGS84Position",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "WGS84Position",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -857,10 +1391,11 @@ func (ec *executionContext) _WGS84Position_lat(ctx context.Context, field graphq
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "WGS84Position",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "WGS84Position",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -891,10 +1426,11 @@ func (ec *executionContext) __Service_sdl(ctx context.Context, field graphql.Col
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "_Service",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "_Service",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -922,10 +1458,11 @@ func (ec *executionContext) ___Directive_name(ctx context.Context, field graphql
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Directive",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "__Directive",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -956,10 +1493,11 @@ func (ec *executionContext) ___Directive_description(ctx context.Context, field
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Directive",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "__Directive",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -987,10 +1525,11 @@ func (ec *executionContext) ___Directive_locations(ctx context.Context, field gr
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Directive",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "__Directive",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1021,10 +1560,11 @@ func (ec *executionContext) ___Directive_args(ctx context.Context, field graphql
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Directive",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "__Directive",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1055,10 +1595,11 @@ func (ec *executionContext) ___EnumValue_name(ctx context.Context, field graphql
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__EnumValue",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "__EnumValue",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1089,10 +1630,11 @@ func (ec *executionContext) ___EnumValue_description(ctx context.Context, field
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__EnumValue",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "__EnumValue",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1120,10 +1662,11 @@ func (ec *executionContext) ___EnumValue_isDeprecated(ctx context.Context, field
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__EnumValue",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__EnumValue",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1154,10 +1697,11 @@ func (ec *executionContext) ___EnumValue_deprecationReason(ctx context.Context,
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__EnumValue",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__EnumValue",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1185,10 +1729,11 @@ func (ec *executionContext) ___Field_name(ctx context.Context, field graphql.Col
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Field",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "__Field",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1219,10 +1764,11 @@ func (ec *executionContext) ___Field_description(ctx context.Context, field grap
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Field",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "__Field",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1250,10 +1796,11 @@ func (ec *executionContext) ___Field_args(ctx context.Context, field graphql.Col
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Field",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "__Field",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1284,10 +1831,11 @@ func (ec *executionContext) ___Field_type(ctx context.Context, field graphql.Col
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Field",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "__Field",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1318,10 +1866,11 @@ func (ec *executionContext) ___Field_isDeprecated(ctx context.Context, field gra
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Field",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__Field",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1352,10 +1901,11 @@ func (ec *executionContext) ___Field_deprecationReason(ctx context.Context, fiel
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Field",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__Field",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1383,10 +1933,11 @@ func (ec *executionContext) ___InputValue_name(ctx context.Context, field graphq
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__InputValue",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "__InputValue",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1417,10 +1968,11 @@ func (ec *executionContext) ___InputValue_description(ctx context.Context, field
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__InputValue",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "__InputValue",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1448,10 +2000,11 @@ func (ec *executionContext) ___InputValue_type(ctx context.Context, field graphq
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__InputValue",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "__InputValue",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1482,10 +2035,11 @@ func (ec *executionContext) ___InputValue_defaultValue(ctx context.Context, fiel
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__InputValue",
-		Field:    field,
-		Args:     nil,
-		IsMethod: false,
+		Object:     "__InputValue",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   false,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1513,10 +2067,11 @@ func (ec *executionContext) ___Schema_types(ctx context.Context, field graphql.C
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Schema",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__Schema",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1547,10 +2102,11 @@ func (ec *executionContext) ___Schema_queryType(ctx context.Context, field graph
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Schema",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__Schema",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1581,10 +2137,11 @@ func (ec *executionContext) ___Schema_mutationType(ctx context.Context, field gr
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Schema",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__Schema",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1612,10 +2169,11 @@ func (ec *executionContext) ___Schema_subscriptionType(ctx context.Context, fiel
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Schema",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__Schema",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1643,10 +2201,11 @@ func (ec *executionContext) ___Schema_directives(ctx context.Context, field grap
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Schema",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__Schema",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1677,10 +2236,11 @@ func (ec *executionContext) ___Type_kind(ctx context.Context, field graphql.Coll
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Type",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__Type",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1711,10 +2271,11 @@ func (ec *executionContext) ___Type_name(ctx context.Context, field graphql.Coll
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Type",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__Type",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1742,10 +2303,11 @@ func (ec *executionContext) ___Type_description(ctx context.Context, field graph
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Type",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__Type",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1773,10 +2335,11 @@ func (ec *executionContext) ___Type_fields(ctx context.Context, field graphql.Co
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Type",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__Type",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1811,10 +2374,11 @@ func (ec *executionContext) ___Type_interfaces(ctx context.Context, field graphq
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Type",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__Type",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1842,10 +2406,11 @@ func (ec *executionContext) ___Type_possibleTypes(ctx context.Context, field gra
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Type",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__Type",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1873,10 +2438,11 @@ func (ec *executionContext) ___Type_enumValues(ctx context.Context, field graphq
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Type",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__Type",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1911,10 +2477,11 @@ func (ec *executionContext) ___Type_inputFields(ctx context.Context, field graph
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Type",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__Type",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -1942,10 +2509,11 @@ func (ec *executionContext) ___Type_ofType(ctx context.Context, field graphql.Co
 		}
 	}()
 	fc := &graphql.FieldContext{
-		Object:   "__Type",
-		Field:    field,
-		Args:     nil,
-		IsMethod: true,
+		Object:     "__Type",
+		Field:      field,
+		Args:       nil,
+		IsMethod:   true,
+		IsResolver: false,
 	}
 
 	ctx = graphql.WithFieldContext(ctx, fc)
@@ -2036,6 +2604,36 @@ func (ec *executionContext) _Device(ctx context.Context, sel ast.SelectionSet, o
 	return out
 }
 
+var deviceMetadataImplementors = []string{"DeviceMetadata"}
+
+func (ec *executionContext) _DeviceMetadata(ctx context.Context, sel ast.SelectionSet, obj *DeviceMetadata) graphql.Marshaler {
+	fields := graphql.CollectFields(ec.OperationContext, sel, deviceMetadataImplementors)
+
+	out := graphql.NewFieldSet(fields)
+	var invalids uint32
+	for i, field := range fields {
+		switch field.Name {
+		case "__typename":
+			out.Values[i] = graphql.MarshalString("DeviceMetadata")
+		case "name":
+			out.Values[i] = ec._DeviceMetadata_name(ctx, field, obj)
+		case "owner":
+			out.Values[i] = ec._DeviceMetadata_owner(ctx, field, obj)
+		case "site":
+			out.Values[i] = ec._DeviceMetadata_site(ctx, field, obj)
+		case "model":
+			out.Values[i] = ec._DeviceMetadata_model(ctx, field, obj)
+		default:
+			panic("unknown field " + strconv.Quote(field.Name))
+		}
+	}
+	out.Dispatch()
+	if invalids > 0 {
+		return graphql.Null
+	}
+	return out
+}
+
 var entityImplementors = []string{"Entity"}
 
 func (ec *executionContext) _Entity(ctx context.Context, sel ast.SelectionSet) graphql.Marshaler {
@@ -2091,6 +2689,37 @@ func (ec *executionContext) _Origin(ctx context.Context, sel ast.SelectionSet, o
 			out.Values[i] = ec._Origin_device(ctx, field, obj)
 		case "pos":
 			out.Values[i] = ec._Origin_pos(ctx, field, obj)
+		case "deviceMetadata":
+			out.Values[i] = ec._Origin_deviceMetadata(ctx, field, obj)
+		default:
+			panic("unknown field " + strconv.Quote(field.Name))
+		}
+	}
+	out.Dispatch()
+	if invalids > 0 {
+		return graphql.Null
+	}
+	return out
+}
+
+var qualityImplementors = []string{"Quality"}
+
+func (ec *executionContext) _Quality(ctx context.Context, sel ast.SelectionSet, obj *Quality) graphql.Marshaler {
+	fields := graphql.CollectFields(ec.OperationContext, sel, qualityImplementors)
+
+	out := graphql.NewFieldSet(fields)
+	var invalids uint32
+	for i, field := range fields {
+		switch field.Name {
+		case "__typename":
+			out.Values[i] = graphql.MarshalString("Quality")
+		case "status":
+			out.Values[i] = ec._Quality_status(ctx, field, obj)
+			if out.Values[i] == graphql.Null {
+				invalids++
+			}
+		case "reason":
+			out.Values[i] = ec._Quality_reason(ctx, field, obj)
 		default:
 			panic("unknown field " + strconv.Quote(field.Name))
 		}
@@ -2200,6 +2829,21 @@ func (ec *executionContext) _Temperature(ctx context.Context, sel ast.SelectionS
 			if out.Values[i] == graphql.Null {
 				invalids++
 			}
+		case "depth":
+			out.Values[i] = ec._Temperature_depth(ctx, field, obj)
+			if out.Values[i] == graphql.Null {
+				invalids++
+			}
+		case "unitCode":
+			out.Values[i] = ec._Temperature_unitCode(ctx, field, obj)
+			if out.Values[i] == graphql.Null {
+				invalids++
+			}
+		case "quality":
+			out.Values[i] = ec._Temperature_quality(ctx, field, obj)
+			if out.Values[i] == graphql.Null {
+				invalids++
+			}
 		default:
 			panic("unknown field " + strconv.Quote(field.Name))
 		}
@@ -2513,7 +3157,8 @@ func (ec *executionContext) ___Type(ctx context.Context, sel ast.SelectionSet, o
 // region    ***************************** type.gotpl *****************************
 
 func (ec *executionContext) unmarshalNBoolean2bool(ctx context.Context, v interface{}) (bool, error) {
-	return graphql.UnmarshalBoolean(v)
+	res, err := graphql.UnmarshalBoolean(v)
+	return res, graphql.ErrorOnPath(ctx, err)
 }
 
 func (ec *executionContext) marshalNBoolean2bool(ctx context.Context, sel ast.SelectionSet, v bool) graphql.Marshaler {
@@ -2527,7 +3172,8 @@ func (ec *executionContext) marshalNBoolean2bool(ctx context.Context, sel ast.Se
 }
 
 func (ec *executionContext) unmarshalNDateTime2string(ctx context.Context, v interface{}) (string, error) {
-	return graphql.UnmarshalString(v)
+	res, err := graphql.UnmarshalString(v)
+	return res, graphql.ErrorOnPath(ctx, err)
 }
 
 func (ec *executionContext) marshalNDateTime2string(ctx context.Context, sel ast.SelectionSet, v string) graphql.Marshaler {
@@ -2540,11 +3186,11 @@ func (ec *executionContext) marshalNDateTime2string(ctx context.Context, sel ast
 	return res
 }
 
-func (ec *executionContext) marshalNDevice2githubᚗcomᚋiotᚑforᚑtillgenglighetᚋapiᚑtemperatureᚋinternalᚋpkgᚋgraphqlᚐDevice(ctx context.Context, sel ast.SelectionSet, v Device) graphql.Marshaler {
+func (ec *executionContext) marshalNDevice2githubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐDevice(ctx context.Context, sel ast.SelectionSet, v Device) graphql.Marshaler {
 	return ec._Device(ctx, sel, &v)
 }
 
-func (ec *executionContext) marshalNDevice2ᚖgithubᚗcomᚋiotᚑforᚑtillgenglighetᚋapiᚑtemperatureᚋinternalᚋpkgᚋgraphqlᚐDevice(ctx context.Context, sel ast.SelectionSet, v *Device) graphql.Marshaler {
+func (ec *executionContext) marshalNDevice2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐDevice(ctx context.Context, sel ast.SelectionSet, v *Device) graphql.Marshaler {
 	if v == nil {
 		if !graphql.HasFieldError(ctx, graphql.GetFieldContext(ctx)) {
 			ec.Errorf(ctx, "must not be null")
@@ -2555,7 +3201,8 @@ func (ec *executionContext) marshalNDevice2ᚖgithubᚗcomᚋiotᚑforᚑtillgen
 }
 
 func (ec *executionContext) unmarshalNFloat2float64(ctx context.Context, v interface{}) (float64, error) {
-	return graphql.UnmarshalFloat(v)
+	res, err := graphql.UnmarshalFloat(v)
+	return res, graphql.ErrorOnPath(ctx, err)
 }
 
 func (ec *executionContext) marshalNFloat2float64(ctx context.Context, sel ast.SelectionSet, v float64) graphql.Marshaler {
@@ -2569,7 +3216,8 @@ func (ec *executionContext) marshalNFloat2float64(ctx context.Context, sel ast.S
 }
 
 func (ec *executionContext) unmarshalNID2string(ctx context.Context, v interface{}) (string, error) {
-	return graphql.UnmarshalID(v)
+	res, err := graphql.UnmarshalID(v)
+	return res, graphql.ErrorOnPath(ctx, err)
 }
 
 func (ec *executionContext) marshalNID2string(ctx context.Context, sel ast.SelectionSet, v string) graphql.Marshaler {
@@ -2582,22 +3230,39 @@ func (ec *executionContext) marshalNID2string(ctx context.Context, sel ast.Selec
 	return res
 }
 
-func (ec *executionContext) marshalNOrigin2githubᚗcomᚋiotᚑforᚑtillgenglighetᚋapiᚑtemperatureᚋinternalᚋpkgᚋgraphqlᚐOrigin(ctx context.Context, sel ast.SelectionSet, v Origin) graphql.Marshaler {
-	return ec._Origin(ctx, sel, &v)
+func (ec *executionContext) marshalNOrigin2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐOrigin(ctx context.Context, sel ast.SelectionSet, v *Origin) graphql.Marshaler {
+	if v == nil {
+		if !graphql.HasFieldError(ctx, graphql.GetFieldContext(ctx)) {
+			ec.Errorf(ctx, "must not be null")
+		}
+		return graphql.Null
+	}
+	return ec._Origin(ctx, sel, v)
 }
 
-func (ec *executionContext) marshalNOrigin2ᚖgithubᚗcomᚋiotᚑforᚑtillgenglighetᚋapiᚑtemperatureᚋinternalᚋpkgᚋgraphqlᚐOrigin(ctx context.Context, sel ast.SelectionSet, v *Origin) graphql.Marshaler {
+func (ec *executionContext) marshalNQuality2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐQuality(ctx context.Context, sel ast.SelectionSet, v *Quality) graphql.Marshaler {
 	if v == nil {
 		if !graphql.HasFieldError(ctx, graphql.GetFieldContext(ctx)) {
 			ec.Errorf(ctx, "must not be null")
 		}
 		return graphql.Null
 	}
-	return ec._Origin(ctx, sel, v)
+	return ec._Quality(ctx, sel, v)
+}
+
+func (ec *executionContext) unmarshalNQualityStatus2githubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐQualityStatus(ctx context.Context, v interface{}) (QualityStatus, error) {
+	var res QualityStatus
+	err := res.UnmarshalGQL(v)
+	return res, graphql.ErrorOnPath(ctx, err)
+}
+
+func (ec *executionContext) marshalNQualityStatus2githubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐQualityStatus(ctx context.Context, sel ast.SelectionSet, v QualityStatus) graphql.Marshaler {
+	return v
 }
 
 func (ec *executionContext) unmarshalNString2string(ctx context.Context, v interface{}) (string, error) {
-	return graphql.UnmarshalString(v)
+	res, err := graphql.UnmarshalString(v)
+	return res, graphql.ErrorOnPath(ctx, err)
 }
 
 func (ec *executionContext) marshalNString2string(ctx context.Context, sel ast.SelectionSet, v string) graphql.Marshaler {
@@ -2610,7 +3275,7 @@ func (ec *executionContext) marshalNString2string(ctx context.Context, sel ast.S
 	return res
 }
 
-func (ec *executionContext) marshalNTemperature2ᚕᚖgithubᚗcomᚋiotᚑforᚑtillgenglighetᚋapiᚑtemperatureᚋinternalᚋpkgᚋgraphqlᚐTemperature(ctx context.Context, sel ast.SelectionSet, v []*Temperature) graphql.Marshaler {
+func (ec *executionContext) marshalNTemperature2ᚕᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐTemperature(ctx context.Context, sel ast.SelectionSet, v []*Temperature) graphql.Marshaler {
 	ret := make(graphql.Array, len(v))
 	var wg sync.WaitGroup
 	isLen1 := len(v) == 1
@@ -2634,7 +3299,7 @@ func (ec *executionContext) marshalNTemperature2ᚕᚖgithubᚗcomᚋiotᚑfor
 			if !isLen1 {
 				defer wg.Done()
 			}
-			ret[i] = ec.marshalOTemperature2ᚖgithubᚗcomᚋiotᚑforᚑtillgenglighetᚋapiᚑtemperatureᚋinternalᚋpkgᚋgraphqlᚐTemperature(ctx, sel, v[i])
+			ret[i] = ec.marshalOTemperature2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐTemperature(ctx, sel, v[i])
 		}
 		if isLen1 {
 			f(i)
@@ -2647,11 +3312,19 @@ func (ec *executionContext) marshalNTemperature2ᚕᚖgithubᚗcomᚋiotᚑfor
 	return ret
 }
 
+func (ec *executionContext) unmarshalNTemperatureUnit2githubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐTemperatureUnit(ctx context.Context, v interface{}) (TemperatureUnit, error) {
+	var res TemperatureUnit
+	err := res.UnmarshalGQL(v)
+	return res, graphql.ErrorOnPath(ctx, err)
+}
+
+func (ec *executionContext) marshalNTemperatureUnit2githubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐTemperatureUnit(ctx context.Context, sel ast.SelectionSet, v TemperatureUnit) graphql.Marshaler {
+	return v
+}
+
 func (ec *executionContext) unmarshalN_Any2map(ctx context.Context, v interface{}) (map[string]interface{}, error) {
-	if v == nil {
-		return nil, nil
-	}
-	return graphql.UnmarshalMap(v)
+	res, err := graphql.UnmarshalMap(v)
+	return res, graphql.ErrorOnPath(ctx, err)
 }
 
 func (ec *executionContext) marshalN_Any2map(ctx context.Context, sel ast.SelectionSet, v map[string]interface{}) graphql.Marshaler {
@@ -2682,6 +3355,7 @@ func (ec *executionContext) unmarshalN_Any2ᚕmapᚄ(ctx context.Context, v inte
 	var err error
 	res := make([]map[string]interface{}, len(vSlice))
 	for i := range vSlice {
+		ctx := graphql.WithPathContext(ctx, graphql.NewPathWithIndex(i))
 		res[i], err = ec.unmarshalN_Any2map(ctx, vSlice[i])
 		if err != nil {
 			return nil, err
@@ -2737,7 +3411,8 @@ func (ec *executionContext) marshalN_Entity2ᚕgithubᚗcomᚋ99designsᚋgqlgen
 }
 
 func (ec *executionContext) unmarshalN_FieldSet2string(ctx context.Context, v interface{}) (string, error) {
-	return graphql.UnmarshalString(v)
+	res, err := graphql.UnmarshalString(v)
+	return res, graphql.ErrorOnPath(ctx, err)
 }
 
 func (ec *executionContext) marshalN_FieldSet2string(ctx context.Context, sel ast.SelectionSet, v string) graphql.Marshaler {
@@ -2796,7 +3471,8 @@ func (ec *executionContext) marshalN__Directive2ᚕgithubᚗcomᚋ99designsᚋgq
 }
 
 func (ec *executionContext) unmarshalN__DirectiveLocation2string(ctx context.Context, v interface{}) (string, error) {
-	return graphql.UnmarshalString(v)
+	res, err := graphql.UnmarshalString(v)
+	return res, graphql.ErrorOnPath(ctx, err)
 }
 
 func (ec *executionContext) marshalN__DirectiveLocation2string(ctx context.Context, sel ast.SelectionSet, v string) graphql.Marshaler {
@@ -2821,6 +3497,7 @@ func (ec *executionContext) unmarshalN__DirectiveLocation2ᚕstringᚄ(ctx conte
 	var err error
 	res := make([]string, len(vSlice))
 	for i := range vSlice {
+		ctx := graphql.WithPathContext(ctx, graphql.NewPathWithIndex(i))
 		res[i], err = ec.unmarshalN__DirectiveLocation2string(ctx, vSlice[i])
 		if err != nil {
 			return nil, err
@@ -2967,7 +3644,8 @@ func (ec *executionContext) marshalN__Type2ᚖgithubᚗcomᚋ99designsᚋgqlgen
 }
 
 func (ec *executionContext) unmarshalN__TypeKind2string(ctx context.Context, v interface{}) (string, error) {
-	return graphql.UnmarshalString(v)
+	res, err := graphql.UnmarshalString(v)
+	return res, graphql.ErrorOnPath(ctx, err)
 }
 
 func (ec *executionContext) marshalN__TypeKind2string(ctx context.Context, sel ast.SelectionSet, v string) graphql.Marshaler {
@@ -2981,7 +3659,8 @@ func (ec *executionContext) marshalN__TypeKind2string(ctx context.Context, sel a
 }
 
 func (ec *executionContext) unmarshalOBoolean2bool(ctx context.Context, v interface{}) (bool, error) {
-	return graphql.UnmarshalBoolean(v)
+	res, err := graphql.UnmarshalBoolean(v)
+	return res, graphql.ErrorOnPath(ctx, err)
 }
 
 func (ec *executionContext) marshalOBoolean2bool(ctx context.Context, sel ast.SelectionSet, v bool) graphql.Marshaler {
@@ -2992,30 +3671,129 @@ func (ec *executionContext) unmarshalOBoolean2ᚖbool(ctx context.Context, v int
 	if v == nil {
 		return nil, nil
 	}
-	res, err := ec.unmarshalOBoolean2bool(ctx, v)
-	return &res, err
+	res, err := graphql.UnmarshalBoolean(v)
+	return &res, graphql.ErrorOnPath(ctx, err)
 }
 
 func (ec *executionContext) marshalOBoolean2ᚖbool(ctx context.Context, sel ast.SelectionSet, v *bool) graphql.Marshaler {
 	if v == nil {
 		return graphql.Null
 	}
-	return ec.marshalOBoolean2bool(ctx, sel, *v)
+	return graphql.MarshalBoolean(*v)
 }
 
-func (ec *executionContext) marshalODevice2githubᚗcomᚋiotᚑforᚑtillgenglighetᚋapiᚑtemperatureᚋinternalᚋpkgᚋgraphqlᚐDevice(ctx context.Context, sel ast.SelectionSet, v Device) graphql.Marshaler {
-	return ec._Device(ctx, sel, &v)
+func (ec *executionContext) unmarshalOCalibrationSelection2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐCalibrationSelection(ctx context.Context, v interface{}) (*CalibrationSelection, error) {
+	if v == nil {
+		return nil, nil
+	}
+	var res = new(CalibrationSelection)
+	err := res.UnmarshalGQL(v)
+	return res, graphql.ErrorOnPath(ctx, err)
+}
+
+func (ec *executionContext) marshalOCalibrationSelection2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐCalibrationSelection(ctx context.Context, sel ast.SelectionSet, v *CalibrationSelection) graphql.Marshaler {
+	if v == nil {
+		return graphql.Null
+	}
+	return v
 }
 
-func (ec *executionContext) marshalODevice2ᚖgithubᚗcomᚋiotᚑforᚑtillgenglighetᚋapiᚑtemperatureᚋinternalᚋpkgᚋgraphqlᚐDevice(ctx context.Context, sel ast.SelectionSet, v *Device) graphql.Marshaler {
+func (ec *executionContext) marshalODevice2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐDevice(ctx context.Context, sel ast.SelectionSet, v *Device) graphql.Marshaler {
 	if v == nil {
 		return graphql.Null
 	}
 	return ec._Device(ctx, sel, v)
 }
 
+func (ec *executionContext) marshalODeviceMetadata2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐDeviceMetadata(ctx context.Context, sel ast.SelectionSet, v *DeviceMetadata) graphql.Marshaler {
+	if v == nil {
+		return graphql.Null
+	}
+	return ec._DeviceMetadata(ctx, sel, v)
+}
+
+func (ec *executionContext) unmarshalOFloat2ᚖfloat64(ctx context.Context, v interface{}) (*float64, error) {
+	if v == nil {
+		return nil, nil
+	}
+	res, err := graphql.UnmarshalFloat(v)
+	return &res, graphql.ErrorOnPath(ctx, err)
+}
+
+func (ec *executionContext) marshalOFloat2ᚖfloat64(ctx context.Context, sel ast.SelectionSet, v *float64) graphql.Marshaler {
+	if v == nil {
+		return graphql.Null
+	}
+	return graphql.MarshalFloat(*v)
+}
+
+func (ec *executionContext) unmarshalOQualityStatus2ᚕgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐQualityStatusᚄ(ctx context.Context, v interface{}) ([]QualityStatus, error) {
+	if v == nil {
+		return nil, nil
+	}
+	var vSlice []interface{}
+	if v != nil {
+		if tmp1, ok := v.([]interface{}); ok {
+			vSlice = tmp1
+		} else {
+			vSlice = []interface{}{v}
+		}
+	}
+	var err error
+	res := make([]QualityStatus, len(vSlice))
+	for i := range vSlice {
+		ctx := graphql.WithPathContext(ctx, graphql.NewPathWithIndex(i))
+		res[i], err = ec.unmarshalNQualityStatus2githubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐQualityStatus(ctx, vSlice[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (ec *executionContext) marshalOQualityStatus2ᚕgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐQualityStatusᚄ(ctx context.Context, sel ast.SelectionSet, v []QualityStatus) graphql.Marshaler {
+	if v == nil {
+		return graphql.Null
+	}
+	ret := make(graphql.Array, len(v))
+	var wg sync.WaitGroup
+	isLen1 := len(v) == 1
+	if !isLen1 {
+		wg.Add(len(v))
+	}
+	for i := range v {
+		i := i
+		fc := &graphql.FieldContext{
+			Index:  &i,
+			Result: &v[i],
+		}
+		ctx := graphql.WithFieldContext(ctx, fc)
+		f := func(i int) {
+			defer func() {
+				if r := recover(); r != nil {
+					ec.Error(ctx, ec.Recover(ctx, r))
+					ret = nil
+				}
+			}()
+			if !isLen1 {
+				defer wg.Done()
+			}
+			ret[i] = ec.marshalNQualityStatus2githubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐQualityStatus(ctx, sel, v[i])
+		}
+		if isLen1 {
+			f(i)
+		} else {
+			go f(i)
+		}
+
+	}
+	wg.Wait()
+	return ret
+}
+
 func (ec *executionContext) unmarshalOString2string(ctx context.Context, v interface{}) (string, error) {
-	return graphql.UnmarshalString(v)
+	res, err := graphql.UnmarshalString(v)
+	return res, graphql.ErrorOnPath(ctx, err)
 }
 
 func (ec *executionContext) marshalOString2string(ctx context.Context, sel ast.SelectionSet, v string) graphql.Marshaler {
@@ -3026,33 +3804,41 @@ func (ec *executionContext) unmarshalOString2ᚖstring(ctx context.Context, v in
 	if v == nil {
 		return nil, nil
 	}
-	res, err := ec.unmarshalOString2string(ctx, v)
-	return &res, err
+	res, err := graphql.UnmarshalString(v)
+	return &res, graphql.ErrorOnPath(ctx, err)
 }
 
 func (ec *executionContext) marshalOString2ᚖstring(ctx context.Context, sel ast.SelectionSet, v *string) graphql.Marshaler {
 	if v == nil {
 		return graphql.Null
 	}
-	return ec.marshalOString2string(ctx, sel, *v)
+	return graphql.MarshalString(*v)
 }
 
-func (ec *executionContext) marshalOTemperature2githubᚗcomᚋiotᚑforᚑtillgenglighetᚋapiᚑtemperatureᚋinternalᚋpkgᚋgraphqlᚐTemperature(ctx context.Context, sel ast.SelectionSet, v Temperature) graphql.Marshaler {
-	return ec._Temperature(ctx, sel, &v)
-}
-
-func (ec *executionContext) marshalOTemperature2ᚖgithubᚗcomᚋiotᚑforᚑtillgenglighetᚋapiᚑtemperatureᚋinternalᚋpkgᚋgraphqlᚐTemperature(ctx context.Context, sel ast.SelectionSet, v *Temperature) graphql.Marshaler {
+func (ec *executionContext) marshalOTemperature2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐTemperature(ctx context.Context, sel ast.SelectionSet, v *Temperature) graphql.Marshaler {
 	if v == nil {
 		return graphql.Null
 	}
 	return ec._Temperature(ctx, sel, v)
 }
 
-func (ec *executionContext) marshalOWGS84Position2githubᚗcomᚋiotᚑforᚑtillgenglighetᚋapiᚑtemperatureᚋinternalᚋpkgᚋgraphqlᚐWGS84Position(ctx context.Context, sel ast.SelectionSet, v WGS84Position) graphql.Marshaler {
-	return ec._WGS84Position(ctx, sel, &v)
+func (ec *executionContext) unmarshalOTemperatureUnit2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐTemperatureUnit(ctx context.Context, v interface{}) (*TemperatureUnit, error) {
+	if v == nil {
+		return nil, nil
+	}
+	var res = new(TemperatureUnit)
+	err := res.UnmarshalGQL(v)
+	return res, graphql.ErrorOnPath(ctx, err)
 }
 
-func (ec *executionContext) marshalOWGS84Position2ᚖgithubᚗcomᚋiotᚑforᚑtillgenglighetᚋapiᚑtemperatureᚋinternalᚋpkgᚋgraphqlᚐWGS84Position(ctx context.Context, sel ast.SelectionSet, v *WGS84Position) graphql.Marshaler {
+func (ec *executionContext) marshalOTemperatureUnit2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐTemperatureUnit(ctx context.Context, sel ast.SelectionSet, v *TemperatureUnit) graphql.Marshaler {
+	if v == nil {
+		return graphql.Null
+	}
+	return v
+}
+
+func (ec *executionContext) marshalOWGS84Position2ᚖgithubᚗcomᚋdiwiseᚋapiᚑtemperatureᚋinternalᚋpkgᚋ_presentationᚋapiᚋgraphqlᚐWGS84Position(ctx context.Context, sel ast.SelectionSet, v *WGS84Position) graphql.Marshaler {
 	if v == nil {
 		return graphql.Null
 	}
@@ -3186,10 +3972,6 @@ func (ec *executionContext) marshalO__InputValue2ᚕgithubᚗcomᚋ99designsᚋg
 	return ret
 }
 
-func (ec *executionContext) marshalO__Schema2githubᚗcomᚋ99designsᚋgqlgenᚋgraphqlᚋintrospectionᚐSchema(ctx context.Context, sel ast.SelectionSet, v introspection.Schema) graphql.Marshaler {
-	return ec.___Schema(ctx, sel, &v)
-}
-
 func (ec *executionContext) marshalO__Schema2ᚖgithubᚗcomᚋ99designsᚋgqlgenᚋgraphqlᚋintrospectionᚐSchema(ctx context.Context, sel ast.SelectionSet, v *introspection.Schema) graphql.Marshaler {
 	if v == nil {
 		return graphql.Null
@@ -3197,10 +3979,6 @@ func (ec *executionContext) marshalO__Schema2ᚖgithubᚗcomᚋ99designsᚋgqlge
 	return ec.___Schema(ctx, sel, v)
 }
 
-func (ec *executionContext) marshalO__Type2githubᚗcomᚋ99designsᚋgqlgenᚋgraphqlᚋintrospectionᚐType(ctx context.Context, sel ast.SelectionSet, v introspection.Type) graphql.Marshaler {
-	return ec.___Type(ctx, sel, &v)
-}
-
 func (ec *executionContext) marshalO__Type2ᚕgithubᚗcomᚋ99designsᚋgqlgenᚋgraphqlᚋintrospectionᚐTypeᚄ(ctx context.Context, sel ast.SelectionSet, v []introspection.Type) graphql.Marshaler {
 	if v == nil {
 		return graphql.Null
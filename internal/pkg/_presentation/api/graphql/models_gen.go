@@ -2,6 +2,12 @@
 
 package graphql
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
 type Telemetry interface {
 	IsTelemetry()
 }
@@ -12,15 +18,31 @@ type Device struct {
 
 func (Device) IsEntity() {}
 
+type DeviceMetadata struct {
+	Name  *string `json:"name"`
+	Owner *string `json:"owner"`
+	Site  *string `json:"site"`
+	Model *string `json:"model"`
+}
+
 type Origin struct {
-	Device *Device        `json:"device"`
-	Pos    *WGS84Position `json:"pos"`
+	Device         *Device         `json:"device"`
+	Pos            *WGS84Position  `json:"pos"`
+	DeviceMetadata *DeviceMetadata `json:"deviceMetadata"`
+}
+
+type Quality struct {
+	Status QualityStatus `json:"status"`
+	Reason *string       `json:"reason"`
 }
 
 type Temperature struct {
-	From *Origin `json:"from"`
-	When string  `json:"when"`
-	Temp float64 `json:"temp"`
+	From     *Origin         `json:"from"`
+	When     string          `json:"when"`
+	Temp     float64         `json:"temp"`
+	Depth    float64         `json:"depth"`
+	UnitCode TemperatureUnit `json:"unitCode"`
+	Quality  *Quality        `json:"quality"`
 }
 
 func (Temperature) IsTelemetry() {}
@@ -29,3 +51,134 @@ type WGS84Position struct {
 	Lon float64 `json:"lon"`
 	Lat float64 `json:"lat"`
 }
+
+type CalibrationSelection string
+
+const (
+	CalibrationSelectionCorrected CalibrationSelection = "CORRECTED"
+	CalibrationSelectionRaw       CalibrationSelection = "RAW"
+)
+
+var AllCalibrationSelection = []CalibrationSelection{
+	CalibrationSelectionCorrected,
+	CalibrationSelectionRaw,
+}
+
+func (e CalibrationSelection) IsValid() bool {
+	switch e {
+	case CalibrationSelectionCorrected, CalibrationSelectionRaw:
+		return true
+	}
+	return false
+}
+
+func (e CalibrationSelection) String() string {
+	return string(e)
+}
+
+func (e *CalibrationSelection) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CalibrationSelection(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CalibrationSelection", str)
+	}
+	return nil
+}
+
+func (e CalibrationSelection) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type QualityStatus string
+
+const (
+	QualityStatusRaw               QualityStatus = "RAW"
+	QualityStatusGood              QualityStatus = "GOOD"
+	QualityStatusSuspect           QualityStatus = "SUSPECT"
+	QualityStatusBad               QualityStatus = "BAD"
+	QualityStatusManuallyCorrected QualityStatus = "MANUALLY_CORRECTED"
+)
+
+var AllQualityStatus = []QualityStatus{
+	QualityStatusRaw,
+	QualityStatusGood,
+	QualityStatusSuspect,
+	QualityStatusBad,
+	QualityStatusManuallyCorrected,
+}
+
+func (e QualityStatus) IsValid() bool {
+	switch e {
+	case QualityStatusRaw, QualityStatusGood, QualityStatusSuspect, QualityStatusBad, QualityStatusManuallyCorrected:
+		return true
+	}
+	return false
+}
+
+func (e QualityStatus) String() string {
+	return string(e)
+}
+
+func (e *QualityStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = QualityStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid QualityStatus", str)
+	}
+	return nil
+}
+
+func (e QualityStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type TemperatureUnit string
+
+const (
+	TemperatureUnitCel TemperatureUnit = "CEL"
+	TemperatureUnitFah TemperatureUnit = "FAH"
+	TemperatureUnitKel TemperatureUnit = "KEL"
+)
+
+var AllTemperatureUnit = []TemperatureUnit{
+	TemperatureUnitCel,
+	TemperatureUnitFah,
+	TemperatureUnitKel,
+}
+
+func (e TemperatureUnit) IsValid() bool {
+	switch e {
+	case TemperatureUnitCel, TemperatureUnitFah, TemperatureUnitKel:
+		return true
+	}
+	return false
+}
+
+func (e TemperatureUnit) String() string {
+	return string(e)
+}
+
+func (e *TemperatureUnit) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = TemperatureUnit(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid TemperatureUnit", str)
+	}
+	return nil
+}
+
+func (e TemperatureUnit) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -3,21 +3,41 @@ package graphql
 
 import (
 	"context"
+	"fmt"
 	"math"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/diwise/api-temperature/internal/pkg/application/access"
+	"github.com/diwise/api-temperature/internal/pkg/application/precision"
+	"github.com/diwise/api-temperature/internal/pkg/application/querycache"
+	"github.com/diwise/api-temperature/internal/pkg/application/units"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/auth"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/deviceregistry"
 	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/database"
 	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/tenancy"
 )
 
-type Resolver struct{}
+type Resolver struct {
+	Cache    *querycache.Cache
+	Rounding precision.Rounder
+	Devices  *deviceregistry.Client
+}
 
 func (r *entityResolver) FindDeviceByID(ctx context.Context, id string) (*Device, error) {
 	return &Device{ID: id}, nil
 }
 
-func convertDatabaseRecordToGQL(measurement *models.TemperatureV2) *Temperature {
+//convertDatabaseRecordToGQL converts a reading to the GraphQL type, with its temperature converted
+//from Celsius to the provided unit and rounded to the resolution of the sensor. The enum values of
+//TemperatureUnit are the unit codes themselves.
+func convertDatabaseRecordToGQL(measurement *models.TemperatureV2, unit TemperatureUnit, rounding precision.Rounder) *Temperature {
 	if measurement != nil {
+		value := units.FromCelsius(measurement.Temp, string(unit))
+		resolution := units.IntervalFromCelsius(measurement.Resolution, string(unit))
+
 		temp := &Temperature{
 			From: &Origin{
 				Pos: &WGS84Position{
@@ -28,8 +48,17 @@ func convertDatabaseRecordToGQL(measurement *models.TemperatureV2) *Temperature
 					ID: measurement.Device,
 				},
 			},
-			When: measurement.Timestamp.Format(time.RFC3339),
-			Temp: math.Round(float64(measurement.Temp*10)) / 10,
+			When:     measurement.Timestamp.Format(time.RFC3339),
+			Temp:     rounding.Round(value, resolution),
+			Depth:    measurement.Depth,
+			UnitCode: unit,
+			Quality: &Quality{
+				Status: convertQualityToGQL(measurement.Quality),
+			},
+		}
+
+		if measurement.QualityReason != "" {
+			temp.Quality.Reason = &measurement.QualityReason
 		}
 
 		return temp
@@ -38,16 +67,118 @@ func convertDatabaseRecordToGQL(measurement *models.TemperatureV2) *Temperature
 	return nil
 }
 
-func (r *queryResolver) Temperatures(ctx context.Context) ([]*Temperature, error) {
+//convertDeviceToGQL returns the metadata that the device registry has about a device of a tenant,
+//or nil if the registry does not know the device
+func convertDeviceToGQL(devices *deviceregistry.Client, tenant, id string) *DeviceMetadata {
+	d, ok := devices.Device(tenant, id)
+	if !ok {
+		return nil
+	}
+
+	optional := func(s string) *string {
+		if s == "" {
+			return nil
+		}
+		return &s
+	}
+
+	return &DeviceMetadata{
+		Name:  optional(d.Name),
+		Owner: optional(d.Owner),
+		Site:  optional(d.Site),
+		Model: optional(d.Model),
+	}
+}
+
+//convertDepthFromGQL returns the range of depths that the minDepth and maxDepth arguments ask for,
+//or nil if neither of them was provided
+func convertDepthFromGQL(minDepth, maxDepth *float64) (*database.DepthRange, error) {
+	if minDepth == nil && maxDepth == nil {
+		return nil, nil
+	}
+
+	depth := &database.DepthRange{Min: 0, Max: math.MaxFloat64}
+
+	if minDepth != nil {
+		depth.Min = *minDepth
+	}
+
+	if maxDepth != nil {
+		depth.Max = *maxDepth
+	}
+
+	if depth.Min < 0 || depth.Max < depth.Min {
+		return nil, fmt.Errorf("invalid depth range %g to %g", depth.Min, depth.Max)
+	}
+
+	return depth, nil
+}
+
+func convertQualityToGQL(quality string) QualityStatus {
+	if quality == models.QualityManuallyCorrected {
+		return QualityStatusManuallyCorrected
+	}
+
+	status := QualityStatus(strings.ToUpper(quality))
+	if !status.IsValid() {
+		return QualityStatusRaw
+	}
+
+	return status
+}
+
+func convertQualityFromGQL(status QualityStatus) string {
+	if status == QualityStatusManuallyCorrected {
+		return models.QualityManuallyCorrected
+	}
+
+	return strings.ToLower(string(status))
+}
+
+func (r *queryResolver) Temperatures(ctx context.Context, quality []QualityStatus, minDepth *float64, maxDepth *float64, calibration *CalibrationSelection, unit *TemperatureUnit) ([]*Temperature, error) {
 	db, err := database.GetFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	temperatures, err := db.GetTemperatures("", time.Time{}, time.Time{}, "", 0.0, 0.0, 0.0, 0.0, uint64(0), uint64(100))
+	qualityFilter := []string{}
+	for _, q := range quality {
+		qualityFilter = append(qualityFilter, convertQualityFromGQL(q))
+	}
+
+	depth, err := convertDepthFromGQL(minDepth, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := tenancy.FromContext(ctx)
+	db = db.WithContext(ctx).ForTenant(tenant)
 
+	// Devices that the caller may not see are excluded by the query, so that they do not take up
+	// any of the readings that are returned
+	hidden, err := access.HiddenDevices(ctx, db)
 	if err != nil {
-		panic("Failed to query latest temperatures.")
+		return nil, err
+	}
+	db = db.WithoutDevices(hidden)
+
+	depthKey := ""
+	if depth != nil {
+		depthKey = fmt.Sprintf("%g/%g", depth.Min, depth.Max)
+	}
+
+	sort.Strings(qualityFilter)
+	cacheKey := fmt.Sprintf("graphql|%s|temperatures|quality=%s|depth=%s|hidden=%s", tenant, strings.Join(qualityFilter, ","), depthKey, strings.Join(hidden, ","))
+
+	temperatures, found := r.Cache.Get(cacheKey)
+	if !found {
+		temperatures, err = db.GetTemperatures("", nil, qualityFilter, depth, time.Time{}, time.Time{}, "", 0.0, 0.0, 0.0, 0.0, uint64(0), uint64(100))
+
+		if err != nil {
+			panic("Failed to query latest temperatures.")
+		}
+
+		r.Cache.Put(cacheKey, tenant, models.Mediums(), temperatures)
 	}
 
 	tempcount := len(temperatures)
@@ -58,8 +189,27 @@ func (r *queryResolver) Temperatures(ctx context.Context) ([]*Temperature, error
 
 	gqltemps := make([]*Temperature, 0, tempcount)
 
+	raw := calibration != nil && *calibration == CalibrationSelectionRaw
+
+	presentedUnit := TemperatureUnitCel
+	if unit != nil {
+		presentedUnit = *unit
+	}
+
 	for _, v := range temperatures {
-		gqltemps = append(gqltemps, convertDatabaseRecordToGQL(&v))
+		// The devices of a tenant are cached, so a device that only another instance has stored
+		// readings for may not have been hidden by the query. Its readings are dropped here instead.
+		if !auth.MayReadDevice(ctx, v.Device) {
+			continue
+		}
+
+		if raw {
+			// v is a copy, so the cached reading keeps its calibrated temperature
+			v.Temp = v.RawTemp
+		}
+		temp := convertDatabaseRecordToGQL(&v, presentedUnit, r.Rounding)
+		temp.From.DeviceMetadata = convertDeviceToGQL(r.Devices, tenant, v.Device)
+		gqltemps = append(gqltemps, temp)
 	}
 
 	return gqltemps, nil
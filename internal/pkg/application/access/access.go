@@ -0,0 +1,58 @@
+package access
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/auth"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/database"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+)
+
+//HiddenDevices returns the devices whose readings the caller of the request that ctx belongs to may
+//not see, sorted so that they can be part of a cache key. Restricted devices are hidden from callers
+//that have not authenticated, which includes all callers when authentication is disabled, and grouped
+//devices are hidden from callers that are not members of their group. The devices are meant to be
+//excluded from queries with WithoutDevices, so that paging is not thrown off by readings that are
+//filtered out afterwards. The policies and devices are read from db, which should be scoped to the
+//tenant of the request. The devices are only listed when some group hides devices from the caller.
+func HiddenDevices(ctx context.Context, db database.Datastore) ([]string, error) {
+	policies, err := db.GetDevicePolicies()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve device policies: %s", err.Error())
+	}
+
+	hidden := map[string]bool{}
+
+	if !auth.IsAuthenticated(ctx) {
+		for _, p := range policies {
+			if p.Visibility == models.VisibilityRestricted {
+				hidden[p.Device] = true
+			}
+		}
+	}
+
+	// Device groups only apply to callers with a principal, i.e. when authentication is enabled, that
+	// are neither administrators nor members of every group
+	if auth.HidesGroupedDevices(ctx) {
+		devices, err := db.GetDevices()
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve devices: %s", err.Error())
+		}
+
+		for _, device := range devices {
+			if !auth.MayReadDevice(ctx, device) {
+				hidden[device] = true
+			}
+		}
+	}
+
+	devices := []string{}
+	for device := range hidden {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+
+	return devices, nil
+}
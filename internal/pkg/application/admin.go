@@ -0,0 +1,507 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/diwise/api-temperature/internal/pkg/application/alerting"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/messaging/deadletter"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/database"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/tenancy"
+)
+
+type qualityUpdate struct {
+	Status string   `json:"status"`
+	Reason string   `json:"reason"`
+	Temp   *float64 `json:"temp,omitempty"`
+}
+
+//tenantDB returns the datastore scoped to the tenant of a request, and traced as part of it
+func tenantDB(db database.Datastore, r *http.Request) database.Datastore {
+	return db.WithContext(r.Context()).ForTenant(tenancy.FromContext(r.Context()))
+}
+
+func (router *RequestRouter) addAdminHandlers(cfg config.Config, db database.Datastore, failures *FailureHandler, alerts alerting.Engine) {
+	// The effective configuration is served in the same format as the config file, with secrets redacted
+	router.Get("/admin/config", func(w http.ResponseWriter, r *http.Request) {
+		bytes, err := cfg.Redacted().Marshal()
+		if err != nil {
+			http.Error(w, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/yaml")
+		w.Write(bytes)
+	})
+
+	router.Get("/admin/deadletters", func(w http.ResponseWriter, r *http.Request) {
+		bytes, err := json.MarshalIndent(failures.DeadLetters(), "", "  ")
+		if err != nil {
+			http.Error(w, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		w.Write(bytes)
+	})
+
+	router.Post("/admin/deadletters/{id}/replay", func(w http.ResponseWriter, r *http.Request) {
+		err := failures.Replay(chi.URLParam(r, "id"))
+		if err != nil {
+			if errors.Is(err, deadletter.ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	router.Patch("/admin/temperatures/{id}/quality", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+		if err != nil {
+			http.Error(w, "invalid measurement id", http.StatusBadRequest)
+			return
+		}
+
+		update := qualityUpdate{}
+		if err = json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, "failed to decode request body", http.StatusBadRequest)
+			return
+		}
+
+		if update.Temp != nil {
+			// A corrected value always results in a manually corrected reading
+			update.Status = models.QualityManuallyCorrected
+		}
+
+		if !models.IsValidQuality(update.Status) {
+			http.Error(w, "unknown quality status "+update.Status, http.StatusBadRequest)
+			return
+		}
+
+		measurement, err := tenantDB(db, r).UpdateTemperatureQuality(uint(id), update.Status, update.Reason, update.Temp)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		bytes, _ := json.MarshalIndent(measurement, "", "  ")
+		w.Header().Add("Content-Type", "application/json")
+		w.Write(bytes)
+	})
+
+	router.addAlertRuleHandlers(db, alerts)
+	router.addDevicePolicyHandlers(db)
+	router.addCalibrationHandlers(db)
+}
+
+type alertRuleDTO struct {
+	ID          uint    `json:"id"`
+	Name        string  `json:"name"`
+	Devices     string  `json:"devices"`
+	Medium      string  `json:"medium"`
+	Condition   string  `json:"condition"`
+	Threshold   float64 `json:"threshold"`
+	Hysteresis  float64 `json:"hysteresis"`
+	MinDuration string  `json:"minDuration"`
+	Enabled     bool    `json:"enabled"`
+}
+
+func newAlertRuleDTO(rule *models.AlertRule) alertRuleDTO {
+	return alertRuleDTO{
+		ID:          rule.ID,
+		Name:        rule.Name,
+		Devices:     rule.Devices,
+		Medium:      rule.Medium,
+		Condition:   rule.Condition,
+		Threshold:   rule.Threshold,
+		Hysteresis:  rule.Hysteresis,
+		MinDuration: rule.MinDuration.String(),
+		Enabled:     rule.Enabled,
+	}
+}
+
+func (dto alertRuleDTO) toModel() (*models.AlertRule, error) {
+	if dto.Condition != models.AlertConditionAbove && dto.Condition != models.AlertConditionBelow {
+		return nil, fmt.Errorf("condition must be either %s or %s", models.AlertConditionAbove, models.AlertConditionBelow)
+	}
+
+	if !models.IsValidMedium(dto.Medium) {
+		return nil, fmt.Errorf("unknown medium %s", dto.Medium)
+	}
+
+	if dto.Hysteresis < 0 {
+		return nil, errors.New("hysteresis may not be negative")
+	}
+
+	rule := &models.AlertRule{
+		Name:       dto.Name,
+		Devices:    dto.Devices,
+		Medium:     dto.Medium,
+		Condition:  dto.Condition,
+		Threshold:  dto.Threshold,
+		Hysteresis: dto.Hysteresis,
+		Enabled:    dto.Enabled,
+	}
+
+	if dto.MinDuration != "" {
+		d, err := time.ParseDuration(dto.MinDuration)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid minimum duration %s", dto.MinDuration)
+		}
+		rule.MinDuration = d
+	}
+
+	return rule, nil
+}
+
+func decodeAlertRule(r *http.Request) (*models.AlertRule, error) {
+	dto := alertRuleDTO{}
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return nil, errors.New("failed to decode request body")
+	}
+
+	return dto.toModel()
+}
+
+//addAlertRuleHandlers registers the handlers that manage alert rules. Rules are managed per tenant,
+//for the tenant of the request, and only apply to the readings of their tenant.
+func (router *RequestRouter) addAlertRuleHandlers(db database.Datastore, alerts alerting.Engine) {
+	reloadRules := func(log zerolog.Logger) {
+		if err := alerts.Reload(); err != nil {
+			log.Error().Err(err).Msg("failed to reload alert rules")
+		}
+	}
+
+	router.Get("/admin/alertrules", func(w http.ResponseWriter, r *http.Request) {
+		rules, err := tenantDB(db, r).GetAlertRules()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		dtos := []alertRuleDTO{}
+		for i := range rules {
+			dtos = append(dtos, newAlertRuleDTO(&rules[i]))
+		}
+
+		writeJSON(w, http.StatusOK, dtos)
+	})
+
+	router.Post("/admin/alertrules", func(w http.ResponseWriter, r *http.Request) {
+		rule, err := decodeAlertRule(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rule, err = tenantDB(db, r).CreateAlertRule(rule)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		reloadRules(log.Logger)
+		writeJSON(w, http.StatusCreated, newAlertRuleDTO(rule))
+	})
+
+	router.Put("/admin/alertrules/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+		if err != nil {
+			http.Error(w, "invalid alert rule id", http.StatusBadRequest)
+			return
+		}
+
+		rule, err := decodeAlertRule(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rule, err = tenantDB(db, r).UpdateAlertRule(uint(id), rule)
+		if err != nil {
+			writeDatastoreError(w, err)
+			return
+		}
+
+		reloadRules(log.Logger)
+		writeJSON(w, http.StatusOK, newAlertRuleDTO(rule))
+	})
+
+	router.Delete("/admin/alertrules/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+		if err != nil {
+			http.Error(w, "invalid alert rule id", http.StatusBadRequest)
+			return
+		}
+
+		if err = tenantDB(db, r).DeleteAlertRule(uint(id)); err != nil {
+			writeDatastoreError(w, err)
+			return
+		}
+
+		reloadRules(log.Logger)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+type devicePolicyDTO struct {
+	Device     string `json:"device"`
+	Visibility string `json:"visibility"`
+}
+
+//addDevicePolicyHandlers registers the handlers that manage which devices are public and which
+//are restricted. Policies are managed per tenant, for the tenant of the request.
+func (router *RequestRouter) addDevicePolicyHandlers(db database.Datastore) {
+	router.Get("/admin/devicepolicies", func(w http.ResponseWriter, r *http.Request) {
+		policies, err := tenantDB(db, r).GetDevicePolicies()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		dtos := []devicePolicyDTO{}
+		for _, p := range policies {
+			dtos = append(dtos, devicePolicyDTO{Device: p.Device, Visibility: p.Visibility})
+		}
+
+		writeJSON(w, http.StatusOK, dtos)
+	})
+
+	router.Put("/admin/devicepolicies/{device}", func(w http.ResponseWriter, r *http.Request) {
+		dto := devicePolicyDTO{}
+		if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+			http.Error(w, "failed to decode request body", http.StatusBadRequest)
+			return
+		}
+
+		if !models.IsValidVisibility(dto.Visibility) {
+			http.Error(w, fmt.Sprintf("visibility must be either %s or %s", models.VisibilityPublic, models.VisibilityRestricted), http.StatusBadRequest)
+			return
+		}
+
+		policy, err := tenantDB(db, r).SetDevicePolicy(chi.URLParam(r, "device"), dto.Visibility)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, devicePolicyDTO{Device: policy.Device, Visibility: policy.Visibility})
+	})
+
+	router.Delete("/admin/devicepolicies/{device}", func(w http.ResponseWriter, r *http.Request) {
+		if err := tenantDB(db, r).DeleteDevicePolicy(chi.URLParam(r, "device")); err != nil {
+			writeDatastoreError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+type calibrationDTO struct {
+	ID        uint    `json:"id"`
+	Device    string  `json:"device"`
+	Offset    float64 `json:"offset"`
+	Gain      float64 `json:"gain"`
+	ValidFrom string  `json:"validFrom"`
+	ValidTo   string  `json:"validTo,omitempty"`
+}
+
+func newCalibrationDTO(c *models.Calibration) calibrationDTO {
+	dto := calibrationDTO{
+		ID:        c.ID,
+		Device:    c.Device,
+		Offset:    c.Offset,
+		Gain:      c.Gain,
+		ValidFrom: c.ValidFrom.UTC().Format(time.RFC3339),
+	}
+
+	if c.ValidTo != nil {
+		dto.ValidTo = c.ValidTo.UTC().Format(time.RFC3339)
+	}
+
+	return dto
+}
+
+func (dto calibrationDTO) toModel() (*models.Calibration, error) {
+	if dto.Device == "" {
+		return nil, errors.New("device is required")
+	}
+
+	if dto.Gain == 0 {
+		// A calibration without a gain is an offset only calibration
+		dto.Gain = 1
+	} else if dto.Gain < 0 {
+		return nil, errors.New("gain must be positive")
+	}
+
+	validFrom, err := time.Parse(time.RFC3339, dto.ValidFrom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid validFrom %s", dto.ValidFrom)
+	}
+
+	c := &models.Calibration{
+		Device:    dto.Device,
+		Offset:    dto.Offset,
+		Gain:      dto.Gain,
+		ValidFrom: validFrom.UTC(),
+	}
+
+	if dto.ValidTo != "" {
+		validTo, err := time.Parse(time.RFC3339, dto.ValidTo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validTo %s", dto.ValidTo)
+		}
+
+		if !validTo.After(validFrom) {
+			return nil, errors.New("validTo must be after validFrom")
+		}
+
+		validTo = validTo.UTC()
+		c.ValidTo = &validTo
+	}
+
+	return c, nil
+}
+
+func decodeCalibration(r *http.Request) (*models.Calibration, error) {
+	dto := calibrationDTO{}
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return nil, errors.New("failed to decode request body")
+	}
+
+	return dto.toModel()
+}
+
+//addCalibrationHandlers registers the handlers that manage the calibrations of devices. New and
+//changed calibrations are only used for new readings until they are applied, which corrects the
+//historical readings within their validity period. Calibrations are managed per tenant.
+func (router *RequestRouter) addCalibrationHandlers(db database.Datastore) {
+	calibrationID := func(w http.ResponseWriter, r *http.Request) (uint, bool) {
+		id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+		if err != nil {
+			http.Error(w, "invalid calibration id", http.StatusBadRequest)
+			return 0, false
+		}
+		return uint(id), true
+	}
+
+	router.Get("/admin/calibrations", func(w http.ResponseWriter, r *http.Request) {
+		calibrations, err := tenantDB(db, r).GetCalibrations(r.URL.Query().Get("device"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		dtos := []calibrationDTO{}
+		for i := range calibrations {
+			dtos = append(dtos, newCalibrationDTO(&calibrations[i]))
+		}
+
+		writeJSON(w, http.StatusOK, dtos)
+	})
+
+	router.Post("/admin/calibrations", func(w http.ResponseWriter, r *http.Request) {
+		calibration, err := decodeCalibration(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		calibration, err = tenantDB(db, r).CreateCalibration(calibration)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, newCalibrationDTO(calibration))
+	})
+
+	router.Put("/admin/calibrations/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := calibrationID(w, r)
+		if !ok {
+			return
+		}
+
+		calibration, err := decodeCalibration(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		calibration, err = tenantDB(db, r).UpdateCalibration(id, calibration)
+		if err != nil {
+			writeDatastoreError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newCalibrationDTO(calibration))
+	})
+
+	router.Delete("/admin/calibrations/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := calibrationID(w, r)
+		if !ok {
+			return
+		}
+
+		if err := tenantDB(db, r).DeleteCalibration(id); err != nil {
+			writeDatastoreError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	router.Post("/admin/calibrations/{id}/apply", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := calibrationID(w, r)
+		if !ok {
+			return
+		}
+
+		updated, err := tenantDB(db, r).ApplyCalibration(id)
+		if err != nil {
+			writeDatastoreError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]int64{"updated": updated})
+	})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	bytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(bytes)
+}
+
+func writeDatastoreError(w http.ResponseWriter, err error) {
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+	} else {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
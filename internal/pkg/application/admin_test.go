@@ -0,0 +1,258 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/diwise/api-temperature/internal/pkg/application/alerting"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/messaging/deadletter"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/database"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/tenancy"
+)
+
+func TestThatAlertRulesAreManagedPerTenant(t *testing.T) {
+	is := is.New(t)
+	router, _, alerts, _ := newTestAdminRouter(is)
+
+	w := serveAdmin(router, http.MethodPost, "/admin/alertrules", "", `{"name":"cold","medium":"air","condition":"sideways"}`)
+	is.Equal(w.Code, http.StatusBadRequest) // rules with an unknown condition should be rejected
+
+	w = serveAdmin(router, http.MethodPost, "/admin/alertrules", "", `{"name":"cold","medium":"air","condition":"below","threshold":-5,"minDuration":"10m","enabled":true}`)
+	is.Equal(w.Code, http.StatusCreated)
+
+	rule := alertRuleDTO{}
+	is.NoErr(json.NewDecoder(w.Body).Decode(&rule))
+	is.Equal(rule.MinDuration, "10m0s")
+	is.Equal(alerts.reloads, 1) // the engine should be told about the new rule
+
+	is.Equal(listAdmin(is, router, "/admin/alertrules", "", nil), 1)
+	is.Equal(listAdmin(is, router, "/admin/alertrules", "other", nil), 0) // rules should not be visible to other tenants
+
+	update := `{"name":"colder","medium":"air","condition":"below","threshold":-10,"enabled":true}`
+
+	w = serveAdmin(router, http.MethodPut, "/admin/alertrules/1", "other", update)
+	is.Equal(w.Code, http.StatusNotFound) // other tenants should not be able to change the rule
+
+	w = serveAdmin(router, http.MethodPut, "/admin/alertrules/one", "", update)
+	is.Equal(w.Code, http.StatusBadRequest)
+
+	w = serveAdmin(router, http.MethodPut, "/admin/alertrules/1", "", update)
+	is.Equal(w.Code, http.StatusOK)
+	is.NoErr(json.NewDecoder(w.Body).Decode(&rule))
+	is.Equal(rule.Threshold, -10.0)
+
+	w = serveAdmin(router, http.MethodDelete, "/admin/alertrules/1", "other", "")
+	is.Equal(w.Code, http.StatusNotFound) // nor delete it
+
+	w = serveAdmin(router, http.MethodDelete, "/admin/alertrules/1", "", "")
+	is.Equal(w.Code, http.StatusNoContent)
+	is.Equal(alerts.reloads, 3) // the engine should be reloaded after every change, but not after failures
+
+	is.Equal(listAdmin(is, router, "/admin/alertrules", "", nil), 0)
+}
+
+func TestThatDevicePoliciesCanBeSetAndRemoved(t *testing.T) {
+	is := is.New(t)
+	router, _, _, _ := newTestAdminRouter(is)
+
+	w := serveAdmin(router, http.MethodPut, "/admin/devicepolicies/sensor", "", `{"visibility":"secret"}`)
+	is.Equal(w.Code, http.StatusBadRequest) // unknown visibilities should be rejected
+
+	w = serveAdmin(router, http.MethodPut, "/admin/devicepolicies/sensor", "", `{"visibility":"restricted"}`)
+	is.Equal(w.Code, http.StatusOK)
+
+	policies := []devicePolicyDTO{}
+	is.Equal(listAdmin(is, router, "/admin/devicepolicies", "", &policies), 1)
+	is.Equal(policies[0], devicePolicyDTO{Device: "sensor", Visibility: models.VisibilityRestricted})
+
+	w = serveAdmin(router, http.MethodDelete, "/admin/devicepolicies/sensor", "other", "")
+	is.Equal(w.Code, http.StatusNotFound) // other tenants should not be able to remove the policy
+
+	w = serveAdmin(router, http.MethodDelete, "/admin/devicepolicies/sensor", "", "")
+	is.Equal(w.Code, http.StatusNoContent)
+
+	w = serveAdmin(router, http.MethodDelete, "/admin/devicepolicies/sensor", "", "")
+	is.Equal(w.Code, http.StatusNotFound) // a removed policy should be gone
+}
+
+func TestThatCalibrationsCanBeAppliedToStoredReadings(t *testing.T) {
+	is := is.New(t)
+	router, db, _, _ := newTestAdminRouter(is)
+
+	_, err := db.ForTenant(models.DefaultTenant).AddTemperatureMeasurement(models.TemperatureV2{
+		Device: "sensor", Temp: 10.0, Timestamp: time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC),
+	})
+	is.NoErr(err)
+
+	w := serveAdmin(router, http.MethodPost, "/admin/calibrations", "", `{"offset":0.5,"validFrom":"2021-01-01T00:00:00Z"}`)
+	is.Equal(w.Code, http.StatusBadRequest) // calibrations without a device should be rejected
+
+	w = serveAdmin(router, http.MethodPost, "/admin/calibrations", "", `{"device":"sensor","offset":0.5,"validFrom":"2021-01-01T00:00:00Z","validTo":"2020-01-01T00:00:00Z"}`)
+	is.Equal(w.Code, http.StatusBadRequest) // as should calibrations that end before they start
+
+	w = serveAdmin(router, http.MethodPost, "/admin/calibrations", "", `{"device":"sensor","offset":0.5,"validFrom":"2021-01-01T00:00:00Z"}`)
+	is.Equal(w.Code, http.StatusCreated)
+
+	calibration := calibrationDTO{}
+	is.NoErr(json.NewDecoder(w.Body).Decode(&calibration))
+	is.Equal(calibration.Gain, 1.0) // an offset only calibration should get a gain of 1
+
+	is.Equal(listAdmin(is, router, "/admin/calibrations?device=sensor", "", nil), 1)
+	is.Equal(listAdmin(is, router, "/admin/calibrations", "other", nil), 0) // calibrations should not be visible to other tenants
+
+	w = serveAdmin(router, http.MethodPut, "/admin/calibrations/1", "", `{"device":"sensor","offset":1.0,"validFrom":"2021-01-01T00:00:00Z"}`)
+	is.Equal(w.Code, http.StatusOK)
+
+	w = serveAdmin(router, http.MethodPost, "/admin/calibrations/1/apply", "other", "")
+	is.Equal(w.Code, http.StatusNotFound) // other tenants should not be able to apply the calibration
+
+	w = serveAdmin(router, http.MethodPost, "/admin/calibrations/1/apply", "", "")
+	is.Equal(w.Code, http.StatusOK)
+
+	applied := map[string]int64{}
+	is.NoErr(json.NewDecoder(w.Body).Decode(&applied))
+	is.Equal(applied["updated"], int64(1)) // the stored reading should be corrected
+
+	temps, err := db.ForTenant(models.DefaultTenant).GetTemperatures("sensor", nil, nil, nil, time.Time{}, time.Time{}, "", 0, 0, 0, 0, 0, 10)
+	is.NoErr(err)
+	is.Equal(temps[0].Temp, 11.0)    // with the updated calibration
+	is.Equal(temps[0].RawTemp, 10.0) // while keeping the reported temperature
+
+	w = serveAdmin(router, http.MethodDelete, "/admin/calibrations/1", "other", "")
+	is.Equal(w.Code, http.StatusNotFound)
+
+	w = serveAdmin(router, http.MethodDelete, "/admin/calibrations/1", "", "")
+	is.Equal(w.Code, http.StatusNoContent)
+
+	w = serveAdmin(router, http.MethodPost, "/admin/calibrations/1/apply", "", "")
+	is.Equal(w.Code, http.StatusNotFound) // a deleted calibration should not be applied
+}
+
+func TestThatTheQualityOfReadingsCanBeChanged(t *testing.T) {
+	is := is.New(t)
+	router, db, _, _ := newTestAdminRouter(is)
+
+	stored, err := db.ForTenant(models.DefaultTenant).AddTemperatureMeasurement(models.TemperatureV2{
+		Device: "sensor", Temp: 85.0, Timestamp: time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC),
+	})
+	is.NoErr(err)
+
+	path := "/admin/temperatures/" + strconv.Itoa(int(stored.ID)) + "/quality"
+
+	w := serveAdmin(router, http.MethodPatch, "/admin/temperatures/first/quality", "", `{"status":"bad"}`)
+	is.Equal(w.Code, http.StatusBadRequest)
+
+	w = serveAdmin(router, http.MethodPatch, path, "", `{"status":"great"}`)
+	is.Equal(w.Code, http.StatusBadRequest) // unknown statuses should be rejected
+
+	w = serveAdmin(router, http.MethodPatch, path, "other", `{"status":"bad"}`)
+	is.Equal(w.Code, http.StatusNotFound) // other tenants should not be able to change the reading
+
+	w = serveAdmin(router, http.MethodPatch, path, "", `{"status":"bad","reason":"sensor in the sun"}`)
+	is.Equal(w.Code, http.StatusOK)
+
+	measurement := models.TemperatureV2{}
+	is.NoErr(json.NewDecoder(w.Body).Decode(&measurement))
+	is.Equal(measurement.Quality, models.QualityBad)
+	is.Equal(measurement.QualityReason, "sensor in the sun")
+
+	w = serveAdmin(router, http.MethodPatch, path, "", `{"status":"good","temp":21.5}`)
+	is.Equal(w.Code, http.StatusOK)
+	is.NoErr(json.NewDecoder(w.Body).Decode(&measurement))
+	is.Equal(measurement.Quality, models.QualityManuallyCorrected) // a corrected reading should be marked as such
+	is.Equal(measurement.Temp, 21.5)
+}
+
+func TestThatDeadLettersCanBeReplayed(t *testing.T) {
+	is := is.New(t)
+	router, _, _, failures := newTestAdminRouter(is)
+
+	failing := true
+	handler := failures.Wrap("telemetry.temperature", func(msg amqp.Delivery, log zerolog.Logger) error {
+		if failing {
+			return permanent(errors.New("not yet"))
+		}
+		return nil
+	})
+
+	handler(amqp.Delivery{RoutingKey: "telemetry.temperature"}, log.Logger)
+
+	deadletters := []deadletter.Message{}
+	is.Equal(listAdmin(is, router, "/admin/deadletters", "", &deadletters), 1)
+	is.Equal(deadletters[0].Reason, "not yet")
+
+	w := serveAdmin(router, http.MethodPost, "/admin/deadletters/unknown/replay", "", "")
+	is.Equal(w.Code, http.StatusNotFound)
+
+	failing = false
+
+	w = serveAdmin(router, http.MethodPost, "/admin/deadletters/"+deadletters[0].ID+"/replay", "", "")
+	is.Equal(w.Code, http.StatusNoContent)
+	is.Equal(len(failures.DeadLetters()), 0) // the replayed message should be removed
+}
+
+type alertEngineStub struct {
+	reloads int
+}
+
+func (e *alertEngineStub) Evaluate(m *models.TemperatureV2) []alerting.Alert {
+	return nil
+}
+
+func (e *alertEngineStub) Reload() error {
+	e.reloads++
+	return nil
+}
+
+func newTestAdminRouter(is *is.I) (*RequestRouter, database.Datastore, *alertEngineStub, *FailureHandler) {
+	db, err := database.NewDatabaseConnection(database.NewSQLiteConnector(log.Logger))
+	is.NoErr(err)
+
+	alerts := &alertEngineStub{}
+	failures, _ := newTestFailureHandler()
+
+	router := newRequestRouter(config.Default(), nil)
+	router.addAdminHandlers(config.Default(), db, failures, alerts)
+
+	return router, db, alerts, failures
+}
+
+//serveAdmin serves a request on behalf of a tenant, or the default tenant if tenant is empty
+func serveAdmin(router *RequestRouter, method, path, tenant, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if tenant != "" {
+		req.Header.Set(tenancy.HeaderName, tenant)
+	}
+
+	w := httptest.NewRecorder()
+	router.impl.ServeHTTP(w, req)
+	return w
+}
+
+//listAdmin gets a list of resources on behalf of a tenant and returns the number of resources
+func listAdmin(is *is.I, router *RequestRouter, path, tenant string, items interface{}) int {
+	w := serveAdmin(router, http.MethodGet, path, tenant, "")
+	is.Equal(w.Code, http.StatusOK)
+
+	list := []json.RawMessage{}
+	is.NoErr(json.Unmarshal(w.Body.Bytes(), &list))
+
+	if items != nil {
+		is.NoErr(json.Unmarshal(w.Body.Bytes(), items))
+	}
+
+	return len(list)
+}
@@ -0,0 +1,166 @@
+package alerting
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+)
+
+//RuleStore is the part of the datastore that the alerting engine depends on
+type RuleStore interface {
+	GetAlertRules() ([]models.AlertRule, error)
+}
+
+//Alert describes a change in the state of an alert rule for a certain device
+type Alert struct {
+	Rule      models.AlertRule
+	Device    string
+	Fired     bool
+	Temp      float64
+	Since     time.Time
+	Timestamp time.Time
+}
+
+//Engine evaluates stored temperatures against the configured alert rules
+type Engine interface {
+	Evaluate(m *models.TemperatureV2) []Alert
+	Reload() error
+}
+
+//NewEngine creates a new alerting engine and loads the current rules from the store. The state
+//of the alerts is only kept in memory and is lost when the service is restarted.
+func NewEngine(store RuleStore) (Engine, error) {
+	e := &engine{
+		store:  store,
+		states: map[stateKey]*state{},
+	}
+
+	return e, e.Reload()
+}
+
+type stateKey struct {
+	rule   uint
+	tenant string
+	device string
+	depth  float64
+}
+
+type state struct {
+	breachedSince time.Time
+	firing        bool
+}
+
+type engine struct {
+	store RuleStore
+
+	mu     sync.Mutex
+	rules  []models.AlertRule
+	states map[stateKey]*state
+}
+
+//Reload replaces the rules of the engine with the rules that are currently in the store.
+//The state of rules that still exist is kept, so that firing alerts are not fired again.
+func (e *engine) Reload() error {
+	rules, err := e.store.GetAlertRules()
+	if err != nil {
+		return fmt.Errorf("failed to load alert rules: %s", err.Error())
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.rules = rules
+
+	for key := range e.states {
+		if !containsRule(rules, key.rule) {
+			delete(e.states, key)
+		}
+	}
+
+	return nil
+}
+
+//Evaluate checks a stored temperature against all rules of its tenant that apply to it and
+//returns the alerts that fired or cleared because of it
+func (e *engine) Evaluate(m *models.TemperatureV2) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := []Alert{}
+	temp := m.Temp
+
+	for _, rule := range e.rules {
+		// Rules only apply to the readings of the tenant that they were created for
+		if rule.Tenant != m.Tenant || !rule.AppliesTo(m.Device, m.Medium) {
+			continue
+		}
+
+		key := stateKey{rule: rule.ID, tenant: m.Tenant, device: m.Device, depth: m.Depth}
+		s, ok := e.states[key]
+		if !ok {
+			s = &state{}
+			e.states[key] = s
+		}
+
+		if breaches(rule, temp) {
+			if s.breachedSince.IsZero() {
+				s.breachedSince = m.Timestamp
+			}
+
+			if !s.firing && m.Timestamp.Sub(s.breachedSince) >= rule.MinDuration {
+				s.firing = true
+				alerts = append(alerts, newAlert(rule, m, true, s.breachedSince))
+			}
+		} else if clears(rule, temp) {
+			if s.firing {
+				alerts = append(alerts, newAlert(rule, m, false, s.breachedSince))
+			}
+
+			s.firing = false
+			s.breachedSince = time.Time{}
+		} else if !s.firing {
+			// Within the hysteresis band an alert that has not fired yet starts over
+			s.breachedSince = time.Time{}
+		}
+	}
+
+	return alerts
+}
+
+func breaches(rule models.AlertRule, temp float64) bool {
+	if rule.Condition == models.AlertConditionBelow {
+		return temp < rule.Threshold
+	}
+
+	return temp > rule.Threshold
+}
+
+func clears(rule models.AlertRule, temp float64) bool {
+	if rule.Condition == models.AlertConditionBelow {
+		return temp >= rule.Threshold+rule.Hysteresis
+	}
+
+	return temp <= rule.Threshold-rule.Hysteresis
+}
+
+func newAlert(rule models.AlertRule, m *models.TemperatureV2, fired bool, since time.Time) Alert {
+	return Alert{
+		Rule:      rule,
+		Device:    m.Device,
+		Fired:     fired,
+		Temp:      m.Temp,
+		Since:     since,
+		Timestamp: m.Timestamp,
+	}
+}
+
+func containsRule(rules []models.AlertRule, id uint) bool {
+	for _, r := range rules {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package alerting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+)
+
+func TestThatAlertFiresAfterMinimumDuration(t *testing.T) {
+	is := is.New(t)
+	e := newEngineWithRules(t, waterAbove(20.0, 0.5, 30*time.Minute))
+
+	start := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	is.Equal(len(e.Evaluate(reading(20.5, models.MediumWater, start))), 0)                     // the alert should not fire immediately
+	is.Equal(len(e.Evaluate(reading(20.7, models.MediumWater, start.Add(15*time.Minute)))), 0) // nor before the minimum duration
+
+	alerts := e.Evaluate(reading(20.9, models.MediumWater, start.Add(30*time.Minute)))
+	is.Equal(len(alerts), 1)         // the alert should fire after the minimum duration
+	is.True(alerts[0].Fired)         // and it should be reported as fired
+	is.Equal(alerts[0].Since, start) // since the first reading above the threshold
+
+	is.Equal(len(e.Evaluate(reading(21.0, models.MediumWater, start.Add(45*time.Minute)))), 0) // a firing alert should not fire again
+}
+
+func TestThatAlertClearsOutsideOfHysteresis(t *testing.T) {
+	is := is.New(t)
+	e := newEngineWithRules(t, waterAbove(20.0, 0.5, 0))
+
+	start := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	is.Equal(len(e.Evaluate(reading(20.2, models.MediumWater, start))), 1)                     // the alert should fire without a minimum duration
+	is.Equal(len(e.Evaluate(reading(19.8, models.MediumWater, start.Add(15*time.Minute)))), 0) // a value within the hysteresis should not clear it
+
+	alerts := e.Evaluate(reading(19.4, models.MediumWater, start.Add(30*time.Minute)))
+	is.Equal(len(alerts), 1)  // a value below the hysteresis should clear the alert
+	is.True(!alerts[0].Fired) // and it should be reported as cleared
+}
+
+func TestThatRulesOnlyApplyToMatchingReadings(t *testing.T) {
+	is := is.New(t)
+
+	rule := models.AlertRule{Tenant: models.DefaultTenant, Name: "ice", Devices: "bridge-1, bridge-2", Medium: models.MediumSurface, Condition: models.AlertConditionBelow, Threshold: 0, Enabled: true}
+	rule.ID = 1
+	e := newEngineWithRules(t, rule)
+
+	now := time.Date(2021, 12, 1, 3, 0, 0, 0, time.UTC)
+
+	is.Equal(len(e.Evaluate(reading(-1.0, models.MediumWater, now))), 0)   // water readings should not match a surface rule
+	is.Equal(len(e.Evaluate(reading(-1.0, models.MediumSurface, now))), 0) // nor readings from other devices
+
+	m := reading(-1.0, models.MediumSurface, now)
+	m.Device = "bridge-2"
+	is.Equal(len(e.Evaluate(m)), 1) // but readings from the listed devices should
+}
+
+func TestThatRulesOnlyApplyToTheReadingsOfTheirTenant(t *testing.T) {
+	is := is.New(t)
+
+	rule := waterAbove(20.0, 0.5, 0)
+	rule.Tenant = "sundsvall"
+	e := newEngineWithRules(t, rule)
+
+	now := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+	is.Equal(len(e.Evaluate(reading(21.0, models.MediumWater, now))), 0) // readings of other tenants should not match the rule
+
+	m := reading(21.0, models.MediumWater, now)
+	m.Tenant = "sundsvall"
+	is.Equal(len(e.Evaluate(m)), 1) // but readings of the tenant of the rule should
+}
+
+type mockStore struct {
+	rules []models.AlertRule
+}
+
+func (s *mockStore) GetAlertRules() ([]models.AlertRule, error) {
+	return s.rules, nil
+}
+
+func newEngineWithRules(t *testing.T, rules ...models.AlertRule) Engine {
+	e, err := NewEngine(&mockStore{rules: rules})
+	if err != nil {
+		t.Fatalf("failed to create engine: %s", err.Error())
+	}
+	return e
+}
+
+func waterAbove(threshold, hysteresis float64, minDuration time.Duration) models.AlertRule {
+	rule := models.AlertRule{
+		Tenant:      models.DefaultTenant,
+		Name:        "beach",
+		Medium:      models.MediumWater,
+		Condition:   models.AlertConditionAbove,
+		Threshold:   threshold,
+		Hysteresis:  hysteresis,
+		MinDuration: minDuration,
+		Enabled:     true,
+	}
+	rule.ID = 1
+	return rule
+}
+
+func reading(temp float64, medium string, when time.Time) *models.TemperatureV2 {
+	return &models.TemperatureV2{Tenant: models.DefaultTenant, Device: "device", Temp: temp, Medium: medium, Timestamp: when}
+}
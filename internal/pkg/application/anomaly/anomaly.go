@@ -0,0 +1,271 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	//Flatline is reported when a device keeps reporting the same value for a long time
+	Flatline = "flatline"
+	//Spike is reported when a single reading deviates a lot from the recent readings
+	Spike = "spike"
+	//Offset is reported when a device suddenly settles on a new level
+	Offset = "offset"
+)
+
+//Sample is a single reading from a device
+type Sample struct {
+	Temp      float64
+	Timestamp time.Time
+}
+
+//Anomaly describes a suspected sensor malfunction
+type Anomaly struct {
+	Device      string
+	Kind        string
+	Description string
+	Temp        float64
+	Expected    float64
+	Since       time.Time
+	Timestamp   time.Time
+}
+
+//Config contains the tuning parameters for the anomaly detector
+type Config struct {
+	//WindowSize is the number of recent samples that are kept per device
+	WindowSize int
+	//MinSamples is the number of samples required before spikes and offsets are reported
+	MinSamples int
+	//FlatlineDuration is how long a value has to stay constant before it is reported
+	FlatlineDuration time.Duration
+	//FlatlineTolerance is the largest change that is still considered constant
+	FlatlineTolerance float64
+	//SpikeThreshold is the modified z-score above which a sample is considered a spike
+	SpikeThreshold float64
+	//MinDeviation is the smallest median absolute deviation used when computing z-scores
+	MinDeviation float64
+	//OffsetSamples is the number of consecutive samples that must agree on a new level
+	OffsetSamples int
+	//OffsetThreshold is the minimum difference, in degrees, between the old and the new level
+	OffsetThreshold float64
+}
+
+//DefaultConfig returns a configuration suitable for sensors reporting a few times per hour
+func DefaultConfig() Config {
+	return Config{
+		WindowSize:        48,
+		MinSamples:        12,
+		FlatlineDuration:  24 * time.Hour,
+		FlatlineTolerance: 0.01,
+		SpikeThreshold:    5,
+		MinDeviation:      0.2,
+		OffsetSamples:     4,
+		OffsetThreshold:   3,
+	}
+}
+
+//Detector keeps track of recent readings per device and reports anomalies
+type Detector interface {
+	Seed(device string, samples []Sample)
+	Observe(device string, sample Sample) []Anomaly
+}
+
+//NewDetector creates a new streaming anomaly detector
+func NewDetector(cfg Config) Detector {
+	return &detector{
+		cfg:     cfg,
+		devices: map[string]*deviceState{},
+	}
+}
+
+type deviceState struct {
+	samples []Sample
+
+	constantSince time.Time
+	flatlined     bool
+	offset        bool
+}
+
+type detector struct {
+	cfg Config
+
+	mu      sync.Mutex
+	devices map[string]*deviceState
+}
+
+//Seed feeds historical samples to the detector without reporting any anomalies
+func (d *detector) Seed(device string, samples []Sample) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, s := range samples {
+		d.observe(device, s)
+	}
+}
+
+//Observe adds a sample to the state of a device and returns any anomalies that
+//started with this sample. Ongoing anomalies are only reported once.
+func (d *detector) Observe(device string, sample Sample) []Anomaly {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.observe(device, sample)
+}
+
+func (d *detector) observe(device string, sample Sample) []Anomaly {
+	state, ok := d.devices[device]
+	if !ok {
+		state = &deviceState{}
+		d.devices[device] = state
+	}
+
+	n := len(state.samples)
+	if n > 0 && !sample.Timestamp.After(state.samples[n-1].Timestamp) {
+		// Late arrivals would only confuse the streaming state, so we ignore them
+		return nil
+	}
+
+	anomalies := []Anomaly{}
+	newAnomaly := func(kind, description string, expected float64, since time.Time) {
+		anomalies = append(anomalies, Anomaly{
+			Device:      device,
+			Kind:        kind,
+			Description: description,
+			Temp:        sample.Temp,
+			Expected:    expected,
+			Since:       since,
+			Timestamp:   sample.Timestamp,
+		})
+	}
+
+	if a, ok := d.checkFlatline(state, sample); ok {
+		newAnomaly(Flatline, a, sample.Temp, state.constantSince)
+	}
+
+	if n >= d.cfg.MinSamples {
+		window := values(state.samples)
+		expected := median(window)
+
+		if a, ok := d.checkSpike(window, expected, sample); ok {
+			newAnomaly(Spike, a, expected, sample.Timestamp)
+		}
+
+		if a, ok := d.checkOffset(state, sample); ok {
+			newAnomaly(Offset, a, expected, state.samples[n-d.cfg.OffsetSamples+1].Timestamp)
+		}
+	}
+
+	state.samples = append(state.samples, sample)
+	if len(state.samples) > d.cfg.WindowSize {
+		state.samples = state.samples[len(state.samples)-d.cfg.WindowSize:]
+	}
+
+	return anomalies
+}
+
+func (d *detector) checkFlatline(state *deviceState, sample Sample) (string, bool) {
+	n := len(state.samples)
+
+	if n == 0 || math.Abs(sample.Temp-state.samples[n-1].Temp) > d.cfg.FlatlineTolerance {
+		state.constantSince = sample.Timestamp
+		state.flatlined = false
+		return "", false
+	}
+
+	if d.cfg.FlatlineDuration <= 0 || state.flatlined {
+		return "", false
+	}
+
+	if sample.Timestamp.Sub(state.constantSince) >= d.cfg.FlatlineDuration {
+		state.flatlined = true
+		return fmt.Sprintf("value has been stuck at %.2f since %s", sample.Temp, state.constantSince.Format(time.RFC3339)), true
+	}
+
+	return "", false
+}
+
+func (d *detector) checkSpike(window []float64, expected float64, sample Sample) (string, bool) {
+	if d.cfg.SpikeThreshold <= 0 {
+		return "", false
+	}
+
+	score := modifiedZScore(window, expected, sample.Temp, d.cfg.MinDeviation)
+	if math.Abs(score) > d.cfg.SpikeThreshold {
+		return fmt.Sprintf("value %.2f deviates from the recent median %.2f (z-score %.1f)", sample.Temp, expected, score), true
+	}
+
+	return "", false
+}
+
+func (d *detector) checkOffset(state *deviceState, sample Sample) (string, bool) {
+	k := d.cfg.OffsetSamples
+	n := len(state.samples)
+
+	if k <= 0 || n < k+d.cfg.MinSamples-1 {
+		return "", false
+	}
+
+	// Compare the level of the latest k samples (including this one) with the level before them
+	recent := append(values(state.samples[n-k+1:]), sample.Temp)
+	before := median(values(state.samples[:n-k+1]))
+	level := median(recent)
+
+	shifted := math.Abs(level-before) > d.cfg.OffsetThreshold
+	for _, v := range recent {
+		if math.Abs(v-before) <= d.cfg.OffsetThreshold || (v > before) != (level > before) {
+			shifted = false
+		}
+	}
+
+	if !shifted {
+		state.offset = false
+		return "", false
+	}
+
+	if state.offset {
+		return "", false
+	}
+
+	state.offset = true
+	return fmt.Sprintf("level changed from %.2f to %.2f over the last %d readings", before, level, k), true
+}
+
+func values(samples []Sample) []float64 {
+	result := make([]float64, 0, len(samples))
+	for _, s := range samples {
+		result = append(result, s.Temp)
+	}
+	return result
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+
+	return sorted[mid]
+}
+
+//modifiedZScore computes a z-score based on the median absolute deviation, which is far
+//less sensitive to the outliers that we are trying to find than the standard deviation
+func modifiedZScore(window []float64, center, value, minDeviation float64) float64 {
+	deviations := make([]float64, 0, len(window))
+	for _, v := range window {
+		deviations = append(deviations, math.Abs(v-center))
+	}
+
+	mad := math.Max(median(deviations), minDeviation)
+	return 0.6745 * (value - center) / mad
+}
@@ -0,0 +1,82 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestThatFlatlinesAreReportedOnce(t *testing.T) {
+	is := is.New(t)
+	d := NewDetector(DefaultConfig())
+
+	start := time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)
+	reported := []Anomaly{}
+
+	for i := 0; i < 4*36; i++ {
+		reported = append(reported, d.Observe("device", Sample{Temp: 4.2, Timestamp: start.Add(time.Duration(i) * 15 * time.Minute)})...)
+	}
+
+	is.Equal(len(reported), 1)           // a flatline should be reported exactly once
+	is.Equal(reported[0].Kind, Flatline) // the anomaly should be a flatline
+	is.Equal(reported[0].Since, start)   // the flatline should start with the first reading
+}
+
+func TestThatSpikesAreReported(t *testing.T) {
+	is := is.New(t)
+	d := NewDetector(DefaultConfig())
+
+	start := time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)
+	d.Seed("device", noisySamples(start, 24, 10.0))
+
+	anomalies := d.Observe("device", Sample{Temp: 25.0, Timestamp: start.Add(24 * time.Hour)})
+
+	is.Equal(len(anomalies), 1)         // a single spike should be reported
+	is.Equal(anomalies[0].Kind, Spike)  // the anomaly should be a spike
+	is.True(anomalies[0].Expected < 11) // the expected value should be close to the recent level
+}
+
+func TestThatNormalVariationIsNotReported(t *testing.T) {
+	is := is.New(t)
+	d := NewDetector(DefaultConfig())
+
+	start := time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)
+	d.Seed("device", noisySamples(start, 24, 10.0))
+
+	anomalies := d.Observe("device", Sample{Temp: 10.4, Timestamp: start.Add(24 * time.Hour)})
+	is.Equal(len(anomalies), 0) // small changes should not be reported
+}
+
+func TestThatOffsetsAreReported(t *testing.T) {
+	is := is.New(t)
+	d := NewDetector(DefaultConfig())
+
+	start := time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)
+	d.Seed("device", noisySamples(start, 24, 10.0))
+
+	offsets := 0
+	for _, s := range noisySamples(start.Add(24*time.Hour), 8, 16.0) {
+		for _, a := range d.Observe("device", s) {
+			if a.Kind == Offset {
+				offsets++
+			}
+		}
+	}
+
+	is.Equal(offsets, 1) // a sudden change of level should be reported once
+}
+
+func noisySamples(start time.Time, count int, level float64) []Sample {
+	noise := []float64{0.1, -0.2, 0.0, 0.2, -0.1}
+	samples := []Sample{}
+
+	for i := 0; i < count; i++ {
+		samples = append(samples, Sample{
+			Temp:      level + noise[i%len(noise)],
+			Timestamp: start.Add(time.Duration(i) * time.Hour),
+		})
+	}
+
+	return samples
+}
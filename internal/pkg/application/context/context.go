@@ -1,48 +1,118 @@
 package context
 
 import (
+	gocontext "context"
 	"errors"
 	"fmt"
 	"math"
+	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/diwise/api-temperature/internal/pkg/application/access"
+	"github.com/diwise/api-temperature/internal/pkg/application/precision"
+	"github.com/diwise/api-temperature/internal/pkg/application/querycache"
+	"github.com/diwise/api-temperature/internal/pkg/application/units"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/auth"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/deviceregistry"
 	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/database"
 	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/tenancy"
 	"github.com/diwise/ngsi-ld-golang/pkg/datamodels/fiware"
 	ngsi "github.com/diwise/ngsi-ld-golang/pkg/ngsi-ld"
-	"github.com/diwise/ngsi-ld-golang/pkg/ngsi-ld/types"
 )
 
 type contextSource struct {
-	db database.Datastore
+	db       database.Datastore
+	cache    *querycache.Cache
+	rounding precision.Rounder
+	devices  *deviceregistry.Client
 }
 
 //CreateSource instantiates and returns a Fiware ContextSource that wraps the provided db interface
 func CreateSource(db database.Datastore) ngsi.ContextSource {
-	return &contextSource{db: db}
+	return CreateCachedSource(db, nil, precision.Default, nil)
 }
 
-func convertDatabaseRecordToWaterQualityObserved(r *models.TemperatureV2) *fiware.WaterQualityObserved {
+//CreateCachedSource instantiates and returns a Fiware ContextSource that wraps the provided db interface,
+//keeps the results of recent queries in the provided cache, rounds the temperatures it presents with
+//the provided rounder and adds the metadata that the device registry has about the devices, if any
+func CreateCachedSource(db database.Datastore, cache *querycache.Cache, rounding precision.Rounder, devices *deviceregistry.Client) ngsi.ContextSource {
+	return &contextSource{db: db, cache: cache, rounding: rounding, devices: devices}
+}
+
+//entityTypes maps each medium to the entity type that its readings are presented as
+var entityTypes = map[string]string{
+	models.MediumAir:     "WeatherObserved",
+	models.MediumWater:   "WaterQualityObserved",
+	models.MediumSoil:    soilTemperatureObservedType,
+	models.MediumSurface: roadSurfaceObservedType,
+	models.MediumIndoor:  indoorEnvironmentObservedType,
+}
+
+func convertDatabaseRecordToEntity(r *models.TemperatureV2, p presentation) ngsi.Entity {
+	switch r.Medium {
+	case models.MediumWater:
+		return convertDatabaseRecordToWaterQualityObserved(r, p)
+	case models.MediumSoil, models.MediumSurface, models.MediumIndoor:
+		return convertDatabaseRecordToTemperatureObserved(entityTypes[r.Medium], r, p)
+	}
+
+	return convertDatabaseRecordToWeatherObserved(r, p)
+}
+
+func convertDatabaseRecordToWaterQualityObserved(r *models.TemperatureV2, p presentation) *waterQualityObserved {
+	if r != nil {
+		entity := &waterQualityObserved{
+			WaterQualityObserved: *fiware.NewWaterQualityObserved("temperature:"+r.Device, r.Latitude, r.Longitude, r.Timestamp.Format(time.RFC3339)),
+		}
+		entity.ID = entityIDWithDepth(entity.ID, r)
+		entity.Depth = newDepthProperty(r)
+		entity.deviceMetadata = newDeviceMetadata(r, p)
+		entity.Temperature = newTemperatureProperty(r, p)
+		return entity
+	}
+
+	return nil
+}
+
+func convertDatabaseRecordToWeatherObserved(r *models.TemperatureV2, p presentation) *weatherObserved {
 	if r != nil {
-		entity := fiware.NewWaterQualityObserved("temperature:"+r.Device, r.Latitude, r.Longitude, r.Timestamp.Format(time.RFC3339))
-		entity.Temperature = types.NewNumberProperty(math.Round(float64(r.Temp*10)) / 10)
+		entity := &weatherObserved{
+			WeatherObserved: *fiware.NewWeatherObserved("temperature:"+r.Device, r.Latitude, r.Longitude, r.Timestamp.Format(time.RFC3339)),
+		}
+		entity.deviceMetadata = newDeviceMetadata(r, p)
+		entity.Temperature = newTemperatureProperty(r, p)
 		return entity
 	}
 
 	return nil
 }
 
-func convertDatabaseRecordToWeatherObserved(r *models.TemperatureV2) *fiware.WeatherObserved {
+func convertDatabaseRecordToTemperatureObserved(typeName string, r *models.TemperatureV2, p presentation) *temperatureObserved {
 	if r != nil {
-		entity := fiware.NewWeatherObserved("temperature:"+r.Device, r.Latitude, r.Longitude, r.Timestamp.Format(time.RFC3339))
-		entity.Temperature = types.NewNumberProperty(math.Round(float64(r.Temp*10)) / 10)
+		entity := newTemperatureObserved(typeName, "temperature:"+r.Device, r.Latitude, r.Longitude, r.Timestamp.Format(time.RFC3339))
+		entity.ID = entityIDWithDepth(entity.ID, r)
+		entity.Depth = newDepthProperty(r)
+		entity.deviceMetadata = newDeviceMetadata(r, p)
+		entity.Temperature = newTemperatureProperty(r, p)
 		return entity
 	}
 
 	return nil
 }
 
+//entityIDWithDepth makes the ids of readings from several depths at the same time unique
+func entityIDWithDepth(id string, r *models.TemperatureV2) string {
+	if r.Depth == 0 {
+		return id
+	}
+
+	return fmt.Sprintf("%s:%gm", id, r.Depth)
+}
+
 func (cs contextSource) CreateEntity(typeName, entityID string, req ngsi.Request) error {
 	return errors.New("create entity not supported for type " + typeName)
 }
@@ -56,39 +126,93 @@ func (cs contextSource) GetEntities(query ngsi.Query, callback ngsi.QueryEntitie
 		return errors.New("GetEntities: query may not be nil")
 	}
 
-	includeAirTemperature := false
-	includeWaterTemperature := false
+	includedMediums := map[string]bool{}
 
 	for _, typeName := range query.EntityTypes() {
-		if typeName == "WeatherObserved" {
-			includeAirTemperature = true
-		} else if typeName == "WaterQualityObserved" {
-			includeWaterTemperature = true
+		for medium, entityType := range entityTypes {
+			if typeName == entityType {
+				includedMediums[medium] = true
+			}
 		}
 	}
 
-	if !includeAirTemperature && !includeWaterTemperature {
+	if len(includedMediums) == 0 {
 		// No provided type specified, but maybe the caller specified an attribute list instead?
 		if queriedAttributesDoNotInclude(query.EntityAttributes(), "temperature") {
 			return errors.New("GetEntities called without specifying a type that is provided by this service")
 		}
 
-		// Include both entity types as they both hold a temperature value
-		includeAirTemperature = true
-		includeWaterTemperature = true
+		// Include all entity types as they all hold a temperature value
+		for medium := range entityTypes {
+			includedMediums[medium] = true
+		}
+	}
+
+	db := cs.db
+	ctx := gocontext.Background()
+
+	if query.Request() != nil {
+		// Queries should be traced as part of the request that they were made for
+		ctx = query.Request().Context()
+		db = db.WithContext(ctx)
+	}
+
+	tenant := tenancy.FromContext(ctx)
+	db = db.ForTenant(tenant)
+
+	// Devices that the caller may not see are excluded by the query, so that they are not counted
+	// when the results are paged
+	hidden, err := access.HiddenDevices(ctx, db)
+	if err != nil {
+		return err
 	}
+	db = db.WithoutDevices(hidden)
 
-	temperatures, err = getTemperatures(cs.db, query)
+	tq, err := newTemperatureQuery(query)
 	if err != nil {
-		return fmt.Errorf("something went wrong when retrieving temperatures from database: %s", err.Error())
+		return err
+	}
+
+	raw, err := wantsRawTemperatures(query.Request())
+	if err != nil {
+		return err
+	}
+
+	unit, err := getRequestedUnit(query.Request())
+	if err != nil {
+		return err
+	}
+
+	mediums := []string{}
+	for medium := range includedMediums {
+		mediums = append(mediums, medium)
+	}
+	sort.Strings(mediums)
+
+	// Only the readings of the included mediums are queried, so results are cached per set of
+	// mediums and are not invalidated by the readings of other mediums
+	cacheKey := fmt.Sprintf("ngsi-ld|%s|%s|hidden=%s|%s", tenant, strings.Join(mediums, ","), strings.Join(hidden, ","), tq.key())
+
+	temperatures, found := cs.cache.Get(cacheKey)
+	if !found {
+		temperatures, err = tq.run(db, mediums)
+		if err != nil {
+			return fmt.Errorf("something went wrong when retrieving temperatures from database: %s", err.Error())
+		}
+
+		cs.cache.Put(cacheKey, tenant, mediums, temperatures)
 	}
 
 	if err == nil {
 		for _, v := range temperatures {
-			if !v.Water && includeAirTemperature {
-				err = callback(convertDatabaseRecordToWeatherObserved(&v))
-			} else if v.Water && includeWaterTemperature {
-				err = callback(convertDatabaseRecordToWaterQualityObserved(&v))
+			// The devices of a tenant are cached, so a device that only another instance has stored
+			// readings for may not have been hidden by the query. Its readings are dropped here instead.
+			if auth.MayReadDevice(ctx, v.Device) {
+				if raw {
+					// v is a copy, so the cached reading keeps its calibrated temperature
+					v.Temp = v.RawTemp
+				}
+				err = callback(convertDatabaseRecordToEntity(&v, presentation{unit: unit, rounding: cs.rounding, devices: cs.devices, tenant: tenant}))
 			}
 			if err != nil {
 				break
@@ -108,12 +232,23 @@ func (cs contextSource) ProvidesAttribute(attributeName string) bool {
 }
 
 func (cs contextSource) ProvidesEntitiesWithMatchingID(entityID string) bool {
-	return strings.HasPrefix(entityID, "urn:ngsi-ld:WeatherObserved:") ||
-		strings.HasPrefix(entityID, "urn:ngsi-ld:WaterQualityObserved:")
+	for _, typeName := range entityTypes {
+		if strings.HasPrefix(entityID, "urn:ngsi-ld:"+typeName+":") {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (cs contextSource) ProvidesType(typeName string) bool {
-	return typeName == "WeatherObserved" || typeName == "WaterQualityObserved"
+	for _, entityType := range entityTypes {
+		if typeName == entityType {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (cs contextSource) RetrieveEntity(entityID string, request ngsi.Request) (ngsi.Entity, error) {
@@ -124,21 +259,49 @@ func (cs contextSource) UpdateEntityAttributes(entityID string, req ngsi.Request
 	return errors.New("UpdateEntityAttributes is not supported by this service")
 }
 
-func getTemperatures(db database.Datastore, query ngsi.Query) ([]models.TemperatureV2, error) {
-	deviceID := ""
+//temperatureQuery holds the normalized parameters of an NGSI-LD query for temperatures
+type temperatureQuery struct {
+	device   string
+	quality  []string
+	depth    *database.DepthRange
+	from, to time.Time
+	recent   bool // the default window, which ends when the query is run
+	geoRel   string
+	nwLat    float64
+	nwLon    float64
+	seLat    float64
+	seLon    float64
+	offset   uint64
+	limit    uint64
+}
+
+func newTemperatureQuery(query ngsi.Query) (*temperatureQuery, error) {
+	tq := &temperatureQuery{
+		offset: query.PaginationOffset(),
+		limit:  query.PaginationLimit(),
+	}
+
 	if query.HasDeviceReference() && query.Device() != "" {
-		deviceID = strings.TrimPrefix(query.Device(), fiware.DeviceIDPrefix)
+		tq.device = strings.TrimPrefix(query.Device(), fiware.DeviceIDPrefix)
 	}
 
-	// get temperatures from past 24 hours by default
-	from := time.Now().UTC().AddDate(0, 0, -1)
-	to := time.Now().UTC()
 	if query.IsTemporalQuery() {
-		from, to = query.Temporal().TimeSpan()
+		tq.from, tq.to = query.Temporal().TimeSpan()
+	} else {
+		tq.recent = true
 	}
 
-	offset := query.PaginationOffset()
-	limit := query.PaginationLimit()
+	var err error
+
+	tq.quality, err = getQualityFilter(query.Request())
+	if err != nil {
+		return nil, err
+	}
+
+	tq.depth, err = getDepthFilter(query.Request())
+	if err != nil {
+		return nil, err
+	}
 
 	if query.IsGeoQuery() {
 		geo := query.Geo()
@@ -146,19 +309,179 @@ func getTemperatures(db database.Datastore, query ngsi.Query) ([]models.Temperat
 			lon, lat, _ := geo.Point()
 			distance, _ := geo.Distance()
 
-			nw_lat, nw_lon, se_lat, se_lon := getApproximatePoint(lat, lon, uint64(distance))
-
-			return db.GetTemperatures(deviceID, from, to, geo.GeoRel, nw_lat, nw_lon, se_lat, se_lon, offset, limit)
+			tq.geoRel = geo.GeoRel
+			tq.nwLat, tq.nwLon, tq.seLat, tq.seLon = getApproximatePoint(lat, lon, uint64(distance))
 		} else if geo.GeoRel == ngsi.GeoSpatialRelationWithinRect {
-			nw_lat, nw_lon, se_lat, se_lon, err := geo.Rectangle()
+			tq.geoRel = geo.GeoRel
+			tq.nwLat, tq.nwLon, tq.seLat, tq.seLon, err = geo.Rectangle()
 			if err != nil {
 				return nil, err
 			}
-			return db.GetTemperatures(deviceID, from, to, geo.GeoRel, nw_lat, nw_lon, se_lat, se_lon, offset, limit)
 		}
 	}
 
-	return db.GetTemperatures(deviceID, from, to, "", 0.0, 0.0, 0.0, 0.0, offset, limit)
+	return tq, nil
+}
+
+//key returns a string that is the same for all queries that would return the same temperatures,
+//if they were run at the same time
+func (tq *temperatureQuery) key() string {
+	quality := append([]string{}, tq.quality...)
+	sort.Strings(quality)
+
+	window := "recent"
+	if !tq.recent {
+		window = tq.from.UTC().Format(time.RFC3339Nano) + "/" + tq.to.UTC().Format(time.RFC3339Nano)
+	}
+
+	depth := ""
+	if tq.depth != nil {
+		depth = fmt.Sprintf("%g/%g", tq.depth.Min, tq.depth.Max)
+	}
+
+	return fmt.Sprintf("device=%s;quality=%s;depth=%s;window=%s;geo=%s(%g,%g,%g,%g);offset=%d;limit=%d",
+		tq.device, strings.Join(quality, ","), depth, window, tq.geoRel, tq.nwLat, tq.nwLon, tq.seLat, tq.seLon, tq.offset, tq.limit)
+}
+
+//run queries the readings of the provided mediums that match the query
+func (tq *temperatureQuery) run(db database.Datastore, mediums []string) ([]models.TemperatureV2, error) {
+	from, to := tq.from, tq.to
+
+	if tq.recent {
+		// get temperatures from past 24 hours by default
+		from = time.Now().UTC().AddDate(0, 0, -1)
+		to = time.Now().UTC()
+	}
+
+	return db.GetTemperatures(tq.device, mediums, tq.quality, tq.depth, from, to, tq.geoRel, tq.nwLat, tq.nwLon, tq.seLat, tq.seLon, tq.offset, tq.limit)
+}
+
+//wantsRawTemperatures returns true if the calibration parameter of the request asks for the
+//temperatures as they were reported by the devices, rather than the calibrated temperatures
+func wantsRawTemperatures(req *http.Request) (bool, error) {
+	if req == nil {
+		return false, nil
+	}
+
+	switch calibration := req.URL.Query().Get("calibration"); calibration {
+	case "", "corrected":
+		return false, nil
+	case "raw":
+		return true, nil
+	default:
+		return false, fmt.Errorf("calibration must be either raw or corrected, not %s", calibration)
+	}
+}
+
+//getRequestedUnit returns the unit that the unit parameter of the request asks for the
+//temperatures to be presented in, or Celsius if the request does not ask for a unit
+func getRequestedUnit(req *http.Request) (string, error) {
+	if req == nil {
+		return units.Celsius, nil
+	}
+
+	return units.Parse(req.URL.Query().Get("unit"))
+}
+
+//getQualityFilter looks for a quality control filter, such as temperature.quality=="good",
+//in the q parameter of the request and returns the requested statuses
+func getQualityFilter(req *http.Request) ([]string, error) {
+	const qualityPrefix string = "temperature.quality=="
+
+	if req == nil {
+		return nil, nil
+	}
+
+	for _, term := range strings.Split(req.URL.Query().Get("q"), ";") {
+		if !strings.HasPrefix(term, qualityPrefix) {
+			continue
+		}
+
+		quality := []string{}
+		for _, value := range strings.Split(strings.TrimPrefix(term, qualityPrefix), ",") {
+			value = strings.Trim(value, "\"")
+			if !models.IsValidQuality(value) {
+				return nil, fmt.Errorf("unknown quality status %s in query", value)
+			}
+			quality = append(quality, value)
+		}
+
+		return quality, nil
+	}
+
+	return nil, nil
+}
+
+//getDepthFilter looks for depth filters, such as depth>=1.5;depth<=5, in the q parameter of
+//the request and returns the requested range of depths. Depths can only be compared with the
+//==, >= and <= operators.
+func getDepthFilter(req *http.Request) (*database.DepthRange, error) {
+	if req == nil {
+		return nil, nil
+	}
+
+	var depth *database.DepthRange
+
+	for _, term := range strings.Split(req.URL.Query().Get("q"), ";") {
+		attribute := term
+		if i := strings.IndexAny(term, "=!<>~"); i >= 0 {
+			attribute = term[:i]
+		}
+
+		if attribute != "depth" {
+			if strings.HasPrefix(attribute, "depth") {
+				return nil, fmt.Errorf("unknown attribute %s in query term %s", attribute, term)
+			}
+			continue
+		}
+
+		op := term[len(attribute):]
+		if len(op) > 2 {
+			op = op[:2]
+		}
+
+		if op != "==" && op != ">=" && op != "<=" {
+			return nil, fmt.Errorf("unsupported depth filter %s, depths can only be compared with ==, >= or <=", term)
+		}
+
+		value, err := strconv.ParseFloat(term[len(attribute)+len(op):], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid depth in query term %s", term)
+		}
+
+		if depth == nil {
+			depth = &database.DepthRange{Min: 0, Max: math.MaxFloat64}
+		}
+
+		if op != "<=" {
+			depth.Min = value
+		}
+
+		if op != ">=" {
+			depth.Max = value
+		}
+	}
+
+	return depth, nil
+}
+
+//ValidateRequest returns an error if the parameters of a request for entities ask for temperatures
+//in a way that is not supported, so that the request can be rejected before it is handled
+func ValidateRequest(req *http.Request) error {
+	if _, err := getQualityFilter(req); err != nil {
+		return err
+	}
+
+	if _, err := getDepthFilter(req); err != nil {
+		return err
+	}
+
+	if _, err := wantsRawTemperatures(req); err != nil {
+		return err
+	}
+
+	_, err := getRequestedUnit(req)
+	return err
 }
 
 func queriedAttributesDoNotInclude(attributes []string, requiredAttribute string) bool {
@@ -1,19 +1,29 @@
 package context_test
 
 import (
+	gocontext "context"
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/diwise/api-temperature/internal/pkg/application/context"
+	"github.com/diwise/api-temperature/internal/pkg/application/precision"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/auth"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/deviceregistry"
 	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/database"
 	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
 	ngsi "github.com/diwise/ngsi-ld-golang/pkg/ngsi-ld"
 )
 
-const inTheWater bool = true
-const inTheAir bool = false
+const inTheWater string = models.MediumWater
+const inTheAir string = models.MediumAir
+const inTheClassroom string = models.MediumIndoor
 
 var db database.Datastore
 
@@ -55,6 +65,32 @@ func TestGetWeatherObservedEntities(t *testing.T) {
 	}
 }
 
+func TestGetIndoorEnvironmentObservedEntities(t *testing.T) {
+	src := context.CreateSource(createMockedDB(
+		createTempRecord(21.4, inTheClassroom, "2020-10-26T21:51:13Z"),
+		createTempRecord(12.4, inTheAir, "2020-10-26T21:51:13Z"),
+	))
+
+	entities := []ngsi.Entity{}
+	callback := func(e ngsi.Entity) error {
+		entities = append(entities, e)
+		return nil
+	}
+
+	if err := src.GetEntities(newMockQueryForTypes([]string{"IndoorEnvironmentObserved"}), callback); err != nil {
+		t.Error("Unexpected error when calling GetEntities. ", err.Error())
+	}
+
+	if len(entities) != 1 {
+		t.Fatal("Unexpected number of callbacks made. ", len(entities), " != ", 1)
+	}
+
+	entityJSON, _ := json.Marshal(entities[0])
+	if !strings.Contains(string(entityJSON), `"type":"IndoorEnvironmentObserved"`) {
+		t.Error("Expected an IndoorEnvironmentObserved entity, but got ", string(entityJSON))
+	}
+}
+
 func TestGetWaterQualityObservedEntities(t *testing.T) {
 	src := context.CreateSource(db)
 
@@ -131,6 +167,250 @@ func TestGetEntitiesWithDeviceQuery(t *testing.T) {
 	}
 }
 
+func TestThatTemperatureQualityIsIncludedInEntities(t *testing.T) {
+	record := createTempRecord(31.7, inTheWater, "2020-10-26T21:53:21Z")
+	record.Quality = models.QualitySuspect
+	src := context.CreateSource(createMockedDB(record))
+
+	var entityJSON []byte
+	callback := func(e ngsi.Entity) error {
+		entityJSON, _ = json.Marshal(e)
+		return nil
+	}
+
+	if err := src.GetEntities(newMockQueryForTypes([]string{"WaterQualityObserved"}), callback); err != nil {
+		t.Error("Unexpected error when calling GetEntities. ", err.Error())
+	}
+
+	if !strings.Contains(string(entityJSON), `"quality":{"type":"Property","value":"suspect"}`) {
+		t.Error("Expected temperature quality in entity, but got ", string(entityJSON))
+	}
+}
+
+func TestThatDepthIsIncludedInEntities(t *testing.T) {
+	record := createTempRecord(14.1, inTheWater, "2020-07-01T12:00:00Z")
+	record.Device = "buoy"
+	record.Depth = 5
+	src := context.CreateSource(createMockedDB(record))
+
+	var entityJSON []byte
+	callback := func(e ngsi.Entity) error {
+		entityJSON, _ = json.Marshal(e)
+		return nil
+	}
+
+	if err := src.GetEntities(newMockQueryForTypes([]string{"WaterQualityObserved"}), callback); err != nil {
+		t.Error("Unexpected error when calling GetEntities. ", err.Error())
+	}
+
+	if !strings.Contains(string(entityJSON), `"depth":{"type":"Property","value":5}`) {
+		t.Error("Expected depth in entity, but got ", string(entityJSON))
+	}
+
+	if !strings.Contains(string(entityJSON), `:2020-07-01T12:00:00Z:5m"`) {
+		t.Error("Expected depth in entity id, but got ", string(entityJSON))
+	}
+}
+
+func TestThatRestrictedDevicesAreHiddenFromPublicCallers(t *testing.T) {
+	classroom := createTempRecord(21.4, inTheClassroom, "2020-10-26T21:51:13Z")
+	classroom.Device = "school-17"
+	lake := createTempRecord(3.1, inTheWater, "2020-10-26T21:53:21Z")
+	lake.Device = "lake-1"
+
+	db := createMockedDB(classroom, lake)
+	db.SetDevicePolicy("school-17", models.VisibilityRestricted)
+	src := context.CreateSource(db)
+
+	devices := func(query mockQuery) []string {
+		result := []string{}
+		src.GetEntities(query, func(e ngsi.Entity) error {
+			entityJSON, _ := json.Marshal(e)
+			result = append(result, string(entityJSON))
+			return nil
+		})
+		return result
+	}
+
+	types := []string{"IndoorEnvironmentObserved", "WaterQualityObserved"}
+
+	public := devices(newMockQueryForTypes(types))
+	if len(public) != 1 || !strings.Contains(public[0], "lake-1") {
+		t.Error("Expected only the public device to be visible to public callers, but got ", public)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/ngsi-ld/v1/entities", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "staff"}))
+
+	staff := devices(mockQuery{types: types, request: req})
+	if len(staff) != 2 {
+		t.Error("Expected both devices to be visible to authenticated callers, but got ", staff)
+	}
+}
+
+func TestThatRawTemperaturesCanBeRequested(t *testing.T) {
+	record := createTempRecord(12.2, inTheWater, "2020-10-26T21:53:21Z")
+	record.RawTemp = 12.7
+	src := context.CreateSource(createMockedDB(record))
+
+	var entityJSON []byte
+	callback := func(e ngsi.Entity) error {
+		entityJSON, _ = json.Marshal(e)
+		return nil
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/ngsi-ld/v1/entities?calibration=raw", nil)
+	query := mockQuery{types: []string{"WaterQualityObserved"}, request: req}
+
+	if err := src.GetEntities(query, callback); err != nil {
+		t.Error("Unexpected error when calling GetEntities. ", err.Error())
+	}
+
+	if !strings.Contains(string(entityJSON), `"value":12.7`) {
+		t.Error("Expected the raw temperature in entity, but got ", string(entityJSON))
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/ngsi-ld/v1/entities?calibration=both", nil)
+	if src.GetEntities(mockQuery{types: []string{"WaterQualityObserved"}, request: req}, callback) == nil {
+		t.Error("Expected an error for an unknown calibration selection")
+	}
+}
+
+func TestThatTemperaturesCanBeRequestedInOtherUnits(t *testing.T) {
+	src := context.CreateSource(createMockedDB(createTempRecord(21.5, inTheWater, "2020-10-26T21:53:21Z")))
+
+	var entityJSON []byte
+	callback := func(e ngsi.Entity) error {
+		entityJSON, _ = json.Marshal(e)
+		return nil
+	}
+
+	src.GetEntities(newMockQueryForTypes([]string{"WaterQualityObserved"}), callback)
+	if !strings.Contains(string(entityJSON), `"value":21.5,"unitCode":"CEL"`) {
+		t.Error("Expected the temperature in Celsius by default, but got ", string(entityJSON))
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/ngsi-ld/v1/entities?unit=FAH", nil)
+	if err := src.GetEntities(mockQuery{types: []string{"WaterQualityObserved"}, request: req}, callback); err != nil {
+		t.Error("Unexpected error when calling GetEntities. ", err.Error())
+	}
+
+	if !strings.Contains(string(entityJSON), `"value":70.7,"unitCode":"FAH"`) {
+		t.Error("Expected the temperature in Fahrenheit, but got ", string(entityJSON))
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/ngsi-ld/v1/entities?unit=rankine", nil)
+	if src.GetEntities(mockQuery{types: []string{"WaterQualityObserved"}, request: req}, callback) == nil {
+		t.Error("Expected an error for an unknown unit")
+	}
+}
+
+func TestThatTemperaturesAreRoundedToTheResolutionOfTheSensor(t *testing.T) {
+	record := createTempRecord(21.37, inTheWater, "2020-10-26T21:53:21Z")
+	record.Resolution = 0.5
+
+	var entityJSON []byte
+	callback := func(e ngsi.Entity) error {
+		entityJSON, _ = json.Marshal(e)
+		return nil
+	}
+
+	src := context.CreateCachedSource(createMockedDB(record), nil, precision.NewRounder(config.Rounding{Decimals: 1, ByResolution: true}), nil)
+	src.GetEntities(newMockQueryForTypes([]string{"WaterQualityObserved"}), callback)
+	if !strings.Contains(string(entityJSON), `"value":21.5,"unitCode":"CEL"`) {
+		t.Error("Expected the temperature to be rounded to the resolution of the sensor, but got ", string(entityJSON))
+	}
+
+	src = context.CreateCachedSource(createMockedDB(record), nil, precision.NewRounder(config.Rounding{Decimals: 2}), nil)
+	src.GetEntities(newMockQueryForTypes([]string{"WaterQualityObserved"}), callback)
+	if !strings.Contains(string(entityJSON), `"value":21.37,"unitCode":"CEL"`) {
+		t.Error("Expected the temperature to be rounded to two decimals, but got ", string(entityJSON))
+	}
+}
+
+func TestThatEntitiesAreEnrichedWithDeviceMetadata(t *testing.T) {
+	registry := httptest.NewServer(deviceregistry.NewStub(deviceregistry.Device{
+		ID: "brygga", Name: "Brygga 2", Owner: "Sundsvalls kommun", Site: "Norra stadsfjärden", Model: "Elsys ELT Lite",
+	}))
+	defer registry.Close()
+
+	devices := deviceregistry.NewClient(config.DeviceRegistry{URL: registry.URL, Tenants: []string{models.DefaultTenant}, Timeout: time.Second})
+	if err := devices.Refresh(gocontext.Background()); err != nil {
+		t.Fatal("Failed to refresh devices from the stub registry. ", err.Error())
+	}
+
+	record := createTempRecord(12.7, inTheWater, "2020-10-26T21:53:21Z")
+	record.Device = "brygga"
+
+	unknown := createTempRecord(12.7, inTheAir, "2020-10-26T21:53:21Z")
+	unknown.Device = "unknown"
+
+	entities := []string{}
+	callback := func(e ngsi.Entity) error {
+		entityJSON, _ := json.Marshal(e)
+		entities = append(entities, string(entityJSON))
+		return nil
+	}
+
+	src := context.CreateCachedSource(createMockedDB(record, unknown), nil, precision.Default, devices)
+	src.GetEntities(newMockQueryForTypes([]string{"WaterQualityObserved", "WeatherObserved"}), callback)
+
+	if len(entities) != 2 {
+		t.Fatalf("Expected two entities, but got %d", len(entities))
+	}
+
+	for _, expected := range []string{`"deviceName":{"type":"Property","value":"Brygga 2"}`, `"deviceOwner"`, `"deviceSite"`, `"deviceModel":{"type":"Property","value":"Elsys ELT Lite"}`} {
+		if !strings.Contains(entities[0]+entities[1], expected) {
+			t.Errorf("Expected the entity to contain %s, but got %s", expected, entities)
+		}
+	}
+
+	for _, e := range entities {
+		if strings.Contains(e, "WeatherObserved") && strings.Contains(e, "deviceName") {
+			t.Error("Expected no device metadata for a device that the registry does not know, but got ", e)
+		}
+	}
+}
+
+func TestThatTemperaturesCanBeFilteredByDepth(t *testing.T) {
+	db := &mockDB{temps: []models.TemperatureV2{createTempRecord(4.5, inTheWater, "2020-10-26T21:53:21Z")}}
+	src := context.CreateSource(db)
+
+	req, _ := http.NewRequest(http.MethodGet, "/ngsi-ld/v1/entities?q="+url.QueryEscape("depth>=1.5;depth<=5"), nil)
+	if err := src.GetEntities(mockQuery{types: []string{"WaterQualityObserved"}, request: req}, func(ngsi.Entity) error { return nil }); err != nil {
+		t.Error("Unexpected error when calling GetEntities. ", err.Error())
+	}
+
+	if db.depth == nil || db.depth.Min != 1.5 || db.depth.Max != 5 {
+		t.Errorf("Expected temperatures between 1.5 and 5 meters deep to be requested, but got %v", db.depth)
+	}
+
+	for _, q := range []string{"depth>5", "depth<5", "depth!=2", "depth~=2", "depth", "depthX", "depthX==2", "depth==deep"} {
+		req, _ := http.NewRequest(http.MethodGet, "/ngsi-ld/v1/entities?q="+url.QueryEscape(q), nil)
+		if context.ValidateRequest(req) == nil {
+			t.Errorf("Expected the unsupported depth filter %s to be rejected", q)
+		}
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/ngsi-ld/v1/entities?q="+url.QueryEscape("depth==2;temperature.quality==good"), nil)
+	if err := context.ValidateRequest(req); err != nil {
+		t.Error("Expected a supported depth filter to be accepted, but got ", err.Error())
+	}
+}
+
+func TestThatOnlyTheMediumsOfTheRequestedTypesAreQueried(t *testing.T) {
+	db := &mockDB{}
+	src := context.CreateSource(db)
+
+	if err := src.GetEntities(newMockQueryForTypes([]string{"WaterQualityObserved"}), func(ngsi.Entity) error { return nil }); err != nil {
+		t.Error("Unexpected error when calling GetEntities. ", err.Error())
+	}
+
+	if len(db.mediums) != 1 || db.mediums[0] != models.MediumWater {
+		t.Errorf("Expected only water temperatures to be requested, but got %v", db.mediums)
+	}
+}
+
 func TestGetEntitiesOfUnknownTypeReturnsError(t *testing.T) {
 	src := context.CreateSource(nil)
 	if src.GetEntities(newMockQueryForTypes([]string{"UnknownType"}), nil) == nil {
@@ -139,7 +419,10 @@ func TestGetEntitiesOfUnknownTypeReturnsError(t *testing.T) {
 }
 
 type mockDB struct {
-	temps []models.TemperatureV2
+	temps    []models.TemperatureV2
+	policies []models.DevicePolicy
+	depth    *database.DepthRange
+	mediums  []string
 }
 
 func createMockedDB(records ...models.TemperatureV2) database.Datastore {
@@ -148,18 +431,138 @@ func createMockedDB(records ...models.TemperatureV2) database.Datastore {
 	return db
 }
 
-func (db *mockDB) AddTemperatureMeasurement(device *string, latitude, longitude, temp float64, water bool, when string) (*models.TemperatureV2, error) {
+func (db *mockDB) AddTemperatureMeasurement(measurement models.TemperatureV2) (*models.TemperatureV2, error) {
+	return nil, nil
+}
+
+func (db *mockDB) ImportTemperatureMeasurements(measurements []models.TemperatureV2) ([]error, error) {
+	return make([]error, len(measurements)), nil
+}
+
+func (db *mockDB) GetTemperatures(deviceId string, mediums, quality []string, depth *database.DepthRange, from, to time.Time, geoSpatial string, lon0, lat0, lon1, lat1 float64, resultOffset, resultLimit uint64) ([]models.TemperatureV2, error) {
+	db.depth = depth
+	db.mediums = mediums
+
+	temps := []models.TemperatureV2{}
+	for _, t := range db.temps {
+		for _, m := range mediums {
+			if t.Medium == m {
+				temps = append(temps, t)
+			}
+		}
+	}
+	return temps, nil
+}
+
+func (db *mockDB) GetDevices() ([]string, error) {
+	devices := []string{}
+	for _, t := range db.temps {
+		devices = append(devices, t.Device)
+	}
+	return devices, nil
+}
+
+func (db *mockDB) UpdateTemperatureQuality(id uint, quality, qualityReason string, correctedTemp *float64) (*models.TemperatureV2, error) {
 	return nil, nil
 }
 
-func (db *mockDB) GetTemperatures(deviceId string, from, to time.Time, geoSpatial string, lon0, lat0, lon1, lat1 float64, resultOffset, resultLimit uint64) ([]models.TemperatureV2, error) {
-	return db.temps, nil
+func (db *mockDB) DeleteTemperaturesBefore(before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (db *mockDB) CreateAlertRule(rule *models.AlertRule) (*models.AlertRule, error) {
+	return rule, nil
+}
+
+func (db *mockDB) GetAlertRules() ([]models.AlertRule, error) {
+	return []models.AlertRule{}, nil
+}
+
+func (db *mockDB) UpdateAlertRule(id uint, rule *models.AlertRule) (*models.AlertRule, error) {
+	return rule, nil
+}
+
+func (db *mockDB) DeleteAlertRule(id uint) error {
+	return nil
+}
+
+func (db *mockDB) CreateCalibration(calibration *models.Calibration) (*models.Calibration, error) {
+	return calibration, nil
+}
+
+func (db *mockDB) GetCalibrations(device string) ([]models.Calibration, error) {
+	return []models.Calibration{}, nil
+}
+
+func (db *mockDB) UpdateCalibration(id uint, calibration *models.Calibration) (*models.Calibration, error) {
+	return calibration, nil
+}
+
+func (db *mockDB) DeleteCalibration(id uint) error {
+	return nil
+}
+
+func (db *mockDB) ApplyCalibration(id uint) (int64, error) {
+	return 0, nil
+}
+
+func (db *mockDB) GetDevicePolicies() ([]models.DevicePolicy, error) {
+	return db.policies, nil
+}
+
+func (db *mockDB) SetDevicePolicy(device, visibility string) (*models.DevicePolicy, error) {
+	db.policies = append(db.policies, models.DevicePolicy{Device: device, Visibility: visibility})
+	return &db.policies[len(db.policies)-1], nil
+}
+
+func (db *mockDB) DeleteDevicePolicy(device string) error {
+	return nil
+}
+
+func (db *mockDB) Ping() error {
+	return nil
+}
+
+func (db *mockDB) MigrationStatus() error {
+	return nil
+}
+
+func (db *mockDB) Close() error {
+	return nil
+}
+
+func (db *mockDB) WithContext(ctx gocontext.Context) database.Datastore {
+	return db
+}
+
+func (db *mockDB) ForTenant(tenant string) database.Datastore {
+	return db
+}
+
+func (db *mockDB) WithoutDevices(devices []string) database.Datastore {
+	if len(devices) == 0 {
+		return db
+	}
+
+	hidden := map[string]bool{}
+	for _, d := range devices {
+		hidden[d] = true
+	}
+
+	visible := &mockDB{policies: db.policies}
+	for _, t := range db.temps {
+		if !hidden[t.Device] {
+			visible.temps = append(visible.temps, t)
+		}
+	}
+	return visible
 }
 
 type mockQuery struct {
-	device string
-	attrs  []string
-	types  []string
+	device  string
+	attrs   []string
+	types   []string
+	request *http.Request
 }
 
 func newMockQueryForAttributes(attributeNames []string) mockQuery {
@@ -215,13 +618,13 @@ func (q mockQuery) PaginationOffset() uint64 {
 }
 
 func (q mockQuery) Request() *http.Request {
-	return nil
+	return q.request
 }
 
-func createTempRecord(temp float32, water bool, when string) models.TemperatureV2 {
+func createTempRecord(temp float64, medium string, when string) models.TemperatureV2 {
 	t := models.TemperatureV2{}
 	t.Temp = temp
-	t.Water = water
+	t.Medium = medium
 	t.Timestamp, _ = time.Parse(time.RFC3339Nano, when)
 	return t
 }
@@ -0,0 +1,247 @@
+package context
+
+import (
+	"github.com/diwise/api-temperature/internal/pkg/application/precision"
+	"github.com/diwise/api-temperature/internal/pkg/application/units"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/deviceregistry"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+	"github.com/diwise/ngsi-ld-golang/pkg/datamodels/fiware"
+	"github.com/diwise/ngsi-ld-golang/pkg/ngsi-ld/geojson"
+	"github.com/diwise/ngsi-ld-golang/pkg/ngsi-ld/types"
+)
+
+const (
+	//indoorEnvironmentObservedType is the fiware type for observations of indoor conditions
+	indoorEnvironmentObservedType string = "IndoorEnvironmentObserved"
+	//soilTemperatureObservedType is used for ground temperatures, as there is no fiware type for them
+	soilTemperatureObservedType string = "SoilTemperatureObserved"
+	//roadSurfaceObservedType is used for road surface temperatures, as there is no fiware type for them
+	roadSurfaceObservedType string = "RoadSurfaceObserved"
+)
+
+//temperatureProperty is a number property with the unit of its value, that carries the
+//quality control status of the reading as properties of the property
+type temperatureProperty struct {
+	types.NumberProperty
+	UnitCode      string              `json:"unitCode,omitempty"`
+	Quality       *types.TextProperty `json:"quality,omitempty"`
+	QualityReason *types.TextProperty `json:"qualityReason,omitempty"`
+}
+
+//presentation is the unit and rounding that the temperatures of a query are presented with, and
+//the registry of the devices whose metadata is added to the entities along with the tenant that
+//the devices belong to
+type presentation struct {
+	unit     string
+	rounding precision.Rounder
+	devices  *deviceregistry.Client
+	tenant   string
+}
+
+//temperature returns the temperature of a reading converted from Celsius to the unit of the
+//presentation, rounded to the resolution of the sensor converted to the same unit
+func (p presentation) temperature(r *models.TemperatureV2) float64 {
+	return p.rounding.Round(units.FromCelsius(r.Temp, p.unit), units.IntervalFromCelsius(r.Resolution, p.unit))
+}
+
+//newTemperatureProperty returns the temperature of a reading as it should be presented
+func newTemperatureProperty(r *models.TemperatureV2, pres presentation) *temperatureProperty {
+	p := &temperatureProperty{
+		NumberProperty: *types.NewNumberProperty(pres.temperature(r)),
+		UnitCode:       pres.unit,
+	}
+
+	if r.Quality != "" {
+		p.Quality = types.NewTextProperty(r.Quality)
+	}
+
+	if r.QualityReason != "" {
+		p.QualityReason = types.NewTextProperty(r.QualityReason)
+	}
+
+	return p
+}
+
+//deviceMetadata describes the device that a reading comes from, as far as the device registry knows it
+type deviceMetadata struct {
+	DeviceName  *types.TextProperty `json:"deviceName,omitempty"`
+	DeviceOwner *types.TextProperty `json:"deviceOwner,omitempty"`
+	DeviceSite  *types.TextProperty `json:"deviceSite,omitempty"`
+	DeviceModel *types.TextProperty `json:"deviceModel,omitempty"`
+}
+
+//newDeviceMetadata returns the metadata of the device of a reading, which is empty if the device
+//registry does not know the device
+func newDeviceMetadata(r *models.TemperatureV2, pres presentation) deviceMetadata {
+	d, ok := pres.devices.Device(pres.tenant, r.Device)
+	if !ok {
+		return deviceMetadata{}
+	}
+
+	return deviceMetadata{
+		DeviceName:  newOptionalTextProperty(d.Name),
+		DeviceOwner: newOptionalTextProperty(d.Owner),
+		DeviceSite:  newOptionalTextProperty(d.Site),
+		DeviceModel: newOptionalTextProperty(d.Model),
+	}
+}
+
+func newOptionalTextProperty(value string) *types.TextProperty {
+	if value == "" {
+		return nil
+	}
+
+	return types.NewTextProperty(value)
+}
+
+func (m deviceMetadata) setGeoJSONProperties(f geojson.GeoJSONFeature, simplified bool) {
+	properties := []struct {
+		name     string
+		property *types.TextProperty
+	}{
+		{"deviceName", m.DeviceName},
+		{"deviceOwner", m.DeviceOwner},
+		{"deviceSite", m.DeviceSite},
+		{"deviceModel", m.DeviceModel},
+	}
+
+	for _, p := range properties {
+		if p.property == nil {
+			continue
+		}
+
+		if simplified {
+			f.SetProperty(p.name, p.property.Value)
+		} else {
+			f.SetProperty(p.name, p.property)
+		}
+	}
+}
+
+func (p *temperatureProperty) setGeoJSONProperties(f geojson.GeoJSONFeature, simplified bool) {
+	if p == nil {
+		return
+	}
+
+	if simplified {
+		f.SetProperty("temperature", p.Value)
+		f.SetProperty("temperatureUnitCode", p.UnitCode)
+		if p.Quality != nil {
+			f.SetProperty("temperatureQuality", p.Quality.Value)
+		}
+	} else {
+		f.SetProperty("temperature", p)
+	}
+}
+
+//weatherObserved extends the fiware WeatherObserved with our own temperature property
+type weatherObserved struct {
+	fiware.WeatherObserved
+	deviceMetadata
+	Temperature *temperatureProperty `json:"temperature,omitempty"`
+}
+
+func (wo weatherObserved) ToGeoJSONFeature(propertyName string, simplified bool) (geojson.GeoJSONFeature, error) {
+	f, err := wo.WeatherObserved.ToGeoJSONFeature(propertyName, simplified)
+	if err == nil {
+		wo.deviceMetadata.setGeoJSONProperties(f, simplified)
+		wo.Temperature.setGeoJSONProperties(f, simplified)
+	}
+	return f, err
+}
+
+//waterQualityObserved extends the fiware WaterQualityObserved with our own temperature property
+type waterQualityObserved struct {
+	fiware.WaterQualityObserved
+	deviceMetadata
+	Depth       *types.NumberProperty `json:"depth,omitempty"`
+	Temperature *temperatureProperty  `json:"temperature,omitempty"`
+}
+
+func (wqo waterQualityObserved) ToGeoJSONFeature(propertyName string, simplified bool) (geojson.GeoJSONFeature, error) {
+	f, err := wqo.WaterQualityObserved.ToGeoJSONFeature(propertyName, simplified)
+	if err == nil {
+		setDepthGeoJSONProperty(f, wqo.Depth, simplified)
+		wqo.deviceMetadata.setGeoJSONProperties(f, simplified)
+		wqo.Temperature.setGeoJSONProperties(f, simplified)
+	}
+	return f, err
+}
+
+//newDepthProperty returns a depth property for readings taken below the surface
+func newDepthProperty(r *models.TemperatureV2) *types.NumberProperty {
+	if r.Depth == 0 {
+		return nil
+	}
+
+	return types.NewNumberProperty(r.Depth)
+}
+
+func setDepthGeoJSONProperty(f geojson.GeoJSONFeature, depth *types.NumberProperty, simplified bool) {
+	if depth == nil {
+		return
+	}
+
+	if simplified {
+		f.SetProperty("depth", depth.Value)
+	} else {
+		f.SetProperty("depth", depth)
+	}
+}
+
+//temperatureObserved is a minimal observation entity for the kinds of temperatures that the
+//fiware package lacks a data model for. It follows the layout of the fiware observation types.
+type temperatureObserved struct {
+	types.BaseEntity
+	deviceMetadata
+	DateObserved types.DateTimeProperty          `json:"dateObserved"`
+	Location     geojson.GeoJSONProperty         `json:"location"`
+	RefDevice    *types.SingleObjectRelationship `json:"refDevice,omitempty"`
+	Depth        *types.NumberProperty           `json:"depth,omitempty"`
+	Temperature  *temperatureProperty            `json:"temperature,omitempty"`
+}
+
+func newTemperatureObserved(typeName, device string, latitude, longitude float64, observedAt string) *temperatureObserved {
+	refDevice := fiware.CreateDeviceRelationshipFromDevice(device)
+
+	if refDevice == nil {
+		device = "manual"
+	}
+
+	return &temperatureObserved{
+		DateObserved: *types.CreateDateTimeProperty(observedAt),
+		Location:     *geojson.CreateGeoJSONPropertyFromWGS84(longitude, latitude),
+		RefDevice:    refDevice,
+		BaseEntity: types.BaseEntity{
+			ID:   "urn:ngsi-ld:" + typeName + ":" + device + ":" + observedAt,
+			Type: typeName,
+			Context: []string{
+				"https://schema.lab.fiware.org/ld/context",
+				"https://uri.etsi.org/ngsi-ld/v1/ngsi-ld-core-context.jsonld",
+			},
+		},
+	}
+}
+
+func (to temperatureObserved) ToGeoJSONFeature(propertyName string, simplified bool) (geojson.GeoJSONFeature, error) {
+	f := geojson.NewGeoJSONFeature(to.ID, to.Type, to.Location.GeoPropertyValue())
+
+	if simplified {
+		f.SetProperty(propertyName, to.Location.GeoPropertyValue())
+		f.SetProperty("dateObserved", to.DateObserved.Value.Value)
+
+		if to.RefDevice != nil {
+			f.SetProperty("refDevice", to.RefDevice.Object)
+		}
+	} else {
+		f.SetProperty(propertyName, to.Location)
+		f.SetProperty("dateObserved", to.DateObserved)
+		f.SetProperty("refDevice", to.RefDevice)
+	}
+
+	setDepthGeoJSONProperty(f, to.Depth, simplified)
+	to.deviceMetadata.setGeoJSONProperties(f, simplified)
+	to.Temperature.setGeoJSONProperties(f, simplified)
+
+	return f, nil
+}
@@ -0,0 +1,41 @@
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestThatConditionalRequestsAreAnsweredWithNotModified(t *testing.T) {
+	is := is.New(t)
+
+	handler := withETag(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/ld+json")
+		w.Write([]byte(`[{"id":"urn:ngsi-ld:WeatherObserved:temperature:sensor"}]`))
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/ngsi-ld/v1/entities", nil))
+
+	etag := w.Header().Get("ETag")
+	is.Equal(w.Code, http.StatusOK)
+	is.True(etag != "") // successful responses should be tagged
+
+	req := httptest.NewRequest(http.MethodGet, "/ngsi-ld/v1/entities", nil)
+	req.Header.Set("If-None-Match", etag)
+
+	w = httptest.NewRecorder()
+	handler(w, req)
+
+	is.Equal(w.Code, http.StatusNotModified) // clients that have the current response should be told so
+	is.Equal(w.Body.Len(), 0)                // without sending it again
+
+	req.Header.Set("If-None-Match", `"something-else"`)
+
+	w = httptest.NewRecorder()
+	handler(w, req)
+
+	is.Equal(w.Code, http.StatusOK) // while clients with an old response should get the new one
+}
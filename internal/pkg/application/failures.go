@@ -0,0 +1,200 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/rs/zerolog"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/messaging/deadletter"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/metrics"
+	"github.com/diwise/api-temperature/pkg/infrastructure/messaging/commands"
+	"github.com/diwise/messaging-golang/pkg/messaging"
+)
+
+//TelemetryHandler is a callback type that handles a received telemetry message and
+//reports any failure back to the caller
+type TelemetryHandler func(amqp.Delivery, zerolog.Logger) error
+
+//PermanentError marks a failure that will not go away no matter how many times we retry
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+func permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+func isPermanent(err error) bool {
+	var pe *PermanentError
+	return errors.As(err, &pe)
+}
+
+//RetryPolicy controls how many times, and how often, a failed message is retried
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+//DefaultRetryPolicy returns a policy that retries a message five times with exponential backoff
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+	}
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff << uint(attempt-1)
+	if d > p.MaxBackoff || d <= 0 {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+//FailureHandler retries transient failures and routes permanently failing messages to a dead letter store
+type FailureHandler struct {
+	policy      RetryPolicy
+	deadletters deadletter.Store
+	sleep       func(d time.Duration, stop <-chan struct{}) bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	mu       sync.Mutex
+	handlers map[string]TelemetryHandler
+	log      zerolog.Logger
+}
+
+//NewFailureHandler creates a new FailureHandler that uses the provided retry policy and dead letter store
+func NewFailureHandler(policy RetryPolicy, deadletters deadletter.Store, log zerolog.Logger) *FailureHandler {
+	return &FailureHandler{
+		policy:      policy,
+		deadletters: deadletters,
+		sleep:       sleepUnlessStopped,
+		stop:        make(chan struct{}),
+		handlers:    map[string]TelemetryHandler{},
+		log:         log,
+	}
+}
+
+//Wrap decorates a TelemetryHandler with retries and dead-lettering and returns a handler
+//that can be registered with the messenger
+func (fh *FailureHandler) Wrap(routingKey string, handler TelemetryHandler) messaging.TopicMessageHandler {
+	fh.mu.Lock()
+	fh.handlers[routingKey] = handler
+	fh.mu.Unlock()
+
+	return func(msg amqp.Delivery, log zerolog.Logger) {
+		fh.handle(msg, handler, log)
+	}
+}
+
+//Stop makes messages that are waiting to be retried give up and go to the dead letter store
+//right away, so that they are not lost when the service shuts down
+func (fh *FailureHandler) Stop() {
+	fh.stopOnce.Do(func() { close(fh.stop) })
+}
+
+//DeadLetters returns the messages that are currently dead-lettered
+func (fh *FailureHandler) DeadLetters() []deadletter.Message {
+	return fh.deadletters.List()
+}
+
+//Replay hands a dead-lettered message back to the handler of its original routing key
+func (fh *FailureHandler) Replay(id string) error {
+	m, err := fh.deadletters.Get(id)
+	if err != nil {
+		return err
+	}
+
+	fh.mu.Lock()
+	handler, ok := fh.handlers[m.RoutingKey]
+	fh.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no handler registered for routing key %s", m.RoutingKey)
+	}
+
+	log := fh.log.With().Str("deadletter", id).Logger()
+	log.Info().Msg("replaying dead-lettered message")
+
+	// The message is either handled or dead-lettered again, so it is safe to remove the old copy
+	fh.handle(amqp.Delivery{
+		RoutingKey:  m.RoutingKey,
+		ContentType: m.ContentType,
+		Body:        m.Body,
+	}, handler, log)
+
+	return fh.deadletters.Remove(id)
+}
+
+//handle hands a message to its handler until it has been handled, or until the handler fails
+//permanently or runs out of attempts. The message is counted as received once, no matter how many
+//attempts it takes. Handlers count the outcome of the attempt that a message is handled or rejected
+//in, so only transient failures that are given up on are counted here.
+func (fh *FailureHandler) handle(msg amqp.Delivery, handler TelemetryHandler, log zerolog.Logger) {
+	var err error
+	attempt := 0
+
+	metrics.MessageReceived(msg.RoutingKey)
+
+	for attempt < fh.policy.MaxAttempts {
+		attempt++
+
+		err = handler(msg, log)
+		if err == nil || isPermanent(err) {
+			break
+		}
+
+		if attempt < fh.policy.MaxAttempts {
+			backoff := fh.policy.backoff(attempt)
+			log.Warn().Err(err).Int("attempt", attempt).Msgf("failed to handle message, retrying in %s", backoff)
+
+			if !fh.sleep(backoff, fh.stop) {
+				log.Warn().Msg("shutting down, will not retry message again")
+				break
+			}
+		}
+	}
+
+	if err == nil {
+		return
+	}
+
+	if !isPermanent(err) {
+		metrics.MessageProcessed(msg.RoutingKey, commands.StatusFailed)
+	}
+
+	log.Error().Err(err).Int("attempts", attempt).Msg("giving up on message, routing it to the dead letter exchange")
+
+	if dlErr := fh.deadletters.Add(msg, err, attempt); dlErr != nil {
+		log.Error().Err(dlErr).Str("body", string(msg.Body)).Msg("failed to dead-letter message, it will be lost")
+	}
+}
+
+//sleepUnlessStopped sleeps for d and returns true, unless stop is closed before that
+func sleepUnlessStopped(d time.Duration, stop <-chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-stop:
+		return false
+	}
+}
@@ -0,0 +1,155 @@
+package application
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/prometheus/client_golang/prometheus"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/messaging/deadletter"
+)
+
+func TestThatTransientFailuresAreRetried(t *testing.T) {
+	is := is.New(t)
+	fh, store := newTestFailureHandler()
+
+	calls := 0
+	handler := fh.Wrap("telemetry.temperature", func(msg amqp.Delivery, log zerolog.Logger) error {
+		calls++
+		if calls < 3 {
+			return errors.New("database is down")
+		}
+		return nil
+	})
+
+	handler(amqp.Delivery{RoutingKey: "telemetry.temperature"}, log.Logger)
+
+	is.Equal(calls, 3)             // handler should be called until it succeeds
+	is.Equal(len(store.List()), 0) // no message should be dead-lettered
+}
+
+func TestThatPermanentFailuresAreDeadLetteredWithoutRetry(t *testing.T) {
+	is := is.New(t)
+	fh, store := newTestFailureHandler()
+
+	calls := 0
+	handler := fh.Wrap("telemetry.temperature", func(msg amqp.Delivery, log zerolog.Logger) error {
+		calls++
+		return permanent(errors.New("bad json"))
+	})
+
+	handler(amqp.Delivery{RoutingKey: "telemetry.temperature", Body: []byte("{")}, log.Logger)
+
+	is.Equal(calls, 1) // permanent failures should not be retried
+
+	deadletters := store.List()
+	is.Equal(len(deadletters), 1)               // message should be dead-lettered
+	is.Equal(deadletters[0].Reason, "bad json") // dead letter should carry the error reason
+}
+
+func TestThatExhaustedRetriesAreDeadLettered(t *testing.T) {
+	is := is.New(t)
+	fh, store := newTestFailureHandler()
+
+	calls := 0
+	handler := fh.Wrap("telemetry.temperature", func(msg amqp.Delivery, log zerolog.Logger) error {
+		calls++
+		return errors.New("database is down")
+	})
+
+	handler(amqp.Delivery{RoutingKey: "telemetry.temperature"}, log.Logger)
+
+	is.Equal(calls, DefaultRetryPolicy().MaxAttempts) // handler should be called max attempts times
+	is.Equal(len(store.List()), 1)                    // message should be dead-lettered
+	is.Equal(store.List()[0].Attempts, calls)         // dead letter should record the number of attempts
+}
+
+func TestThatReplayRemovesHandledDeadLetters(t *testing.T) {
+	is := is.New(t)
+	fh, store := newTestFailureHandler()
+
+	failing := true
+	handler := fh.Wrap("telemetry.temperature", func(msg amqp.Delivery, log zerolog.Logger) error {
+		if failing {
+			return permanent(errors.New("not yet"))
+		}
+		return nil
+	})
+
+	handler(amqp.Delivery{RoutingKey: "telemetry.temperature"}, log.Logger)
+	is.Equal(len(store.List()), 1)
+
+	failing = false
+	err := fh.Replay(store.List()[0].ID)
+
+	is.NoErr(err)                  // replay should succeed
+	is.Equal(len(store.List()), 0) // replayed message should be removed
+}
+
+func TestThatRetriedMessagesAreCountedOnce(t *testing.T) {
+	is := is.New(t)
+	fh, _ := newTestFailureHandler()
+
+	handler := fh.Wrap("telemetry.retried", func(msg amqp.Delivery, log zerolog.Logger) error {
+		return errors.New("database is down")
+	})
+
+	handler(amqp.Delivery{RoutingKey: "telemetry.retried"}, log.Logger)
+
+	is.Equal(counted("temperature_messages_received_total", "telemetry.retried", ""), 1.0)        // the message should be received once
+	is.Equal(counted("temperature_messages_processed_total", "telemetry.retried", "failed"), 1.0) // and fail once, after the last attempt
+}
+
+//counted returns the value of a counter of messages on a topic, with an outcome unless it is empty
+func counted(name, topic, outcome string) float64 {
+	families, _ := prometheus.DefaultGatherer.Gather()
+
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+
+		for _, m := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+
+			if labels["topic"] == topic && labels["outcome"] == outcome {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return 0
+}
+
+func newTestFailureHandler() (*FailureHandler, deadletter.Store) {
+	store := deadletter.NewInMemoryStore()
+	fh := NewFailureHandler(DefaultRetryPolicy(), store, log.Logger)
+	fh.sleep = func(time.Duration, <-chan struct{}) bool { return true }
+	return fh, store
+}
+
+func TestThatMessagesAreDeadLetteredInsteadOfRetriedWhenStopped(t *testing.T) {
+	is := is.New(t)
+	fh, store := newTestFailureHandler()
+	fh.sleep = sleepUnlessStopped
+	fh.Stop()
+
+	calls := 0
+	handler := fh.Wrap("telemetry.temperature", func(msg amqp.Delivery, log zerolog.Logger) error {
+		calls++
+		return errors.New("database is down")
+	})
+
+	handler(amqp.Delivery{RoutingKey: "telemetry.temperature"}, log.Logger)
+
+	is.Equal(calls, 1)             // handler should not be retried after stop
+	is.Equal(len(store.List()), 1) // message should be dead-lettered rather than lost
+}
@@ -2,9 +2,12 @@ package application
 
 import (
 	"compress/flate"
+	"context"
+	"errors"
 	"net/http"
-	"os"
+	"strconv"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/handler/extension"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
@@ -13,9 +16,20 @@ import (
 	"github.com/go-chi/chi/middleware"
 
 	gql "github.com/diwise/api-temperature/internal/pkg/_presentation/api/graphql"
+	"github.com/diwise/api-temperature/internal/pkg/application/alerting"
 	fiwarecontext "github.com/diwise/api-temperature/internal/pkg/application/context"
+	"github.com/diwise/api-temperature/internal/pkg/application/precision"
+	"github.com/diwise/api-temperature/internal/pkg/application/querycache"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/auth"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/deviceregistry"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/metrics"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/ratelimit"
 	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/database"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/tenancy"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/tracing"
 	ngsi "github.com/diwise/ngsi-ld-golang/pkg/ngsi-ld"
+	ngsierrors "github.com/diwise/ngsi-ld-golang/pkg/ngsi-ld/errors"
 
 	"github.com/rs/cors"
 	"github.com/rs/zerolog"
@@ -26,10 +40,37 @@ type RequestRouter struct {
 	impl *chi.Mux
 }
 
-func (router *RequestRouter) addGraphQLHandlers(db database.Datastore) {
-	gqlServer := handler.New(gql.NewExecutableSchema(gql.Config{Resolvers: &gql.Resolver{}}))
+func (router *RequestRouter) addGraphQLHandlers(db database.Datastore, cache *querycache.Cache, rounding precision.Rounder, devices *deviceregistry.Client) {
+	gqlServer := handler.New(gql.NewExecutableSchema(gql.Config{Resolvers: &gql.Resolver{Cache: cache, Rounding: rounding, Devices: devices}}))
 	gqlServer.AddTransport(&transport.POST{})
 	gqlServer.Use(extension.Introspection{})
+	gqlServer.AroundResponses(func(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+		response := next(ctx)
+		if graphql.HasOperationContext(ctx) {
+			oc := graphql.GetOperationContext(ctx)
+
+			operationType := ""
+			if oc.Operation != nil {
+				operationType = string(oc.Operation.Operation)
+			}
+			metrics.ObserveGraphQLOperation(operationType, oc.Stats.OperationStart)
+		}
+		return response
+	})
+	gqlServer.AroundFields(func(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+		fc := graphql.GetFieldContext(ctx)
+		if !fc.IsResolver {
+			return next(ctx)
+		}
+
+		ctx, span := tracing.Tracer().Start(ctx, "graphql."+fc.Object+"."+fc.Field.Name)
+		defer span.End()
+
+		result, err := next(ctx)
+		tracing.RecordError(span, err)
+
+		return result, err
+	})
 
 	// TODO: Investigate some way to use closures instead of context even for GraphQL handlers
 	router.impl.Use(database.Middleware(db))
@@ -39,13 +80,24 @@ func (router *RequestRouter) addGraphQLHandlers(db database.Datastore) {
 }
 
 func (router *RequestRouter) addNGSIHandlers(contextRegistry ngsi.ContextRegistry) {
-	router.Get("/ngsi-ld/v1/entities", ngsi.NewQueryEntitiesHandler(contextRegistry))
+	router.Get("/ngsi-ld/v1/entities", withValidEntityQuery(withETag(ngsi.NewQueryEntitiesHandler(contextRegistry))))
 }
 
-func (router *RequestRouter) addProbeHandlers() {
-	router.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	})
+//withValidEntityQuery rejects requests for entities with unsupported parameters as bad requests,
+//since errors from the context sources are reported as internal errors by the NGSI-LD handler
+func withValidEntityQuery(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := fiwarecontext.ValidateRequest(r); err != nil {
+			ngsierrors.ReportNewBadRequestData(w, err.Error())
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func (router *RequestRouter) addMetricsHandler() {
+	router.impl.Handle("/metrics", metrics.Handler())
 }
 
 //Get accepts a pattern that should be routed to the handlerFn on a GET request
@@ -53,13 +105,35 @@ func (router *RequestRouter) Get(pattern string, handlerFn http.HandlerFunc) {
 	router.impl.Get(pattern, handlerFn)
 }
 
-//newRequestRouter creates and returns a new router wrapper
-func newRequestRouter() *RequestRouter {
+//Post accepts a pattern that should be routed to the handlerFn on a POST request
+func (router *RequestRouter) Post(pattern string, handlerFn http.HandlerFunc) {
+	router.impl.Post(pattern, handlerFn)
+}
+
+//Patch accepts a pattern that should be routed to the handlerFn on a PATCH request
+func (router *RequestRouter) Patch(pattern string, handlerFn http.HandlerFunc) {
+	router.impl.Patch(pattern, handlerFn)
+}
+
+//Put accepts a pattern that should be routed to the handlerFn on a PUT request
+func (router *RequestRouter) Put(pattern string, handlerFn http.HandlerFunc) {
+	router.impl.Put(pattern, handlerFn)
+}
+
+//Delete accepts a pattern that should be routed to the handlerFn on a DELETE request
+func (router *RequestRouter) Delete(pattern string, handlerFn http.HandlerFunc) {
+	router.impl.Delete(pattern, handlerFn)
+}
+
+//newRequestRouter creates and returns a new router wrapper. Requests are authenticated and authorized
+//if an authenticator is provided, are always resolved to a tenant and are rate limited if enabled.
+func newRequestRouter(cfg config.Config, authenticator auth.Authenticator) *RequestRouter {
 	router := &RequestRouter{impl: chi.NewRouter()}
 
 	router.impl.Use(cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowCredentials: true,
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedHeaders:   []string{"Origin", "Accept", "Content-Type", "X-Requested-With", "Authorization", tenancy.HeaderName},
+		AllowCredentials: cfg.CORS.AllowCredentials,
 		Debug:            false,
 	}).Handler)
 
@@ -67,36 +141,65 @@ func newRequestRouter() *RequestRouter {
 	compressor := middleware.NewCompressor(flate.DefaultCompression, "application/json", "application/ld+json")
 	router.impl.Use(compressor.Handler)
 	router.impl.Use(middleware.Logger)
+	router.impl.Use(metrics.Middleware)
+	router.impl.Use(tracing.Middleware)
+
+	if authenticator != nil {
+		router.impl.Use(auth.Middleware(cfg.Auth, authenticator, auth.DefaultRules(cfg.Auth)))
+	}
+
+	router.impl.Use(tenancy.Middleware)
+
+	// Clients are rate limited after authentication, so that they can be told apart by their credentials
+	if cfg.RateLimit.Enabled {
+		router.impl.Use(ratelimit.Middleware(cfg.RateLimit))
+	}
 
 	return router
 }
 
-func createRequestRouter(contextRegistry ngsi.ContextRegistry, db database.Datastore) *RequestRouter {
-	router := newRequestRouter()
+func createRequestRouter(cfg config.Config, authenticator auth.Authenticator, contextRegistry ngsi.ContextRegistry, db database.Datastore, cache *querycache.Cache, devices *deviceregistry.Client, failures *FailureHandler, alerts alerting.Engine, dependencies []Dependency) *RequestRouter {
+	router := newRequestRouter(cfg, authenticator)
 
-	router.addGraphQLHandlers(db)
+	router.addGraphQLHandlers(db, cache, precision.NewRounder(cfg.Precision.GraphQL), devices)
 	router.addNGSIHandlers(contextRegistry)
-	router.addProbeHandlers()
+	router.addAdminHandlers(cfg, db, failures, alerts)
+	router.addMetricsHandler()
+	router.addProbeHandlers(dependencies)
 
 	return router
 }
 
-//CreateRouterAndStartServing creates a request router, registers all handlers and starts serving requests
-func CreateRouterAndStartServing(log zerolog.Logger, db database.Datastore) {
+//CreateRouterAndStartServing creates a request router, registers all handlers and starts serving
+//requests in the background. The returned server should be shut down when the service stops.
+//Query results are kept in cache, unless it is nil, and readings are enriched with the metadata of
+//their devices, unless devices is nil.
+func CreateRouterAndStartServing(log zerolog.Logger, cfg config.Config, authenticator auth.Authenticator, db database.Datastore, cache *querycache.Cache, devices *deviceregistry.Client, failures *FailureHandler, alerts alerting.Engine, dependencies []Dependency) *http.Server {
 
 	contextRegistry := ngsi.NewContextRegistry()
-	ctxSource := fiwarecontext.CreateSource(db)
+	ctxSource := fiwarecontext.CreateCachedSource(db, cache, precision.NewRounder(cfg.Precision.NGSILD), devices)
 	contextRegistry.Register(ctxSource)
 
-	router := createRequestRouter(contextRegistry, db)
+	router := createRequestRouter(cfg, authenticator, contextRegistry, db, cache, devices, failures, alerts, dependencies)
+
+	port := strconv.Itoa(cfg.HTTP.Port)
 
-	port := os.Getenv("TEMPERATURE_API_PORT")
-	if port == "" {
-		port = "8880"
+	server := &http.Server{
+		Addr:         ":" + port,
+		Handler:      router.impl,
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
 	}
 
-	log.Info().Str("port", port).Msg("starting to listen for connections")
+	go func() {
+		log.Info().Str("port", port).Msg("starting to listen for connections")
+
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal().Err(err).Msg("failed to listen for connections")
+		}
+	}()
 
-	err := http.ListenAndServe(":"+port, router.impl)
-	log.Fatal().Err(err).Msg("failed to listen for connections")
+	return server
 }
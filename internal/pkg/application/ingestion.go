@@ -0,0 +1,361 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/diwise/api-temperature/internal/pkg/application/alerting"
+	"github.com/diwise/api-temperature/internal/pkg/application/anomaly"
+	"github.com/diwise/api-temperature/internal/pkg/application/precision"
+	"github.com/diwise/api-temperature/internal/pkg/application/validation"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/metrics"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/database"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/tenancy"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/tracing"
+	"github.com/diwise/api-temperature/pkg/infrastructure/messaging/events"
+	"github.com/diwise/messaging-golang/pkg/messaging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//Ingester validates incoming temperature readings, stores them in the datastore and
+//analyses the stored readings for signs of sensor malfunction and alert conditions
+type Ingester struct {
+	db          database.Datastore
+	validator   validation.Validator
+	detector    anomaly.Detector
+	alerts      alerting.Engine
+	messenger   MessagingContext
+	resolutions *precision.Resolutions
+	rounding    precision.Rounder
+}
+
+//NewIngester creates a new Ingester that tags readings with the resolutions of the precision
+//configuration and rounds the temperatures of the events that it publishes accordingly
+func NewIngester(db database.Datastore, validator validation.Validator, detector anomaly.Detector, alerts alerting.Engine, messenger MessagingContext, cfg config.Precision) *Ingester {
+	return &Ingester{
+		db:          db,
+		validator:   validator,
+		detector:    detector,
+		alerts:      alerts,
+		messenger:   messenger,
+		resolutions: precision.NewResolutions(cfg),
+		rounding:    precision.NewRounder(cfg.Events),
+	}
+}
+
+//Store validates a temperature reading and, unless it is rejected, adds it to the datastore
+//for the tenant of ctx together with its quality control status. Rejected readings are reported as permanent errors,
+//so that they end up in quarantine.
+func (i *Ingester) Store(ctx context.Context, msg messaging.IoTHubMessage, temp, depth float64, kind string, log zerolog.Logger) (*models.TemperatureV2, error) {
+	tenant := tenancy.FromContext(ctx)
+
+	ctx, span := tracing.Tracer().Start(ctx, "ingester.Store", trace.WithAttributes(
+		attribute.String("tenant", tenant),
+		attribute.String("device", msg.Origin.Device),
+		attribute.String("kind", kind),
+	))
+	defer span.End()
+
+	m, err := i.check(tenant, msg, temp, depth, kind, log)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	db := i.db.WithContext(ctx).ForTenant(tenant)
+
+	started := time.Now()
+	measurement, err := db.AddTemperatureMeasurement(*m)
+	metrics.ObserveInsert(started)
+
+	if err != nil {
+		if errors.Is(err, database.ErrAlreadyExists) {
+			span.SetAttributes(attribute.Bool("duplicate", true))
+			i.publishDuplicate(db, msg.Origin.Device, m.Depth, kind, m.Timestamp, log)
+		} else {
+			tracing.RecordError(span, err)
+		}
+		return nil, err
+	}
+
+	metrics.ObserveReading(kind, measurement.Timestamp)
+
+	i.publishStored(measurement, kind, false, log)
+	i.detectAnomalies(measurement, log)
+	i.evaluateAlerts(measurement, log)
+
+	return measurement, nil
+}
+
+//SeriesItem is a single reading in a series of historical readings
+type SeriesItem struct {
+	Msg   messaging.IoTHubMessage
+	Temp  float64
+	Unit  string // the unit that Temp is reported in, Celsius if empty
+	Depth float64
+	Kind  string
+}
+
+//Import validates a series of historical readings and adds the ones that are not rejected to the
+//datastore in a single transaction. The outcome of each reading is returned in the same order as
+//the readings, with a nil error for the readings that were stored. Historical readings are not
+//analysed for anomalies or alert conditions, as those only make sense for current readings.
+//All of the readings are stored for the tenant of ctx.
+func (i *Ingester) Import(ctx context.Context, items []SeriesItem, log zerolog.Logger) ([]*models.TemperatureV2, []error, error) {
+	tenant := tenancy.FromContext(ctx)
+
+	ctx, span := tracing.Tracer().Start(ctx, "ingester.Import", trace.WithAttributes(
+		attribute.String("tenant", tenant),
+		attribute.Int("count", len(items)),
+	))
+	defer span.End()
+
+	stored := make([]*models.TemperatureV2, len(items))
+	outcomes := make([]error, len(items))
+
+	indices := []int{}
+	batch := []models.TemperatureV2{}
+
+	for idx, item := range items {
+		temp, err := toCelsius(item.Temp, item.Unit)
+		if err != nil {
+			outcomes[idx] = err
+			continue
+		}
+
+		m, err := i.check(tenant, item.Msg, temp, item.Depth, item.Kind, log)
+		if err != nil {
+			outcomes[idx] = err
+			continue
+		}
+
+		indices = append(indices, idx)
+		batch = append(batch, *m)
+	}
+
+	if len(batch) > 0 {
+		results, err := i.db.WithContext(ctx).ForTenant(tenant).ImportTemperatureMeasurements(batch)
+		if err != nil {
+			tracing.RecordError(span, err)
+			return nil, nil, err
+		}
+
+		for b, idx := range indices {
+			if results[b] != nil {
+				outcomes[idx] = results[b]
+				continue
+			}
+
+			stored[idx] = &batch[b]
+			metrics.ObserveReading(items[idx].Kind, stored[idx].Timestamp)
+			i.publishStored(stored[idx], items[idx].Kind, false, log)
+		}
+	}
+
+	return stored, outcomes, nil
+}
+
+//check validates a reading of a tenant and returns the measurement that should be stored for it
+func (i *Ingester) check(tenant string, msg messaging.IoTHubMessage, temp, depth float64, kind string, log zerolog.Logger) (*models.TemperatureV2, error) {
+	ts, err := time.Parse(time.RFC3339Nano, msg.Timestamp)
+	if err != nil {
+		return nil, permanent(fmt.Errorf("%w: failed to parse timestamp from %s", database.ErrInvalidTimestamp, msg.Timestamp))
+	}
+
+	if !models.IsValidMedium(kind) {
+		return nil, permanent(fmt.Errorf("unknown temperature kind %q", kind))
+	}
+
+	if depth < 0 || math.IsNaN(depth) || math.IsInf(depth, 0) {
+		return nil, permanent(fmt.Errorf("invalid measurement depth %f", depth))
+	}
+
+	result := i.validator.Validate(validation.Reading{
+		Tenant:    tenant,
+		Device:    msg.Origin.Device,
+		Kind:      kind,
+		Latitude:  msg.Origin.Latitude,
+		Longitude: msg.Origin.Longitude,
+		Temp:      temp,
+		Depth:     depth,
+		Timestamp: ts,
+	})
+
+	quality := models.QualityGood
+
+	if result.Verdict == validation.Rejected {
+		return nil, permanent(fmt.Errorf("temperature reading rejected: %s", result.Reason()))
+	} else if result.Verdict == validation.Suspect {
+		log.Warn().Str("reason", result.Reason()).Msg("storing suspect temperature reading")
+		quality = models.QualitySuspect
+	}
+
+	return &models.TemperatureV2{
+		Tenant:        tenant,
+		Device:        msg.Origin.Device,
+		Latitude:      msg.Origin.Latitude,
+		Longitude:     msg.Origin.Longitude,
+		Temp:          temp,
+		Resolution:    i.resolutions.Of(msg.Origin.Device, kind),
+		Depth:         depth,
+		Medium:        kind,
+		Timestamp:     ts,
+		Quality:       quality,
+		QualityReason: result.Reason(),
+	}, nil
+}
+
+func (i *Ingester) publishStored(m *models.TemperatureV2, kind string, duplicate bool, log zerolog.Logger) {
+	err := i.messenger.PublishOnTopic(&events.TemperatureStored{
+		SchemaVersion: events.TemperatureStoredSchemaVersion,
+		ID:            m.ID,
+		Tenant:        m.Tenant,
+		Device:        m.Device,
+		Kind:          kind,
+		Latitude:      m.Latitude,
+		Longitude:     m.Longitude,
+		Temp:          i.rounding.Round(m.Temp, m.Resolution),
+		Depth:         m.Depth,
+		Quality:       m.Quality,
+		Duplicate:     duplicate,
+		Timestamp:     m.Timestamp,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to publish temperature stored event")
+	}
+}
+
+//publishDuplicate looks up the reading that a duplicate collided with, so that
+//consumers are told about the stored value rather than the duplicate
+func (i *Ingester) publishDuplicate(db database.Datastore, device string, depth float64, kind string, ts time.Time, log zerolog.Logger) {
+	temps, err := db.GetTemperatures(device, nil, nil, &database.DepthRange{Min: depth, Max: depth}, ts, ts.Add(time.Nanosecond), "", 0.0, 0.0, 0.0, 0.0, 0, 1)
+	if err != nil || len(temps) == 0 {
+		log.Warn().Msg("failed to look up the stored reading of a duplicate")
+		return
+	}
+
+	i.publishStored(&temps[0], kind, true, log)
+}
+
+func (i *Ingester) evaluateAlerts(m *models.TemperatureV2, log zerolog.Logger) {
+	for _, a := range i.alerts.Evaluate(m) {
+		alert := events.TemperatureAlert{
+			RuleID:    a.Rule.ID,
+			RuleName:  a.Rule.Name,
+			Tenant:    m.Tenant,
+			Device:    a.Device,
+			Depth:     m.Depth,
+			Medium:    m.Medium,
+			Condition: a.Rule.Condition,
+			Threshold: a.Rule.Threshold,
+			Temp:      a.Temp,
+			Since:     a.Since,
+			Timestamp: a.Timestamp,
+		}
+
+		var err error
+		if a.Fired {
+			log.Info().Str("device", a.Device).Str("rule", a.Rule.Name).Msg("temperature alert fired")
+			err = i.messenger.PublishOnTopic(&events.TemperatureAlertFired{TemperatureAlert: alert})
+		} else {
+			log.Info().Str("device", a.Device).Str("rule", a.Rule.Name).Msg("temperature alert cleared")
+			err = i.messenger.PublishOnTopic(&events.TemperatureAlertCleared{TemperatureAlert: alert})
+		}
+
+		if err != nil {
+			log.Error().Err(err).Msg("failed to publish alert event")
+		}
+	}
+}
+
+func (i *Ingester) detectAnomalies(m *models.TemperatureV2, log zerolog.Logger) {
+	if m.Device == "" {
+		return
+	}
+
+	anomalies := i.detector.Observe(sensorKey(m.Tenant, m.Device, m.Depth), anomaly.Sample{Temp: m.Temp, Timestamp: m.Timestamp})
+
+	for _, a := range anomalies {
+		log.Warn().Str("device", a.Device).Str("anomaly", a.Kind).Msg(a.Description)
+
+		err := i.messenger.PublishOnTopic(&events.TemperatureAnomalyDetected{
+			Tenant:      m.Tenant,
+			Device:      m.Device,
+			Depth:       m.Depth,
+			Medium:      m.Medium,
+			Anomaly:     a.Kind,
+			Description: a.Description,
+			Temp:        a.Temp,
+			Expected:    a.Expected,
+			Since:       a.Since,
+			Timestamp:   a.Timestamp,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to publish anomaly event")
+		}
+	}
+}
+
+//SeedAnomalyDetector feeds the anomaly detector with the readings that have been stored
+//within the provided duration, so that it does not have to start from scratch on startup
+func (i *Ingester) SeedAnomalyDetector(history time.Duration, log zerolog.Logger) error {
+	const pageSize uint64 = 1000
+
+	from := time.Now().UTC().Add(-history)
+	samples := map[string][]anomaly.Sample{}
+	offset := uint64(0)
+
+	for {
+		temps, err := i.db.GetTemperatures("", nil, nil, nil, from, time.Time{}, "", 0.0, 0.0, 0.0, 0.0, offset, pageSize)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve temperature history: %s", err.Error())
+		}
+
+		for _, t := range temps {
+			metrics.ObserveReading(t.Medium, t.Timestamp)
+
+			if t.Device != "" {
+				key := sensorKey(t.Tenant, t.Device, t.Depth)
+				samples[key] = append(samples[key], anomaly.Sample{Temp: t.Temp, Timestamp: t.Timestamp})
+			}
+		}
+
+		if uint64(len(temps)) < pageSize {
+			break
+		}
+
+		offset += pageSize
+	}
+
+	for device, s := range samples {
+		i.detector.Seed(device, s)
+	}
+
+	log.Info().Msgf("seeded anomaly detector with history from %d sensors", len(samples))
+
+	return nil
+}
+
+//sensorKey separates the readings of devices that measure at several depths, such
+//as buoys, so that each depth is analysed as a sensor of its own. Devices of other
+//tenants than the default tenant are prefixed with their tenant, as device names
+//are only unique within a tenant.
+func sensorKey(tenant, device string, depth float64) string {
+	if tenant != "" && tenant != models.DefaultTenant {
+		device = tenant + "/" + device
+	}
+
+	if depth == 0 {
+		return device
+	}
+
+	return fmt.Sprintf("%s@%gm", device, depth)
+}
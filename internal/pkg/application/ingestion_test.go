@@ -0,0 +1,127 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/rs/zerolog/log"
+
+	"github.com/diwise/api-temperature/internal/pkg/application/alerting"
+	"github.com/diwise/api-temperature/internal/pkg/application/anomaly"
+	"github.com/diwise/api-temperature/internal/pkg/application/validation"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/database"
+	"github.com/diwise/api-temperature/pkg/infrastructure/messaging/events"
+	"github.com/diwise/messaging-golang/pkg/messaging"
+)
+
+func TestThatStoredReadingsArePublished(t *testing.T) {
+	is := is.New(t)
+	ingester, messenger := newTestIngester(t)
+
+	m, err := ingester.Store(context.Background(), newTestMessage("sensor", "2021-11-01T12:00:00Z"), 12.74, 0.0, validation.KindWater, log.Logger)
+	is.NoErr(err)
+	is.Equal(m.Temp, 12.74)     // the reading should be stored with full precision
+	is.Equal(m.Resolution, 0.1) // together with the default resolution
+
+	stored := messenger.stored()
+	is.Equal(len(stored), 1)                                                 // a stored event should be published
+	is.Equal(stored[0].ID, m.ID)                                             // with the database id of the reading
+	is.Equal(stored[0].Kind, validation.KindWater)                           // and its kind
+	is.Equal(stored[0].Temp, 12.7)                                           // and the rounded temperature
+	is.Equal(stored[0].SchemaVersion, events.TemperatureStoredSchemaVersion) // and the schema version
+	is.True(!stored[0].Duplicate)                                            // and it should not be a duplicate
+}
+
+func TestThatDuplicatesArePublishedWithTheStoredReading(t *testing.T) {
+	is := is.New(t)
+	ingester, messenger := newTestIngester(t)
+
+	m, err := ingester.Store(context.Background(), newTestMessage("sensor", "2021-11-01T12:00:00Z"), 12.7, 0.0, validation.KindAir, log.Logger)
+	is.NoErr(err)
+
+	_, err = ingester.Store(context.Background(), newTestMessage("sensor", "2021-11-01T12:00:00Z"), 12.7, 0.0, validation.KindAir, log.Logger)
+	is.True(errors.Is(err, database.ErrAlreadyExists)) // the second reading should be a duplicate
+
+	stored := messenger.stored()
+	is.Equal(len(stored), 2)     // both readings should be published
+	is.True(stored[1].Duplicate) // the second one as a duplicate
+	is.Equal(stored[1].ID, m.ID) // of the reading that was stored first
+}
+
+func TestThatReadingsAtDifferentDepthsAreNotDuplicates(t *testing.T) {
+	is := is.New(t)
+	ingester, _ := newTestIngester(t)
+
+	_, err := ingester.Store(context.Background(), newTestMessage("buoy", "2021-07-01T12:00:00Z"), 19.2, 0.0, validation.KindWater, log.Logger)
+	is.NoErr(err) // the surface reading should be stored
+
+	m, err := ingester.Store(context.Background(), newTestMessage("buoy", "2021-07-01T12:00:00Z"), 14.1, 5.0, validation.KindWater, log.Logger)
+	is.NoErr(err)          // the reading at five metres should also be stored
+	is.Equal(m.Depth, 5.0) // together with its depth
+
+	_, err = ingester.Store(context.Background(), newTestMessage("buoy", "2021-07-01T12:00:00Z"), 14.1, 5.0, validation.KindWater, log.Logger)
+	is.True(errors.Is(err, database.ErrAlreadyExists)) // but a second reading at the same depth is a duplicate
+}
+
+type mockMessenger struct {
+	published []messaging.TopicMessage
+	responses []messaging.CommandMessage
+}
+
+func (m *mockMessenger) PublishOnTopic(message messaging.TopicMessage) error {
+	m.published = append(m.published, message)
+	return nil
+}
+
+func (m *mockMessenger) NoteToSelf(message messaging.CommandMessage) error {
+	return nil
+}
+
+func (m *mockMessenger) SendResponseTo(response messaging.CommandMessage, key string) error {
+	m.responses = append(m.responses, response)
+	return nil
+}
+
+func (m *mockMessenger) stored() []*events.TemperatureStored {
+	stored := []*events.TemperatureStored{}
+	for _, msg := range m.published {
+		if e, ok := msg.(*events.TemperatureStored); ok {
+			stored = append(stored, e)
+		}
+	}
+	return stored
+}
+
+func newTestIngester(t *testing.T) (*Ingester, *mockMessenger) {
+	db, err := database.NewDatabaseConnection(database.NewSQLiteConnector(log.Logger))
+	if err != nil {
+		t.Fatalf("failed to create database: %s", err.Error())
+	}
+
+	alerts, err := alerting.NewEngine(db)
+	if err != nil {
+		t.Fatalf("failed to create alerting engine: %s", err.Error())
+	}
+
+	messenger := &mockMessenger{}
+	ingester := NewIngester(
+		db,
+		validation.NewValidator(validation.DefaultConfig()),
+		anomaly.NewDetector(anomaly.DefaultConfig()),
+		alerts,
+		messenger,
+		config.Default().Precision,
+	)
+
+	return ingester, messenger
+}
+
+func newTestMessage(device, timestamp string) messaging.IoTHubMessage {
+	return messaging.IoTHubMessage{
+		Origin:    messaging.IoTHubMessageOrigin{Device: device, Latitude: 62.39, Longitude: 17.30},
+		Timestamp: timestamp,
+	}
+}
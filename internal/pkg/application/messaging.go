@@ -1,135 +1,347 @@
 package application
 
 import (
+	"context"
 	"encoding/json"
-	"math"
+	"errors"
+	"fmt"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/rs/zerolog"
 
+	"github.com/diwise/api-temperature/internal/pkg/application/units"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/metrics"
 	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/database"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/tenancy"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/tracing"
 	"github.com/diwise/api-temperature/pkg/infrastructure/messaging/commands"
 	"github.com/diwise/messaging-golang/pkg/messaging"
-	"github.com/diwise/messaging-golang/pkg/messaging/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 //MessagingContext is an interface that allows mocking of messaging.Context parameters
 type MessagingContext interface {
 	PublishOnTopic(message messaging.TopicMessage) error
 	NoteToSelf(message messaging.CommandMessage) error
+	SendResponseTo(response messaging.CommandMessage, key string) error
 }
 
-func NewStoreTemperatureCommandHandler(db database.Datastore, messenger MessagingContext) messaging.CommandHandler {
+//storeTemperatureCommand has the same layout as all of the store temperature update commands
+type storeTemperatureCommand struct {
+	messaging.IoTHubMessage
+	Temp  float64 `json:"temp"`
+	Depth float64 `json:"depth,omitempty"`
+	commands.UnitScope
+	commands.TenantScope
+	commands.Reply
+}
+
+//NewStoreTemperatureCommandHandler returns a handler that stores temperatures of the provided kind
+//sent as commands and, if the sender asks for it, replies with the outcome
+func NewStoreTemperatureCommandHandler(ingester *Ingester, messenger MessagingContext, kind string) messaging.CommandHandler {
 	return func(wrapper messaging.CommandMessageWrapper, log zerolog.Logger) error {
 
-		cmd := &commands.StoreTemperatureUpdate{}
+		topic := commandTopic(wrapper)
+		metrics.MessageReceived(topic)
+
+		cmd := &storeTemperatureCommand{}
 		err := json.Unmarshal(wrapper.Body(), cmd)
 		if err != nil {
 			log.Error().Err(err).Msg("failed to unmarshal command")
+			metrics.MessageProcessed(topic, commands.StatusRejected)
+			return err
+		}
+
+		// The queue lag of commands is not observed, since the command wrapper does not expose
+		// when the command was published
+
+		ctx, span := startReceiveSpan(context.Background(), topic)
+		defer span.End()
+
+		ctx, err = withTenant(ctx, cmd.Tenant)
+		if err != nil {
+			log.Error().Err(err).Msg("rejected command")
+			metrics.MessageProcessed(topic, commands.StatusRejected)
+			replyToCommand(messenger, cmd.Reply, nil, err, log)
+			return err
+		}
+
+		temp, err := toCelsius(cmd.Temp, cmd.Unit)
+		if err != nil {
+			log.Error().Err(err).Msg("rejected command")
+			metrics.MessageProcessed(topic, commands.StatusRejected)
+			replyToCommand(messenger, cmd.Reply, nil, err, log)
 			return err
 		}
 
-		_, err = db.AddTemperatureMeasurement(
-			&cmd.Origin.Device,
-			cmd.Origin.Latitude, cmd.Origin.Longitude,
-			float64(math.Round(cmd.Temp*10)/10),
-			false,
-			cmd.Timestamp,
-		)
+		m, err := ingester.Store(ctx, cmd.IoTHubMessage, temp, cmd.Depth, kind, log)
+		metrics.MessageProcessed(topic, commandStatus(err))
+		span.SetAttributes(attribute.String("outcome", commandStatus(err)))
 
 		if err != nil {
 			log.Error().Err(err).Msg("failed to add temperature measurement")
 		}
 
+		replyToCommand(messenger, cmd.Reply, m, err, log)
+
 		return err
 	}
 }
 
-func NewStoreWaterTemperatureCommandHandler(db database.Datastore, messenger MessagingContext) messaging.CommandHandler {
+//NewImportTemperatureSeriesCommandHandler returns a handler that imports a series of historical
+//temperatures and, if the sender asks for it, replies with the outcome of each reading
+func NewImportTemperatureSeriesCommandHandler(ingester *Ingester, messenger MessagingContext) messaging.CommandHandler {
 	return func(wrapper messaging.CommandMessageWrapper, log zerolog.Logger) error {
-		cmd := &commands.StoreWaterTemperatureUpdate{}
+		topic := commandTopic(wrapper)
+		metrics.MessageReceived(topic)
+
+		cmd := &commands.ImportTemperatureSeries{}
 		err := json.Unmarshal(wrapper.Body(), cmd)
 		if err != nil {
 			log.Error().Err(err).Msg("failed to unmarshal command")
+			metrics.MessageProcessed(topic, commands.StatusRejected)
 			return err
 		}
 
-		_, err = db.AddTemperatureMeasurement(
-			&cmd.Origin.Device,
-			cmd.Origin.Latitude, cmd.Origin.Longitude,
-			float64(math.Round(cmd.Temp*10)/10),
-			true,
-			cmd.Timestamp,
-		)
+		items := []SeriesItem{}
+		for _, r := range cmd.Readings {
+			items = append(items, SeriesItem{Msg: r.IoTHubMessage, Temp: r.Temp, Unit: r.Unit, Depth: r.Depth, Kind: r.Kind})
+		}
+
+		result := &commands.ImportTemperatureSeriesResult{
+			CorrelationID: cmd.CorrelationID,
+			Status:        commands.StatusStored,
+		}
+
+		ctx, span := startReceiveSpan(context.Background(), topic)
+		defer span.End()
+
+		var outcomes []error
+
+		ctx, err = withTenant(ctx, cmd.Tenant)
+		if err == nil {
+			_, outcomes, err = ingester.Import(ctx, items, log)
+		}
 
 		if err != nil {
-			log.Error().Err(err).Msg("failed to add temperature measurement")
+			log.Error().Err(err).Msg("failed to import temperature series")
+			result.Status = commandStatus(err)
+			result.Reason = err.Error()
+		}
+
+		for idx, outcome := range outcomes {
+			status := commandStatus(outcome)
+
+			switch status {
+			case commands.StatusStored:
+				result.Stored++
+				continue
+			case commands.StatusDuplicate:
+				result.Duplicates++
+			case commands.StatusRejected:
+				result.Rejected++
+			}
+
+			result.Items = append(result.Items, commands.SeriesItemResult{Index: idx, Status: status, Reason: outcome.Error()})
+		}
+
+		metrics.MessageProcessed(topic, result.Status)
+		span.SetAttributes(attribute.String("outcome", result.Status))
+
+		log.Info().Msgf("imported %d of %d temperatures (%d duplicates, %d rejected)",
+			result.Stored, len(items), result.Duplicates, result.Rejected)
+
+		if cmd.WantsReply() {
+			if replyErr := messenger.SendResponseTo(result, cmd.ReplyTo); replyErr != nil {
+				log.Error().Err(replyErr).Str("replyTo", cmd.ReplyTo).Msg("failed to reply to command")
+			}
 		}
 
 		return err
 	}
 }
 
-func NewTemperatureReceiver(db database.Datastore) messaging.TopicMessageHandler {
-	return func(msg amqp.Delivery, log zerolog.Logger) {
+//commandTopic returns the content type of a command, which is what identifies commands in the metrics
+func commandTopic(wrapper messaging.CommandMessageWrapper) string {
+	if typed, ok := wrapper.(interface{ ContentType() string }); ok && typed.ContentType() != "" {
+		return typed.ContentType()
+	}
 
-		log.Info().Str("body", string(msg.Body)).Msg("message received from queue")
+	return "command"
+}
 
-		telTemp := &telemetry.Temperature{}
-		err := json.Unmarshal(msg.Body, telTemp)
+//observeQueueLag records how long a message took to reach this service, counted from when it was
+//published. Messages that the publisher did not set a timestamp on are not observed.
+func observeQueueLag(topic string, published time.Time) {
+	if published.IsZero() {
+		return
+	}
 
-		if err != nil {
-			log.Error().Err(err).Msg("failed to unmarshal message")
-			return
-		}
+	metrics.ObserveQueueLag(topic, published)
+}
 
-		if telTemp.Timestamp == "" {
-			log.Warn().Msg("ignored temperature message with an empty timestamp")
-			return
-		}
+//startReceiveSpan starts a consumer span for a message that was received on a topic
+func startReceiveSpan(ctx context.Context, topic string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, topic+" receive",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("rabbitmq"),
+			semconv.MessagingDestinationNameKey.String(topic),
+			semconv.MessagingOperationReceive,
+		),
+	)
+}
 
-		_, err = db.AddTemperatureMeasurement(
-			&telTemp.Origin.Device,
-			telTemp.Origin.Latitude, telTemp.Origin.Longitude,
-			float64(math.Round(telTemp.Temp*10)/10),
-			false,
-			telTemp.Timestamp,
-		)
+func replyToCommand(messenger MessagingContext, reply commands.Reply, m *models.TemperatureV2, err error, log zerolog.Logger) {
+	if !reply.WantsReply() {
+		return
+	}
 
-		if err != nil {
-			log.Error().Err(err).Msg("failed to add temperature measurement")
-		}
+	result := &commands.StoreTemperatureUpdateResult{
+		CorrelationID: reply.CorrelationID,
+		Status:        commandStatus(err),
 	}
+
+	if err == nil {
+		result.ID = m.ID
+	} else {
+		result.Reason = err.Error()
+	}
+
+	if err = messenger.SendResponseTo(result, reply.ReplyTo); err != nil {
+		log.Error().Err(err).Str("replyTo", reply.ReplyTo).Msg("failed to reply to command")
+	}
+}
+
+//commandStatus maps the outcome of storing a temperature to the status that is reported to producers
+func commandStatus(err error) string {
+	if err == nil {
+		return commands.StatusStored
+	}
+
+	if errors.Is(err, database.ErrAlreadyExists) {
+		return commands.StatusDuplicate
+	} else if isPermanent(err) || errors.Is(err, database.ErrInvalidTimestamp) {
+		return commands.StatusRejected
+	}
+
+	return commands.StatusFailed
 }
 
-func NewWaterTempReceiver(db database.Datastore) messaging.TopicMessageHandler {
-	return func(msg amqp.Delivery, log zerolog.Logger) {
+//telemetryTemperature has the same layout as all of the temperature telemetry messages
+type telemetryTemperature struct {
+	messaging.IoTHubMessage
+	Temp   float64 `json:"temp"`
+	Unit   string  `json:"unit,omitempty"`
+	Depth  float64 `json:"depth,omitempty"`
+	Tenant string  `json:"tenant,omitempty"`
+}
+
+//tenantHeader is the AMQP header that producers use to tell which tenant a message belongs to
+const tenantHeader string = "tenant"
+
+//withTenant returns a copy of ctx that carries the provided tenant, or ctx itself if no tenant
+//was provided. Messages for invalid tenants can never be stored, so they are rejected as permanent.
+func withTenant(ctx context.Context, tenant string) (context.Context, error) {
+	if tenant == "" {
+		return ctx, nil
+	}
+
+	if err := tenancy.Validate(tenant); err != nil {
+		return ctx, permanent(err)
+	}
+
+	return tenancy.WithTenant(ctx, tenant), nil
+}
+
+//toCelsius converts a reported temperature to Celsius, which is what all temperatures are stored
+//in. Temperatures in unknown units can never be stored, so they are rejected as permanent.
+func toCelsius(temp float64, unit string) (float64, error) {
+	code, err := units.Parse(unit)
+	if err != nil {
+		return 0, permanent(err)
+	}
+
+	return units.ToCelsius(temp, code), nil
+}
+
+//NewTemperatureReceiver returns a handler that stores temperature telemetry of the provided kind in the datastore
+func NewTemperatureReceiver(ingester *Ingester, kind string) TelemetryHandler {
+	return func(msg amqp.Delivery, log zerolog.Logger) error {
 
 		log.Info().Str("body", string(msg.Body)).Msg("message received from queue")
 
-		telTemp := &telemetry.WaterTemperature{}
+		telTemp := &telemetryTemperature{}
 		err := json.Unmarshal(msg.Body, telTemp)
 
 		if err != nil {
-			log.Error().Err(err).Msg("failed to unmarshal message")
-			return
+			metrics.MessageProcessed(msg.RoutingKey, commands.StatusRejected)
+			return permanent(fmt.Errorf("failed to unmarshal message: %s", err.Error()))
 		}
 
 		if telTemp.Timestamp == "" {
-			log.Warn().Msg("ignored water temperature message with an empty timestamp.")
-			return
+			metrics.MessageProcessed(msg.RoutingKey, commands.StatusRejected)
+			return permanent(fmt.Errorf("%s temperature message has an empty timestamp", kind))
+		}
+
+		observeQueueLag(msg.RoutingKey, msg.Timestamp)
+
+		ctx, span := startReceiveSpan(tracing.ExtractFromAMQP(context.Background(), msg.Headers), msg.RoutingKey)
+		defer span.End()
+
+		// The tenant in the message metadata takes precedence over one in the body
+		tenant := telTemp.Tenant
+		if header, ok := msg.Headers[tenantHeader].(string); ok && header != "" {
+			tenant = header
 		}
 
-		_, err = db.AddTemperatureMeasurement(
-			&telTemp.Origin.Device,
-			telTemp.Origin.Latitude, telTemp.Origin.Longitude,
-			float64(math.Round(telTemp.Temp*10)/10),
-			true,
-			telTemp.Timestamp,
-		)
+		ctx, err = withTenant(ctx, tenant)
+		if err != nil {
+			metrics.MessageProcessed(msg.RoutingKey, commands.StatusRejected)
+			return err
+		}
 
+		temp, err := toCelsius(telTemp.Temp, telTemp.Unit)
 		if err != nil {
-			log.Error().Err(err).Msg("failed to add temperature measurement")
+			metrics.MessageProcessed(msg.RoutingKey, commands.StatusRejected)
+			return err
+		}
+
+		_, err = ingester.Store(ctx, telTemp.IoTHubMessage, temp, telTemp.Depth, kind, log)
+		span.SetAttributes(attribute.String("outcome", commandStatus(err)))
+
+		// Transient failures are retried by the failure handler, which counts the outcome if it gives up
+		if status := commandStatus(err); status != commands.StatusFailed {
+			metrics.MessageProcessed(msg.RoutingKey, status)
 		}
+
+		return handleStoreError(err, log)
 	}
 }
+
+//handleStoreError classifies errors from the datastore into duplicates that can
+//safely be ignored, permanent failures and transient (retryable) failures
+func handleStoreError(err error, log zerolog.Logger) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, database.ErrAlreadyExists) {
+		log.Info().Msg("ignored duplicate temperature measurement")
+		return nil
+	}
+
+	if isPermanent(err) {
+		return err
+	}
+
+	if errors.Is(err, database.ErrInvalidTimestamp) {
+		return permanent(err)
+	}
+
+	return fmt.Errorf("failed to add temperature measurement: %w", err)
+}
@@ -0,0 +1,178 @@
+package application
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/rs/zerolog/log"
+
+	"github.com/diwise/api-temperature/internal/pkg/application/validation"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+	"github.com/diwise/api-temperature/pkg/infrastructure/messaging/commands"
+	"github.com/diwise/messaging-golang/pkg/messaging"
+	"github.com/diwise/messaging-golang/pkg/messaging/telemetry"
+)
+
+func TestThatCommandsAreRepliedTo(t *testing.T) {
+	is := is.New(t)
+	ingester, messenger := newTestIngester(t)
+	handler := NewStoreTemperatureCommandHandler(ingester, messenger, validation.KindAir)
+
+	cmd := newStoreTemperatureCommand(12.7, "2021-11-01T12:00:00Z", "producer", "abc")
+
+	handler(newMockCommand(cmd), log.Logger)
+	handler(newMockCommand(cmd), log.Logger)
+
+	is.Equal(len(messenger.responses), 2) // both commands should be replied to
+
+	first := messenger.responses[0].(*commands.StoreTemperatureUpdateResult)
+	is.Equal(first.CorrelationID, "abc")          // the reply should carry the correlation id
+	is.Equal(first.Status, commands.StatusStored) // the first reading should be stored
+	is.True(first.ID != 0)                        // and the reply should contain its id
+
+	second := messenger.responses[1].(*commands.StoreTemperatureUpdateResult)
+	is.Equal(second.Status, commands.StatusDuplicate) // the second reading should be a duplicate
+}
+
+func TestThatRejectedCommandsAreRepliedTo(t *testing.T) {
+	is := is.New(t)
+	ingester, messenger := newTestIngester(t)
+	handler := NewStoreTemperatureCommandHandler(ingester, messenger, validation.KindAir)
+
+	handler(newMockCommand(newStoreTemperatureCommand(120.0, "2021-11-01T12:00:00Z", "producer", "abc")), log.Logger)
+
+	is.Equal(len(messenger.responses), 1)
+	result := messenger.responses[0].(*commands.StoreTemperatureUpdateResult)
+	is.Equal(result.Status, commands.StatusRejected) // implausible readings should be rejected
+	is.True(result.Reason != "")                     // with a reason
+}
+
+func TestThatCommandsWithoutCorrelationIDAreNotRepliedTo(t *testing.T) {
+	is := is.New(t)
+	ingester, messenger := newTestIngester(t)
+	handler := NewStoreTemperatureCommandHandler(ingester, messenger, validation.KindAir)
+
+	handler(newMockCommand(newStoreTemperatureCommand(12.7, "2021-11-01T12:00:00Z", "producer", "")), log.Logger)
+
+	is.Equal(len(messenger.responses), 0) // no reply should be sent
+}
+
+func TestThatCommandsAreStoredPerTenant(t *testing.T) {
+	is := is.New(t)
+	ingester, messenger := newTestIngester(t)
+	handler := NewStoreTemperatureCommandHandler(ingester, messenger, validation.KindAir)
+
+	cmd := newStoreTemperatureCommand(12.7, "2021-11-01T12:00:00Z", "producer", "abc")
+	handler(newMockCommand(cmd), log.Logger)
+
+	cmd.Tenant = "sundsvall"
+	handler(newMockCommand(cmd), log.Logger)
+
+	cmd.Tenant = "not a tenant"
+	handler(newMockCommand(cmd), log.Logger)
+
+	is.Equal(len(messenger.responses), 3)
+
+	second := messenger.responses[1].(*commands.StoreTemperatureUpdateResult)
+	is.Equal(second.Status, commands.StatusStored) // the same reading should be stored once per tenant
+
+	third := messenger.responses[2].(*commands.StoreTemperatureUpdateResult)
+	is.Equal(third.Status, commands.StatusRejected) // and invalid tenants should be rejected
+
+	stored := messenger.stored()
+	is.Equal(len(stored), 2)
+	is.Equal(stored[0].Tenant, models.DefaultTenant) // the stored events should tell which tenant they belong to
+	is.Equal(stored[1].Tenant, "sundsvall")
+}
+
+func TestThatTemperaturesInOtherUnitsAreConvertedToCelsius(t *testing.T) {
+	is := is.New(t)
+	ingester, messenger := newTestIngester(t)
+	handler := NewStoreTemperatureCommandHandler(ingester, messenger, validation.KindAir)
+
+	cmd := newStoreTemperatureCommand(54.5, "2021-11-01T12:00:00Z", "producer", "abc")
+	cmd.Unit = "FAH"
+	handler(newMockCommand(cmd), log.Logger)
+
+	cmd = newStoreTemperatureCommand(285.85, "2021-11-01T13:00:00Z", "producer", "abc")
+	cmd.Unit = "KEL"
+	handler(newMockCommand(cmd), log.Logger)
+
+	cmd = newStoreTemperatureCommand(12.7, "2021-11-01T14:00:00Z", "producer", "abc")
+	cmd.Unit = "RAN"
+	handler(newMockCommand(cmd), log.Logger)
+
+	is.Equal(len(messenger.responses), 3)
+
+	third := messenger.responses[2].(*commands.StoreTemperatureUpdateResult)
+	is.Equal(third.Status, commands.StatusRejected) // temperatures in unknown units should be rejected
+
+	stored := messenger.stored()
+	is.Equal(len(stored), 2)
+	is.Equal(stored[0].Temp, 12.5) // Fahrenheit should be stored as Celsius
+	is.Equal(stored[1].Temp, 12.7) // and so should kelvin
+}
+
+func TestThatImportedSeriesAreReportedPerItem(t *testing.T) {
+	is := is.New(t)
+	ingester, messenger := newTestIngester(t)
+	handler := NewImportTemperatureSeriesCommandHandler(ingester, messenger)
+
+	reading := func(temp float64, timestamp string) commands.SeriesReading {
+		return commands.SeriesReading{IoTHubMessage: newTestMessage("lake", timestamp), Kind: commands.KindWater, Temp: temp}
+	}
+
+	cmd := &commands.ImportTemperatureSeries{
+		Readings: []commands.SeriesReading{
+			reading(4.1, "2018-05-01T12:00:00Z"),
+			reading(4.3, "2018-05-01T13:00:00Z"),
+			reading(99.0, "2018-05-01T14:00:00Z"),
+			reading(4.3, "2018-05-01T13:00:00Z"),
+		},
+		Reply: commands.Reply{ReplyTo: "producer", CorrelationID: "abc"},
+	}
+
+	err := handler(newMockCommand(cmd), log.Logger)
+	is.NoErr(err) // the import should succeed
+
+	is.Equal(len(messenger.responses), 1)
+	result := messenger.responses[0].(*commands.ImportTemperatureSeriesResult)
+	is.Equal(result.Stored, 2)     // two readings should be stored
+	is.Equal(result.Rejected, 1)   // one reading should be rejected
+	is.Equal(result.Duplicates, 1) // and one should be a duplicate
+	is.Equal(len(result.Items), 2) // only the readings that were not stored should be listed
+	is.Equal(result.Items[0].Index, 2)
+	is.Equal(result.Items[0].Status, commands.StatusRejected)
+	is.Equal(result.Items[1].Index, 3)
+	is.Equal(result.Items[1].Status, commands.StatusDuplicate)
+
+	is.Equal(len(messenger.stored()), 2) // stored events should be published for the stored readings
+}
+
+type mockCommand struct {
+	body []byte
+}
+
+func newMockCommand(cmd messaging.CommandMessage) *mockCommand {
+	body, _ := json.Marshal(cmd)
+	return &mockCommand{body: body}
+}
+
+func (c *mockCommand) Body() []byte {
+	return c.body
+}
+
+func (c *mockCommand) RespondWith(messaging.CommandMessage) error {
+	return nil
+}
+
+func newStoreTemperatureCommand(temp float64, timestamp, replyTo, correlationID string) *commands.StoreTemperatureUpdate {
+	return &commands.StoreTemperatureUpdate{
+		Temperature: telemetry.Temperature{
+			IoTHubMessage: newTestMessage("sensor", timestamp),
+			Temp:          temp,
+		},
+		Reply: commands.Reply{ReplyTo: replyTo, CorrelationID: correlationID},
+	}
+}
@@ -0,0 +1,80 @@
+package precision
+
+import (
+	"math"
+	"path"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+)
+
+//Resolutions knows the resolution, in degrees, of the sensors that readings come from
+type Resolutions struct {
+	defaultResolution float64
+	kinds             map[string]float64
+	devices           []config.DeviceResolution
+}
+
+//NewResolutions creates Resolutions from the precision configuration
+func NewResolutions(cfg config.Precision) *Resolutions {
+	return &Resolutions{
+		defaultResolution: cfg.DefaultResolution,
+		kinds:             cfg.Kinds,
+		devices:           cfg.Devices,
+	}
+}
+
+//Of returns the resolution of the sensor of a device that reports readings of a kind. Device patterns
+//are tried in the order that they are configured, before the resolution of the kind of reading.
+func (r *Resolutions) Of(device, kind string) float64 {
+	for _, d := range r.devices {
+		if ok, _ := path.Match(d.Pattern, device); ok {
+			return d.Resolution
+		}
+	}
+
+	if resolution, ok := r.kinds[kind]; ok {
+		return resolution
+	}
+
+	return r.defaultResolution
+}
+
+//Rounder rounds temperatures for presentation by an API
+type Rounder struct {
+	decimals     int
+	byResolution bool
+}
+
+//Default rounds to one decimal, which is how temperatures have always been presented
+var Default = Rounder{decimals: 1}
+
+//NewRounder creates a Rounder from the rounding configuration of an API
+func NewRounder(cfg config.Rounding) Rounder {
+	return Rounder{decimals: cfg.Decimals, byResolution: cfg.ByResolution}
+}
+
+//Round rounds a temperature that was measured with the provided resolution. A resolution of zero
+//means that the resolution of the reading is unknown.
+func (r Rounder) Round(value, resolution float64) float64 {
+	if !r.byResolution || resolution <= 0 {
+		return roundToDecimals(value, r.decimals)
+	}
+
+	// The quotient of value and a resolution like 0.1 is seldom exact, so the result is rounded
+	// to the number of decimals of the resolution to get rid of any trailing noise
+	return roundToDecimals(math.Round(value/resolution)*resolution, decimalsOf(resolution))
+}
+
+func roundToDecimals(value float64, decimals int) float64 {
+	scale := math.Pow10(decimals)
+	return math.Round(value*scale) / scale
+}
+
+//decimalsOf returns the number of decimals that are needed to represent a resolution
+func decimalsOf(resolution float64) int {
+	decimals := 0
+	for decimals < 6 && math.Abs(resolution-roundToDecimals(resolution, decimals)) > 1e-9 {
+		decimals++
+	}
+	return decimals
+}
@@ -0,0 +1,43 @@
+package precision
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+)
+
+func TestThatResolutionsAreLookedUpByDeviceThenKind(t *testing.T) {
+	is := is.New(t)
+
+	r := NewResolutions(config.Precision{
+		DefaultResolution: 0.1,
+		Kinds:             map[string]float64{"water": 0.05},
+		Devices: []config.DeviceResolution{
+			{Pattern: "buoy-*", Resolution: 0.01},
+			{Pattern: "*", Resolution: 0.5},
+		},
+	})
+
+	is.Equal(r.Of("buoy-3", "water"), 0.01) // the first matching device pattern should win
+	is.Equal(r.Of("street", "air"), 0.5)    // over the resolution of the kind
+
+	r = NewResolutions(config.Precision{DefaultResolution: 0.1, Kinds: map[string]float64{"water": 0.05}})
+	is.Equal(r.Of("lake", "water"), 0.05) // the kind should be used if no device matches
+	is.Equal(r.Of("street", "air"), 0.1)  // and the default if neither does
+}
+
+func TestThatTemperaturesAreRoundedForPresentation(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(Default.Round(12.3456, 0.01), 12.3) // the default should round to one decimal
+
+	twoDecimals := NewRounder(config.Rounding{Decimals: 2})
+	is.Equal(twoDecimals.Round(12.3456, 0.1), 12.35)
+
+	byResolution := NewRounder(config.Rounding{Decimals: 1, ByResolution: true})
+	is.Equal(byResolution.Round(12.3456, 0.01), 12.35) // high precision sensors should keep their precision
+	is.Equal(byResolution.Round(12.3456, 0.5), 12.5)   // and coarse sensors should not be more precise than they are
+	is.Equal(byResolution.Round(12.3456, 0), 12.3)     // readings without a resolution should be rounded to decimals
+}
@@ -0,0 +1,154 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/database"
+)
+
+//readinessTimeout limits how long the readiness checks may take together, so that a hanging
+//dependency is reported as down rather than making the probe itself time out
+const readinessTimeout = 3 * time.Second
+
+//HealthCheck checks that a dependency of the service is working and returns an error if it is not
+type HealthCheck func(ctx context.Context) error
+
+//Dependency is a named HealthCheck
+type Dependency struct {
+	Name  string
+	Check HealthCheck
+}
+
+//NewDatabaseCheck returns a check that pings the database
+func NewDatabaseCheck(db database.Datastore) HealthCheck {
+	return func(ctx context.Context) error {
+		return db.WithContext(ctx).Ping()
+	}
+}
+
+//NewMigrationCheck returns a check that reports whether the database schema has been migrated
+func NewMigrationCheck(db database.Datastore) HealthCheck {
+	return func(ctx context.Context) error {
+		return db.MigrationStatus()
+	}
+}
+
+//BrokerChannel is a channel to the message broker that knows whether it is still open
+type BrokerChannel interface {
+	IsClosed() bool
+}
+
+//NewMessagingCheck returns a check that fails if the channel to the message broker, or its connection,
+//has been closed. Only the state of the channel is inspected, so that probes do not publish anything.
+func NewMessagingCheck(channel BrokerChannel) HealthCheck {
+	return func(ctx context.Context) error {
+		if channel.IsClosed() {
+			return errors.New("channel/connection is not open")
+		}
+		return nil
+	}
+}
+
+//DefaultDependencies returns the dependencies that the service needs in order to be ready for traffic.
+//The broker channel may be nil if messaging has been disabled, in which case it is not checked.
+func DefaultDependencies(db database.Datastore, broker BrokerChannel) []Dependency {
+	dependencies := []Dependency{
+		{Name: "database", Check: NewDatabaseCheck(db)},
+		{Name: "migrations", Check: NewMigrationCheck(db)},
+	}
+
+	if broker != nil {
+		dependencies = append(dependencies, Dependency{Name: "messaging", Check: NewMessagingCheck(broker)})
+	}
+
+	return dependencies
+}
+
+const (
+	statusUp   = "up"
+	statusDown = "down"
+)
+
+type dependencyStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type readinessReport struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]dependencyStatus `json:"dependencies"`
+}
+
+func (r *readinessReport) add(name string, err error) {
+	if err != nil {
+		r.Status = statusDown
+		r.Dependencies[name] = dependencyStatus{Status: statusDown, Error: err.Error()}
+		return
+	}
+
+	r.Dependencies[name] = dependencyStatus{Status: statusUp}
+}
+
+//checkReadiness runs the checks of all dependencies concurrently and reports the status of each
+func checkReadiness(ctx context.Context, dependencies []Dependency) readinessReport {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	type outcome struct {
+		name string
+		err  error
+	}
+
+	outcomes := make(chan outcome, len(dependencies))
+	pending := map[string]bool{}
+
+	for _, d := range dependencies {
+		pending[d.Name] = true
+		go func(d Dependency) {
+			outcomes <- outcome{name: d.Name, err: d.Check(ctx)}
+		}(d)
+	}
+
+	report := readinessReport{Status: statusUp, Dependencies: map[string]dependencyStatus{}}
+
+	for len(pending) > 0 {
+		select {
+		case o := <-outcomes:
+			delete(pending, o.name)
+			report.add(o.name, o.err)
+		case <-ctx.Done():
+			for name := range pending {
+				delete(pending, name)
+				report.add(name, errors.New("timed out"))
+			}
+		}
+	}
+
+	return report
+}
+
+//addProbeHandlers registers a liveness probe, that succeeds as long as the service is able to
+//answer requests, and a readiness probe, that only succeeds when all dependencies are working
+func (router *RequestRouter) addProbeHandlers(dependencies []Dependency) {
+	live := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	// /health is kept as an alias of the liveness probe for existing deployments
+	router.Get("/health", live)
+	router.Get("/health/live", live)
+
+	router.Get("/health/ready", func(w http.ResponseWriter, r *http.Request) {
+		report := checkReadiness(r.Context(), dependencies)
+
+		statusCode := http.StatusOK
+		if report.Status != statusUp {
+			statusCode = http.StatusServiceUnavailable
+		}
+
+		writeJSON(w, statusCode, report)
+	})
+}
@@ -0,0 +1,67 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/rs/zerolog/log"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/database"
+)
+
+func TestThatReadinessIsReportedPerDependency(t *testing.T) {
+	is := is.New(t)
+
+	db, err := database.NewDatabaseConnection(database.NewSQLiteConnector(log.Logger))
+	is.NoErr(err)
+
+	router := newRequestRouter(config.Default(), nil)
+	router.addProbeHandlers(DefaultDependencies(db, brokerChannelStub{closed: false}))
+
+	w := httptest.NewRecorder()
+	router.impl.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+
+	report := readinessReport{}
+	is.NoErr(json.NewDecoder(w.Body).Decode(&report))
+
+	is.Equal(w.Code, http.StatusOK)                              // the service should be ready
+	is.Equal(len(report.Dependencies), 3)                        // and report on all dependencies
+	is.Equal(report.Dependencies["migrations"].Status, statusUp) // including the migrations
+}
+
+func TestThatFailingDependenciesMakeTheServiceUnavailable(t *testing.T) {
+	is := is.New(t)
+
+	router := newRequestRouter(config.Default(), nil)
+	router.addProbeHandlers([]Dependency{
+		{Name: "database", Check: func(context.Context) error { return nil }},
+		{Name: "messaging", Check: NewMessagingCheck(brokerChannelStub{closed: true})},
+	})
+
+	w := httptest.NewRecorder()
+	router.impl.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+
+	report := readinessReport{}
+	is.NoErr(json.NewDecoder(w.Body).Decode(&report))
+
+	is.Equal(w.Code, http.StatusServiceUnavailable)                                    // the service should not be ready
+	is.Equal(report.Dependencies["database"].Status, statusUp)                         // working dependencies should be reported as up
+	is.Equal(report.Dependencies["messaging"].Error, "channel/connection is not open") // and failing ones with their error
+
+	w = httptest.NewRecorder()
+	router.impl.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	is.Equal(w.Code, http.StatusOK) // the service should still be alive
+}
+
+type brokerChannelStub struct {
+	closed bool
+}
+
+func (ch brokerChannelStub) IsClosed() bool {
+	return ch.closed
+}
@@ -0,0 +1,117 @@
+package querycache
+
+import (
+	"context"
+	"time"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/database"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+)
+
+//invalidatingDatastore drops the cached results that a change to the datastore affects, and caches
+//the devices of the tenant that it is scoped to
+type invalidatingDatastore struct {
+	database.Datastore
+	cache *Cache
+
+	tenant   string
+	filtered bool
+}
+
+//InvalidatingDatastore wraps db so that every reading that is stored, corrected or deleted through
+//it, or whose calibration is applied or removed, invalidates the cached results that the reading
+//could have been part of. The devices of a tenant are cached as well, and kept up to date with the
+//readings that are stored through the wrapped datastore.
+func InvalidatingDatastore(db database.Datastore, cache *Cache) database.Datastore {
+	if cache == nil {
+		return db
+	}
+
+	return &invalidatingDatastore{Datastore: db, cache: cache}
+}
+
+func (db *invalidatingDatastore) AddTemperatureMeasurement(measurement models.TemperatureV2) (*models.TemperatureV2, error) {
+	m, err := db.Datastore.AddTemperatureMeasurement(measurement)
+	if err == nil {
+		db.cache.Invalidate(m.Tenant, m.Medium)
+		db.cache.AddDevice(m.Tenant, m.Device)
+	}
+	return m, err
+}
+
+//GetDevices returns the cached devices of the tenant that the datastore is scoped to, so that the
+//devices of every reading are not listed for every query
+func (db *invalidatingDatastore) GetDevices() ([]string, error) {
+	if db.tenant == "" || db.filtered {
+		return db.Datastore.GetDevices()
+	}
+
+	if devices, ok := db.cache.Devices(db.tenant); ok {
+		return devices, nil
+	}
+
+	devices, err := db.Datastore.GetDevices()
+	if err == nil {
+		db.cache.PutDevices(db.tenant, devices)
+	}
+	return devices, err
+}
+
+func (db *invalidatingDatastore) ImportTemperatureMeasurements(measurements []models.TemperatureV2) ([]error, error) {
+	outcomes, err := db.Datastore.ImportTemperatureMeasurements(measurements)
+	if err == nil {
+		for idx := range measurements {
+			if outcomes[idx] == nil {
+				db.cache.Invalidate(measurements[idx].Tenant, measurements[idx].Medium)
+				db.cache.AddDevice(measurements[idx].Tenant, measurements[idx].Device)
+			}
+		}
+	}
+	return outcomes, err
+}
+
+func (db *invalidatingDatastore) UpdateTemperatureQuality(id uint, quality, qualityReason string, correctedTemp *float64) (*models.TemperatureV2, error) {
+	m, err := db.Datastore.UpdateTemperatureQuality(id, quality, qualityReason, correctedTemp)
+	if err == nil {
+		db.cache.Invalidate(m.Tenant, m.Medium)
+	}
+	return m, err
+}
+
+func (db *invalidatingDatastore) DeleteTemperaturesBefore(before time.Time) (int64, error) {
+	deleted, err := db.Datastore.DeleteTemperaturesBefore(before)
+	if deleted > 0 {
+		db.cache.InvalidateAll()
+	}
+	return deleted, err
+}
+
+func (db *invalidatingDatastore) DeleteCalibration(id uint) error {
+	err := db.Datastore.DeleteCalibration(id)
+	if err == nil {
+		db.cache.InvalidateAll()
+	}
+	return err
+}
+
+func (db *invalidatingDatastore) ApplyCalibration(id uint) (int64, error) {
+	updated, err := db.Datastore.ApplyCalibration(id)
+	if updated > 0 {
+		db.cache.InvalidateAll()
+	}
+	return updated, err
+}
+
+func (db *invalidatingDatastore) WithContext(ctx context.Context) database.Datastore {
+	return &invalidatingDatastore{Datastore: db.Datastore.WithContext(ctx), cache: db.cache, tenant: db.tenant, filtered: db.filtered}
+}
+
+func (db *invalidatingDatastore) ForTenant(tenant string) database.Datastore {
+	return &invalidatingDatastore{Datastore: db.Datastore.ForTenant(tenant), cache: db.cache, tenant: tenant, filtered: db.filtered}
+}
+
+func (db *invalidatingDatastore) WithoutDevices(devices []string) database.Datastore {
+	// The devices that are listed by a filtered datastore depend on the filter, so they are not cached
+	filtered := db.filtered || len(devices) > 0
+	return &invalidatingDatastore{Datastore: db.Datastore.WithoutDevices(devices), cache: db.cache, tenant: db.tenant, filtered: filtered}
+}
@@ -0,0 +1,209 @@
+package querycache
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/metrics"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+)
+
+//Cache keeps the results of recent queries for a short while. Each result is tagged with the tenant
+//and the mediums that it may contain, so that it can be dropped as soon as a reading that could have
+//been part of it is stored. A nil Cache caches nothing, which is what caching disabled looks like.
+type Cache struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	devices map[string]*deviceList
+}
+
+type entry struct {
+	tenant  string
+	mediums map[string]bool
+	temps   []models.TemperatureV2
+	created time.Time
+}
+
+//deviceList is the devices that readings have been stored for within a tenant
+type deviceList struct {
+	devices []string
+	created time.Time
+}
+
+//New creates a Cache from the configuration, or returns nil if caching is disabled
+func New(cfg config.QueryCache) *Cache {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	return &Cache{
+		ttl:        cfg.TTL,
+		maxEntries: cfg.MaxEntries,
+		now:        time.Now,
+		entries:    map[string]*entry{},
+		devices:    map[string]*deviceList{},
+	}
+}
+
+//Get returns the cached result of a query, if there is one that has not expired
+func (c *Cache) Get(key string) ([]models.TemperatureV2, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if ok && c.now().Sub(e.created) >= c.ttl {
+		delete(c.entries, key)
+		ok = false
+	}
+
+	metrics.QueryCacheLookup(ok)
+
+	if !ok {
+		return nil, false
+	}
+
+	return e.temps, true
+}
+
+//Put caches the result of a query for a tenant that may contain readings of the provided mediums.
+//The result must not be modified afterwards, as it is shared by everyone that gets it.
+func (c *Cache) Put(key, tenant string, mediums []string, temps []models.TemperatureV2) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.maxEntries {
+		c.evict(now)
+	}
+
+	e := &entry{tenant: tenant, mediums: map[string]bool{}, temps: temps, created: now}
+	for _, m := range mediums {
+		e.mediums[m] = true
+	}
+
+	c.entries[key] = e
+}
+
+//evict makes room for a new entry by dropping the expired entries or, if there are none, the oldest one
+func (c *Cache) evict(now time.Time) {
+	oldestKey := ""
+	var oldest time.Time
+
+	for key, e := range c.entries {
+		if now.Sub(e.created) >= c.ttl {
+			delete(c.entries, key)
+			continue
+		}
+
+		if oldestKey == "" || e.created.Before(oldest) {
+			oldestKey, oldest = key, e.created
+		}
+	}
+
+	if len(c.entries) >= c.maxEntries {
+		delete(c.entries, oldestKey)
+	}
+}
+
+//Invalidate drops the results for a tenant that may contain readings of a medium
+func (c *Cache) Invalidate(tenant, medium string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, e := range c.entries {
+		if e.tenant == tenant && e.mediums[medium] {
+			delete(c.entries, key)
+		}
+	}
+}
+
+//InvalidateAll drops all results
+func (c *Cache) InvalidateAll() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = map[string]*entry{}
+}
+
+//Devices returns the cached devices of a tenant, if they have not expired. The devices must not be
+//modified, as they are shared by everyone that gets them.
+func (c *Cache) Devices(tenant string) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, ok := c.devices[tenant]
+	if !ok || c.now().Sub(l.created) >= c.ttl {
+		delete(c.devices, tenant)
+		return nil, false
+	}
+
+	return l.devices, true
+}
+
+//PutDevices caches the devices of a tenant, which must be sorted and not modified afterwards
+func (c *Cache) PutDevices(tenant string, devices []string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.devices[tenant] = &deviceList{devices: devices, created: c.now()}
+}
+
+//AddDevice adds a device that a reading has been stored for to the cached devices of its tenant, if
+//they are cached, so that a new device is known as soon as its first reading has been stored
+func (c *Cache) AddDevice(tenant, device string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, ok := c.devices[tenant]
+	if !ok {
+		return
+	}
+
+	idx := sort.SearchStrings(l.devices, device)
+	if idx < len(l.devices) && l.devices[idx] == device {
+		return
+	}
+
+	// The cached devices may be in use, so the devices are copied rather than changed in place
+	devices := make([]string, 0, len(l.devices)+1)
+	devices = append(devices, l.devices[:idx]...)
+	devices = append(devices, device)
+	devices = append(devices, l.devices[idx:]...)
+
+	c.devices[tenant] = &deviceList{devices: devices, created: l.created}
+}
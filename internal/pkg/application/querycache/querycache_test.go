@@ -0,0 +1,126 @@
+package querycache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/rs/zerolog/log"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/database"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+)
+
+func newTestCache() (*Cache, *time.Time) {
+	now := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+
+	c := New(config.Default().QueryCache)
+	c.now = func() time.Time { return now }
+
+	return c, &now
+}
+
+func TestThatResultsExpire(t *testing.T) {
+	is := is.New(t)
+	c, now := newTestCache()
+
+	c.Put("key", models.DefaultTenant, []string{models.MediumAir}, []models.TemperatureV2{{Device: "a"}})
+
+	temps, ok := c.Get("key")
+	is.True(ok) // a result should be found while it is fresh
+	is.Equal(len(temps), 1)
+
+	*now = now.Add(config.Default().QueryCache.TTL)
+
+	_, ok = c.Get("key")
+	is.True(!ok) // but not once it has expired
+}
+
+func TestThatResultsAreInvalidatedPerTenantAndMedium(t *testing.T) {
+	is := is.New(t)
+	c, _ := newTestCache()
+
+	c.Put("air", models.DefaultTenant, []string{models.MediumAir}, nil)
+	c.Put("water", models.DefaultTenant, []string{models.MediumWater}, nil)
+	c.Put("other", "sundsvall", []string{models.MediumAir}, nil)
+
+	c.Invalidate(models.DefaultTenant, models.MediumAir)
+
+	_, ok := c.Get("air")
+	is.True(!ok) // results that could contain the reading should be dropped
+	_, ok = c.Get("water")
+	is.True(ok) // while results for other mediums should be kept
+	_, ok = c.Get("other")
+	is.True(ok) // and so should the results of other tenants
+}
+
+func TestThatStoredReadingsInvalidateResults(t *testing.T) {
+	is := is.New(t)
+	c, _ := newTestCache()
+
+	db, err := database.NewDatabaseConnection(database.NewSQLiteConnector(log.Logger))
+	is.NoErr(err)
+	db = InvalidatingDatastore(db, c)
+
+	c.Put("water", "sundsvall", []string{models.MediumWater}, nil)
+
+	device := "lake"
+	_, err = db.ForTenant("sundsvall").AddTemperatureMeasurement(models.TemperatureV2{Device: device, Latitude: 62.39, Longitude: 17.30, Temp: 4.1, Medium: models.MediumWater, Timestamp: time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)})
+	is.NoErr(err)
+
+	_, ok := c.Get("water")
+	is.True(!ok) // storing a reading through a scoped datastore should invalidate the results of its tenant
+}
+
+func TestThatTheDevicesOfATenantAreCachedAndKeptUpToDate(t *testing.T) {
+	is := is.New(t)
+	c, now := newTestCache()
+
+	db, err := database.NewDatabaseConnection(database.NewSQLiteConnector(log.Logger))
+	is.NoErr(err)
+	db = InvalidatingDatastore(db, c)
+
+	sundsvall := db.ForTenant("sundsvall")
+	store := func(device string) {
+		_, err := sundsvall.AddTemperatureMeasurement(models.TemperatureV2{Device: device, Latitude: 62.39, Longitude: 17.30, Temp: 4.1, Medium: models.MediumWater, Timestamp: *now})
+		is.NoErr(err)
+	}
+
+	store("lake")
+
+	devices, err := sundsvall.GetDevices()
+	is.NoErr(err)
+	is.Equal(devices, []string{"lake"})
+
+	cached, ok := c.Devices("sundsvall")
+	is.True(ok) // the devices of the tenant should be cached once they have been listed
+	is.Equal(cached, []string{"lake"})
+
+	store("beach")
+
+	devices, err = sundsvall.GetDevices()
+	is.NoErr(err)
+	is.Equal(devices, []string{"beach", "lake"}) // new devices should be added to the cached devices as they are stored
+
+	*now = now.Add(config.Default().QueryCache.TTL)
+
+	_, ok = c.Devices("sundsvall")
+	is.True(!ok) // and the cached devices should expire like any other result
+}
+
+func TestThatTheOldestResultIsEvictedWhenFull(t *testing.T) {
+	is := is.New(t)
+	c, now := newTestCache()
+	c.maxEntries = 2
+
+	c.Put("first", models.DefaultTenant, nil, nil)
+	*now = now.Add(time.Second)
+	c.Put("second", models.DefaultTenant, nil, nil)
+	c.Put("third", models.DefaultTenant, nil, nil)
+
+	_, ok := c.Get("first")
+	is.True(!ok) // the oldest result should make room for the new one
+	_, ok = c.Get("third")
+	is.True(ok)
+}
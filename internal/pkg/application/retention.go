@@ -0,0 +1,49 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/database"
+)
+
+//StartRetention deletes readings that are older than the max age of the retention configuration,
+//once at startup and then at every interval, until ctx is done. Readings are kept forever if no
+//max age has been configured.
+func StartRetention(ctx context.Context, db database.Datastore, cfg config.Retention, log zerolog.Logger) {
+	if cfg.MaxAge <= 0 {
+		log.Info().Msg("retention disabled, readings are kept forever")
+		return
+	}
+
+	purge := func() {
+		before := time.Now().UTC().Add(-cfg.MaxAge)
+
+		deleted, err := db.WithContext(ctx).DeleteTemperaturesBefore(before)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to delete old readings")
+			return
+		}
+
+		log.Info().Int64("deleted", deleted).Time("before", before).Msg("deleted old readings")
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		purge()
+
+		for {
+			select {
+			case <-ticker.C:
+				purge()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,136 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/rs/zerolog"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/messaging/deadletter"
+	"github.com/diwise/messaging-golang/pkg/messaging"
+)
+
+//ErrShuttingDown is the reason that messages which arrive while the service shuts down are dead-lettered
+var ErrShuttingDown = errors.New("service is shutting down")
+
+//Drainer keeps track of the messages that are being handled, so that they can be
+//allowed to finish before the service shuts down. Once stopped, it refuses the
+//messages that arrive, so that draining is not held up by new messages.
+type Drainer struct {
+	deadletters deadletter.Store
+
+	mu       sync.Mutex
+	active   int
+	idle     chan struct{}
+	stopped  bool
+	released chan struct{}
+}
+
+//NewDrainer creates a new Drainer that routes the topic messages that it refuses to a dead letter store
+func NewDrainer(deadletters deadletter.Store) *Drainer {
+	return &Drainer{
+		deadletters: deadletters,
+		released:    make(chan struct{}),
+	}
+}
+
+//Topic decorates a topic message handler so that the messages it handles are tracked. Topic
+//messages are acknowledged as they are delivered, so the ones that arrive after the drainer has
+//been stopped are routed to the dead letter store, from which they can be replayed.
+func (d *Drainer) Topic(handler messaging.TopicMessageHandler) messaging.TopicMessageHandler {
+	return func(msg amqp.Delivery, log zerolog.Logger) {
+		if !d.begin() {
+			if err := d.deadletters.Add(msg, ErrShuttingDown, 0); err != nil {
+				log.Error().Err(err).Str("body", string(msg.Body)).Msg("failed to dead-letter message, it will be lost")
+			}
+			return
+		}
+		defer d.end()
+
+		handler(msg, log)
+	}
+}
+
+//Command decorates a command handler so that the commands it handles are tracked. Commands are
+//acknowledged once they have been handled, so the ones that arrive after the drainer has been
+//stopped are held back until it is released, when the connection to the broker has been closed
+//and the broker delivers them again.
+func (d *Drainer) Command(handler messaging.CommandHandler) messaging.CommandHandler {
+	return func(wrapper messaging.CommandMessageWrapper, log zerolog.Logger) error {
+		if !d.begin() {
+			<-d.released
+			return ErrShuttingDown
+		}
+		defer d.end()
+
+		return handler(wrapper, log)
+	}
+}
+
+//Stop makes the drainer refuse the messages that arrive from now on
+func (d *Drainer) Stop() {
+	d.mu.Lock()
+	d.stopped = true
+	d.mu.Unlock()
+}
+
+//Release lets go of the commands that have been held back since the drainer was stopped. It
+//should be called once the connection to the broker has been closed, so that they are not acknowledged.
+func (d *Drainer) Release() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	select {
+	case <-d.released:
+	default:
+		close(d.released)
+	}
+}
+
+//Drain waits until no messages are being handled, or until ctx is done
+func (d *Drainer) Drain(ctx context.Context) error {
+	d.mu.Lock()
+	if d.active == 0 {
+		d.mu.Unlock()
+		return nil
+	}
+
+	if d.idle == nil {
+		d.idle = make(chan struct{})
+	}
+	idle := d.idle
+	d.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//begin tracks a message that is about to be handled, unless the drainer has been stopped
+func (d *Drainer) begin() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return false
+	}
+
+	d.active++
+	return true
+}
+
+func (d *Drainer) end() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.active--
+	if d.active == 0 && d.idle != nil {
+		close(d.idle)
+		d.idle = nil
+	}
+}
@@ -0,0 +1,80 @@
+package application
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/messaging/deadletter"
+	"github.com/diwise/messaging-golang/pkg/messaging"
+)
+
+func TestThatDrainWaitsForMessagesBeingHandled(t *testing.T) {
+	is := is.New(t)
+	drainer := NewDrainer(deadletter.NewInMemoryStore())
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handled := false
+
+	handler := drainer.Topic(func(msg amqp.Delivery, log zerolog.Logger) {
+		close(started)
+		<-release
+		handled = true
+	})
+
+	go handler(amqp.Delivery{}, log.Logger)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	is.Equal(drainer.Drain(ctx), context.DeadlineExceeded) // drain should give up when the context is done
+
+	close(release)
+	is.NoErr(drainer.Drain(context.Background())) // drain should return once the message has been handled
+	is.True(handled)
+}
+
+func TestThatDrainReturnsImmediatelyWhenIdle(t *testing.T) {
+	is := is.New(t)
+	is.NoErr(NewDrainer(deadletter.NewInMemoryStore()).Drain(context.Background())) // nothing to wait for
+}
+
+func TestThatMessagesAreRefusedOnceTheDrainerIsStopped(t *testing.T) {
+	is := is.New(t)
+	deadletters := deadletter.NewInMemoryStore()
+	drainer := NewDrainer(deadletters)
+
+	handled := 0
+	topic := drainer.Topic(func(msg amqp.Delivery, log zerolog.Logger) { handled++ })
+	command := drainer.Command(func(wrapper messaging.CommandMessageWrapper, log zerolog.Logger) error {
+		handled++
+		return nil
+	})
+
+	drainer.Stop()
+
+	topic(amqp.Delivery{RoutingKey: "telemetry.temperature", Body: []byte("{}")}, log.Logger)
+	is.Equal(handled, 0)                 // topic messages should not be handled once the drainer is stopped
+	is.Equal(len(deadletters.List()), 1) // but routed to the dead letter store
+
+	refused := make(chan error)
+	go func() { refused <- command(nil, log.Logger) }()
+
+	select {
+	case <-refused:
+		is.Fail() // commands should be held back until the drainer is released
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	is.NoErr(drainer.Drain(context.Background())) // refused messages should not hold up draining
+
+	drainer.Release()
+	is.Equal(<-refused, ErrShuttingDown) // and then be reported as not handled
+	is.Equal(handled, 0)
+}
@@ -0,0 +1,68 @@
+package units
+
+import (
+	"fmt"
+	"strings"
+)
+
+//The units of temperature that readings can be reported and presented in, identified by
+//their UN/CEFACT common codes as the NGSI-LD unitCode of a property requires
+const (
+	//Celsius is the unit that all temperatures are stored in
+	Celsius string = "CEL"
+	//Fahrenheit is degrees Fahrenheit
+	Fahrenheit string = "FAH"
+	//Kelvin is kelvin
+	Kelvin string = "KEL"
+)
+
+//Parse returns the unit code that a string refers to. Codes are case insensitive and an
+//empty string refers to Celsius, as that is what temperatures without a unit are in.
+func Parse(s string) (string, error) {
+	if s == "" {
+		return Celsius, nil
+	}
+
+	code := strings.ToUpper(s)
+
+	switch code {
+	case Celsius, Fahrenheit, Kelvin:
+		return code, nil
+	}
+
+	return "", fmt.Errorf("unknown unit %q, expected one of %s, %s or %s", s, Celsius, Fahrenheit, Kelvin)
+}
+
+//ToCelsius converts a temperature in the provided unit to Celsius
+func ToCelsius(value float64, unit string) float64 {
+	switch unit {
+	case Fahrenheit:
+		return (value - 32) * 5 / 9
+	case Kelvin:
+		return value - 273.15
+	}
+
+	return value
+}
+
+//FromCelsius converts a temperature in Celsius to the provided unit
+func FromCelsius(value float64, unit string) float64 {
+	switch unit {
+	case Fahrenheit:
+		return value*9/5 + 32
+	case Kelvin:
+		return value + 273.15
+	}
+
+	return value
+}
+
+//IntervalFromCelsius converts a difference between two temperatures in Celsius, such as the
+//resolution of a sensor, to the provided unit
+func IntervalFromCelsius(delta float64, unit string) float64 {
+	if unit == Fahrenheit {
+		return delta * 9 / 5
+	}
+
+	return delta
+}
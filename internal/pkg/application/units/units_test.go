@@ -0,0 +1,38 @@
+package units
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestThatUnitsAreParsed(t *testing.T) {
+	is := is.New(t)
+
+	unit, err := Parse("")
+	is.NoErr(err)
+	is.Equal(unit, Celsius) // temperatures without a unit should be in Celsius
+
+	unit, err = Parse("fah")
+	is.NoErr(err)
+	is.Equal(unit, Fahrenheit) // codes should be case insensitive
+
+	_, err = Parse("rankine")
+	is.True(err != nil) // unknown units should be rejected
+}
+
+func TestThatTemperaturesAreConverted(t *testing.T) {
+	is := is.New(t)
+
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+	is.True(near(ToCelsius(212, Fahrenheit), 100))   // boiling point in Fahrenheit
+	is.True(near(ToCelsius(273.15, Kelvin), 0))      // freezing point in kelvin
+	is.True(near(FromCelsius(-40, Fahrenheit), -40)) // where the scales meet
+	is.True(near(FromCelsius(21.5, Kelvin), 294.65))
+	is.True(near(FromCelsius(ToCelsius(55.4, Fahrenheit), Fahrenheit), 55.4)) // and back again
+
+	is.True(near(IntervalFromCelsius(0.5, Fahrenheit), 0.9)) // differences should be converted without an offset
+	is.True(near(IntervalFromCelsius(0.5, Kelvin), 0.5))
+}
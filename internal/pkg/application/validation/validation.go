@@ -0,0 +1,240 @@
+package validation
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Verdict is the outcome of validating a reading
+type Verdict int
+
+const (
+	//Accepted readings passed all rules
+	Accepted Verdict = iota
+	//Suspect readings are plausible enough to be stored, but should be treated with care
+	Suspect
+	//Rejected readings are physically impossible or otherwise invalid and must not be stored
+	Rejected
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Accepted:
+		return "accepted"
+	case Suspect:
+		return "suspect"
+	case Rejected:
+		return "rejected"
+	}
+
+	return "unknown"
+}
+
+const (
+	//KindAir is the kind of readings that describe the temperature of the air
+	KindAir = "air"
+	//KindWater is the kind of readings that describe the temperature of water
+	KindWater = "water"
+	//KindSoil is the kind of readings that describe the temperature of the ground
+	KindSoil = "soil"
+	//KindSurface is the kind of readings that describe the temperature of a road surface
+	KindSurface = "surface"
+	//KindIndoor is the kind of readings that describe the temperature of the air indoors
+	KindIndoor = "indoor"
+)
+
+//Reading contains the parts of a temperature measurement that are subject to validation
+type Reading struct {
+	Tenant    string
+	Device    string
+	Kind      string
+	Latitude  float64
+	Longitude float64
+	Temp      float64
+	Depth     float64
+	Timestamp time.Time
+}
+
+//Result holds the verdict for a reading together with the reasons for it
+type Result struct {
+	Verdict Verdict
+	Reasons []string
+}
+
+//Reason returns all reasons as a single string
+func (r Result) Reason() string {
+	return strings.Join(r.Reasons, "; ")
+}
+
+func (r *Result) add(verdict Verdict, reason string) {
+	if verdict == Accepted {
+		return
+	}
+
+	if verdict > r.Verdict {
+		r.Verdict = verdict
+	}
+
+	r.Reasons = append(r.Reasons, reason)
+}
+
+//Range is an inclusive interval of temperatures
+type Range struct {
+	Min float64
+	Max float64
+}
+
+func (r Range) contains(value float64) bool {
+	return value >= r.Min && value <= r.Max
+}
+
+//Limits hold the physical (hard) and plausible (soft) temperature ranges for a kind of reading
+type Limits struct {
+	Physical  Range
+	Plausible Range
+}
+
+//Config contains the configurable parameters of the validation rules. A zero value
+//disables the corresponding rule.
+type Config struct {
+	Limits map[string]Limits
+	//MaxRateOfChange is the maximum plausible change per hour, in degrees, for a single device
+	MaxRateOfChange float64
+	//MaxClockSkew is how far into the future a timestamp may be before it is rejected
+	MaxClockSkew time.Duration
+	//RejectNullIsland rejects readings positioned at exactly 0,0
+	RejectNullIsland bool
+}
+
+//DefaultConfig returns a configuration suitable for Swedish air, lake, soil, road surface and indoor temperatures
+func DefaultConfig() Config {
+	return Config{
+		Limits: map[string]Limits{
+			KindAir: {
+				Physical:  Range{Min: -90, Max: 60},
+				Plausible: Range{Min: -45, Max: 40},
+			},
+			KindWater: {
+				Physical:  Range{Min: -3, Max: 45},
+				Plausible: Range{Min: -1, Max: 32},
+			},
+			KindSoil: {
+				Physical:  Range{Min: -50, Max: 70},
+				Plausible: Range{Min: -25, Max: 40},
+			},
+			KindSurface: {
+				Physical:  Range{Min: -60, Max: 80},
+				Plausible: Range{Min: -40, Max: 65},
+			},
+			KindIndoor: {
+				Physical:  Range{Min: -30, Max: 60},
+				Plausible: Range{Min: 5, Max: 35},
+			},
+		},
+		MaxRateOfChange:  10,
+		MaxClockSkew:     5 * time.Minute,
+		RejectNullIsland: true,
+	}
+}
+
+const minimumRateWindow float64 = 0.25
+
+//Validator checks readings against a set of validation rules
+type Validator interface {
+	Validate(r Reading) Result
+}
+
+//NewValidator creates a Validator that applies the rules in the provided configuration
+func NewValidator(cfg Config) Validator {
+	return &validator{
+		cfg:    cfg,
+		now:    time.Now,
+		latest: map[string]Reading{},
+	}
+}
+
+type validator struct {
+	cfg Config
+	now func() time.Time
+
+	mu     sync.Mutex
+	latest map[string]Reading
+}
+
+func (v *validator) Validate(r Reading) Result {
+	result := Result{Verdict: Accepted}
+
+	v.checkCoordinates(r, &result)
+	v.checkTimestamp(r, &result)
+	v.checkRange(r, &result)
+	v.checkRateOfChange(r, &result)
+
+	return result
+}
+
+func (v *validator) checkCoordinates(r Reading, result *Result) {
+	if r.Latitude < -90 || r.Latitude > 90 || r.Longitude < -180 || r.Longitude > 180 {
+		result.add(Rejected, fmt.Sprintf("position %f,%f is not a valid WGS84 coordinate", r.Latitude, r.Longitude))
+	} else if v.cfg.RejectNullIsland && r.Latitude == 0 && r.Longitude == 0 {
+		result.add(Rejected, "position 0,0 is not a plausible sensor location")
+	}
+}
+
+func (v *validator) checkTimestamp(r Reading, result *Result) {
+	if v.cfg.MaxClockSkew > 0 && r.Timestamp.After(v.now().Add(v.cfg.MaxClockSkew)) {
+		result.add(Rejected, fmt.Sprintf("timestamp %s is in the future", r.Timestamp.Format(time.RFC3339)))
+	}
+}
+
+func (v *validator) checkRange(r Reading, result *Result) {
+	limits, ok := v.cfg.Limits[r.Kind]
+	if !ok {
+		return
+	}
+
+	if !limits.Physical.contains(r.Temp) {
+		result.add(Rejected, fmt.Sprintf("%s temperature %.2f is outside the physical range [%.1f, %.1f]",
+			r.Kind, r.Temp, limits.Physical.Min, limits.Physical.Max))
+	} else if !limits.Plausible.contains(r.Temp) {
+		result.add(Suspect, fmt.Sprintf("%s temperature %.2f is outside the plausible range [%.1f, %.1f]",
+			r.Kind, r.Temp, limits.Plausible.Min, limits.Plausible.Max))
+	}
+}
+
+func (v *validator) checkRateOfChange(r Reading, result *Result) {
+	if r.Device == "" || result.Verdict == Rejected {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// Device names are only unique within a tenant, and devices that measure at several depths
+	// have a separate history per depth
+	key := fmt.Sprintf("%s/%s@%g", r.Tenant, r.Device, r.Depth)
+
+	previous, ok := v.latest[key]
+	if ok && !r.Timestamp.After(previous.Timestamp) {
+		// Late arrivals are not compared against newer readings
+		return
+	}
+
+	v.latest[key] = r
+
+	if !ok || v.cfg.MaxRateOfChange <= 0 {
+		return
+	}
+
+	// Readings that are close in time are compared over a minimum window, so that
+	// sensor noise between frequent readings is not mistaken for a rapid change
+	hours := math.Max(r.Timestamp.Sub(previous.Timestamp).Hours(), minimumRateWindow)
+	delta := math.Abs(r.Temp - previous.Temp)
+
+	if delta > v.cfg.MaxRateOfChange*hours {
+		result.add(Suspect, fmt.Sprintf("temperature changed %.1f degrees in %.1f hours since the previous reading",
+			delta, r.Timestamp.Sub(previous.Timestamp).Hours()))
+	}
+}
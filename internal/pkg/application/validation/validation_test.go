@@ -0,0 +1,105 @@
+package validation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestThatPlausibleReadingsAreAccepted(t *testing.T) {
+	is := is.New(t)
+	v := NewValidator(DefaultConfig())
+
+	result := v.Validate(newReading("device", KindWater, 17.2, time.Now().UTC()))
+
+	is.Equal(result.Verdict, Accepted) // reading should be accepted
+	is.Equal(len(result.Reasons), 0)   // no reasons should be given
+}
+
+func TestThatPhysicallyImpossibleTemperaturesAreRejected(t *testing.T) {
+	is := is.New(t)
+	v := NewValidator(DefaultConfig())
+
+	result := v.Validate(newReading("device", KindAir, -273, time.Now().UTC()))
+	is.Equal(result.Verdict, Rejected) // -273 degrees in the air should be rejected
+
+	result = v.Validate(newReading("device", KindWater, 95, time.Now().UTC()))
+	is.Equal(result.Verdict, Rejected) // 95 degrees lake water should be rejected
+}
+
+func TestThatImplausibleTemperaturesAreSuspect(t *testing.T) {
+	is := is.New(t)
+	v := NewValidator(DefaultConfig())
+
+	result := v.Validate(newReading("device", KindWater, 35, time.Now().UTC()))
+	is.Equal(result.Verdict, Suspect) // 35 degrees lake water should be suspect
+}
+
+func TestThatNullIslandIsRejected(t *testing.T) {
+	is := is.New(t)
+	v := NewValidator(DefaultConfig())
+
+	r := newReading("device", KindAir, 12, time.Now().UTC())
+	r.Latitude, r.Longitude = 0, 0
+
+	result := v.Validate(r)
+	is.Equal(result.Verdict, Rejected) // readings at 0,0 should be rejected
+}
+
+func TestThatFutureTimestampsAreRejected(t *testing.T) {
+	is := is.New(t)
+	v := NewValidator(DefaultConfig())
+
+	result := v.Validate(newReading("device", KindAir, 12, time.Now().UTC().Add(time.Hour)))
+	is.Equal(result.Verdict, Rejected) // readings from the future should be rejected
+}
+
+func TestThatRapidChangesAreSuspect(t *testing.T) {
+	is := is.New(t)
+	v := NewValidator(DefaultConfig())
+
+	now := time.Now().UTC()
+
+	result := v.Validate(newReading("device", KindWater, 12, now.Add(-1*time.Hour)))
+	is.Equal(result.Verdict, Accepted) // first reading should be accepted
+
+	result = v.Validate(newReading("device", KindWater, 12.3, now.Add(-50*time.Minute)))
+	is.Equal(result.Verdict, Accepted) // small changes should be accepted
+
+	result = v.Validate(newReading("device", KindWater, 28, now))
+	is.Equal(result.Verdict, Suspect) // a 16 degree change in less than an hour should be suspect
+
+	result = v.Validate(newReading("other", KindWater, 4, now))
+	is.Equal(result.Verdict, Accepted) // other devices should not be affected
+}
+
+func TestThatTheReadingsOfOtherTenantsAreNotCompared(t *testing.T) {
+	is := is.New(t)
+	v := NewValidator(DefaultConfig())
+
+	now := time.Now().UTC()
+
+	sundsvall := newReading("device", KindWater, 12, now.Add(-1*time.Hour))
+	sundsvall.Tenant = "sundsvall"
+	is.Equal(v.Validate(sundsvall).Verdict, Accepted) // first reading should be accepted
+
+	timra := newReading("device", KindWater, 28, now)
+	timra.Tenant = "timra"
+	is.Equal(v.Validate(timra).Verdict, Accepted) // the same device id in another tenant is another device
+
+	sundsvall = newReading("device", KindWater, 12.2, now)
+	sundsvall.Tenant = "sundsvall"
+	is.Equal(v.Validate(sundsvall).Verdict, Accepted) // and should not affect the history of the first tenant
+}
+
+func newReading(device, kind string, temp float64, when time.Time) Reading {
+	return Reading{
+		Device:    device,
+		Kind:      kind,
+		Latitude:  62.3908,
+		Longitude: 17.3069,
+		Temp:      temp,
+		Timestamp: when,
+	}
+}
@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+)
+
+//ErrUnauthenticated is returned when a request lacks valid credentials
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+//Principal is the authenticated (or anonymous) caller of a request. A principal with a tenant
+//may only access the data of that tenant, while one without may access the data of any tenant.
+type Principal struct {
+	Subject   string
+	Tenant    string
+	Scopes    []string
+	Groups    []string
+	Anonymous bool
+
+	adminScope   string
+	deviceGroups map[string][]string
+}
+
+//HasScope returns true if the principal has been granted the provided scope
+func (p *Principal) HasScope(scope string) bool {
+	return contains(p.Scopes, scope)
+}
+
+//IsAdmin returns true if the principal has been granted the admin scope
+func (p *Principal) IsAdmin() bool {
+	return p.HasScope(p.adminScope)
+}
+
+//MemberOf returns true if the principal is a member of the provided group
+func (p *Principal) MemberOf(group string) bool {
+	return contains(p.Groups, group)
+}
+
+//MayReadDevice returns true if the principal is allowed to see the readings of a device. Devices that
+//are not in any group are visible to everyone that may read, while the devices in a group are only
+//visible to the members of that group and to administrators.
+func (p *Principal) MayReadDevice(device string) bool {
+	if p.IsAdmin() {
+		return true
+	}
+
+	inGroup := false
+
+	for group, patterns := range p.deviceGroups {
+		if !matchesAny(patterns, device) {
+			continue
+		}
+
+		if p.MemberOf(group) {
+			return true
+		}
+
+		inGroup = true
+	}
+
+	return !inGroup
+}
+
+//HidesGroupedDevices returns true if there is a device group that hides its devices from the
+//principal, i.e. unless the principal is an administrator or a member of every group
+func (p *Principal) HidesGroupedDevices() bool {
+	if p.IsAdmin() {
+		return false
+	}
+
+	for group, patterns := range p.deviceGroups {
+		if len(patterns) > 0 && !p.MemberOf(group) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesAny(patterns []string, device string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, device); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+type principalContextKey struct{}
+
+//WithPrincipal returns a copy of ctx that carries the provided principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, p)
+}
+
+//PrincipalFromContext returns the principal of the request that ctx belongs to, or nil if
+//authentication is disabled
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalContextKey{}).(*Principal)
+	return p
+}
+
+//MayReadDevice returns true if the caller of the request that ctx belongs to is allowed to see the
+//readings of a device. Everything is visible when authentication is disabled.
+func MayReadDevice(ctx context.Context, device string) bool {
+	p := PrincipalFromContext(ctx)
+	return p == nil || p.MayReadDevice(device)
+}
+
+//HidesGroupedDevices returns true if some of the devices that have been put in groups are hidden
+//from the caller of the request that ctx belongs to. Nothing is hidden when authentication is disabled.
+func HidesGroupedDevices(ctx context.Context) bool {
+	p := PrincipalFromContext(ctx)
+	return p != nil && p.HidesGroupedDevices()
+}
+
+//IsAuthenticated returns true if the caller of the request that ctx belongs to has presented valid
+//credentials. Callers are never authenticated when authentication is disabled.
+func IsAuthenticated(ctx context.Context) bool {
+	p := PrincipalFromContext(ctx)
+	return p != nil && !p.Anonymous
+}
+
+//Authenticator verifies a bearer token and returns the principal that it belongs to
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Principal, error)
+}
+
+//NewAuthenticator creates the Authenticator that the configuration asks for
+func NewAuthenticator(cfg config.Auth) (Authenticator, error) {
+	switch cfg.Mode {
+	case config.AuthModeJWKS:
+		return NewJWKSAuthenticator(cfg), nil
+	case config.AuthModeStatic:
+		return NewStaticKeyAuthenticator(cfg), nil
+	}
+
+	return nil, fmt.Errorf("unknown auth mode %q", cfg.Mode)
+}
+
+//Rule requires a scope for all requests with paths that start with a prefix
+type Rule struct {
+	PathPrefix string
+	Scope      string
+}
+
+//DefaultRules require the read scope for the query APIs and the admin scope for the admin API.
+//Health probes and metrics are left open, as they are scraped from within the cluster.
+func DefaultRules(cfg config.Auth) []Rule {
+	return []Rule{
+		{PathPrefix: "/admin", Scope: cfg.AdminScope},
+		{PathPrefix: "/api/graphql", Scope: cfg.ReadScope},
+		{PathPrefix: "/ngsi-ld", Scope: cfg.ReadScope},
+	}
+}
+
+//Middleware authenticates the bearer token of every request that matches a rule and rejects the
+//request unless the caller has the scope that the rule requires. Requests without a token are
+//treated as coming from an anonymous caller with the read scope, if anonymous reads are allowed.
+func Middleware(cfg config.Auth, authenticator Authenticator, rules []Rule) func(http.Handler) http.Handler {
+	anonymous := &Principal{Subject: "anonymous", Anonymous: true, adminScope: cfg.AdminScope, deviceGroups: cfg.DeviceGroups}
+	if cfg.AllowAnonymousRead {
+		anonymous.Scopes = []string{cfg.ReadScope}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rule, ok := matchRule(rules, r.URL.Path)
+			if !ok || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal := anonymous
+
+			if token, found := bearerToken(r); found {
+				p, err := authenticator.Authenticate(r.Context(), token)
+				if err != nil {
+					log.Info().Err(err).Str("path", r.URL.Path).Msg("rejected bearer token")
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+					return
+				}
+
+				p.adminScope = cfg.AdminScope
+				p.deviceGroups = cfg.DeviceGroups
+				principal = p
+			}
+
+			if !principal.HasScope(rule.Scope) {
+				if principal.Anonymous {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+					http.Error(w, "authentication required", http.StatusUnauthorized)
+					return
+				}
+
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, rule.Scope))
+				http.Error(w, "insufficient scope", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+func matchRule(rules []Rule, urlPath string) (Rule, bool) {
+	for _, rule := range rules {
+		if urlPath == rule.PathPrefix || strings.HasPrefix(urlPath, rule.PathPrefix+"/") {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", false
+	}
+
+	const prefix = "bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		// A token in an unsupported scheme is treated as an invalid token rather than no token at all
+		return header, true
+	}
+
+	return strings.TrimSpace(header[len(prefix):]), true
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matryer/is"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+)
+
+func testConfig() config.Auth {
+	cfg := config.Default().Auth
+	cfg.Enabled = true
+	cfg.Mode = config.AuthModeStatic
+	cfg.StaticKeys = []config.StaticKey{
+		{Key: "reader", Subject: "reader", Scopes: []string{cfg.ReadScope}},
+		{Key: "staff", Subject: "staff", Scopes: []string{cfg.ReadScope}, Groups: []string{"schools"}},
+		{Key: "admin", Subject: "admin", Scopes: []string{cfg.ReadScope, cfg.AdminScope}},
+	}
+	cfg.DeviceGroups = map[string][]string{
+		"schools": {"school-*"},
+	}
+	return cfg
+}
+
+func serve(cfg config.Auth, method, path, token string) (*httptest.ResponseRecorder, *Principal) {
+	var principal *Principal
+
+	handler := Middleware(cfg, NewStaticKeyAuthenticator(cfg), DefaultRules(cfg))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = PrincipalFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	return w, principal
+}
+
+func TestThatScopesAreRequiredPerPath(t *testing.T) {
+	is := is.New(t)
+	cfg := testConfig()
+
+	w, _ := serve(cfg, http.MethodGet, "/ngsi-ld/v1/entities", "")
+	is.Equal(w.Code, http.StatusUnauthorized) // anonymous callers should be asked to authenticate
+	is.Equal(w.Header().Get("WWW-Authenticate"), "Bearer")
+
+	w, _ = serve(cfg, http.MethodGet, "/ngsi-ld/v1/entities", "not-a-key")
+	is.Equal(w.Code, http.StatusUnauthorized) // invalid tokens should be rejected
+
+	w, p := serve(cfg, http.MethodGet, "/ngsi-ld/v1/entities", "reader")
+	is.Equal(w.Code, http.StatusOK) // readers should be allowed to query
+	is.Equal(p.Subject, "reader")   // and be known to the handler
+
+	w, _ = serve(cfg, http.MethodGet, "/admin/deadletters", "reader")
+	is.Equal(w.Code, http.StatusForbidden) // but not to use the admin API
+
+	w, _ = serve(cfg, http.MethodGet, "/admin/deadletters", "admin")
+	is.Equal(w.Code, http.StatusOK)
+
+	w, _ = serve(cfg, http.MethodGet, "/health/ready", "")
+	is.Equal(w.Code, http.StatusOK) // probes should be left open
+}
+
+func TestThatAnonymousReadsCanBeAllowed(t *testing.T) {
+	is := is.New(t)
+	cfg := testConfig()
+	cfg.AllowAnonymousRead = true
+
+	w, p := serve(cfg, http.MethodPost, "/api/graphql", "")
+	is.Equal(w.Code, http.StatusOK) // anonymous callers should be allowed to query
+	is.True(p.Anonymous)
+
+	w, _ = serve(cfg, http.MethodGet, "/admin/alertrules", "")
+	is.Equal(w.Code, http.StatusUnauthorized) // but not to use the admin API
+}
+
+func TestThatGroupedDevicesAreOnlyVisibleToMembers(t *testing.T) {
+	is := is.New(t)
+	cfg := testConfig()
+
+	_, reader := serve(cfg, http.MethodGet, "/ngsi-ld/v1/entities", "reader")
+	_, staff := serve(cfg, http.MethodGet, "/ngsi-ld/v1/entities", "staff")
+	_, admin := serve(cfg, http.MethodGet, "/ngsi-ld/v1/entities", "admin")
+
+	is.True(reader.MayReadDevice("lake-1"))     // devices without a group should be visible to all readers
+	is.True(!reader.MayReadDevice("school-17")) // grouped devices should be hidden from non members
+	is.True(staff.MayReadDevice("school-17"))   // and visible to members
+	is.True(admin.MayReadDevice("school-17"))   // and to administrators
+
+	is.True(reader.HidesGroupedDevices()) // non members should have grouped devices hidden from them
+	is.True(!staff.HidesGroupedDevices()) // while members of every group
+	is.True(!admin.HidesGroupedDevices()) // and administrators should not
+
+	cfg.DeviceGroups = nil
+	_, reader = serve(cfg, http.MethodGet, "/ngsi-ld/v1/entities", "reader")
+	is.True(!reader.HidesGroupedDevices()) // nor should anyone when there are no groups
+}
@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+)
+
+//minRefreshInterval limits how often the JWKS is fetched when tokens are signed with unknown keys,
+//so that a flood of bogus tokens can not be turned into a flood of requests to the identity provider
+const minRefreshInterval = time.Minute
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwksAuthenticator struct {
+	cfg    config.Auth
+	client *http.Client
+	parser *jwt.Parser
+
+	mu          sync.Mutex
+	keys        map[string]interface{}
+	lastRefresh time.Time
+	refreshing  *keyRefresh
+}
+
+//keyRefresh is a fetch of the JWKS that is in progress, which is shared by every request that
+//needs a key that we do not know. It is done when the keys have been fetched or the fetch failed.
+type keyRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+//NewJWKSAuthenticator creates an Authenticator that accepts JWTs that are signed with one of the
+//keys in the configured JWKS, issued by the configured issuer and, if one is configured, for the
+//configured audience. The keys are fetched when they are first needed and again whenever a token
+//is signed with a key that we have not seen before.
+func NewJWKSAuthenticator(cfg config.Auth) Authenticator {
+	return &jwksAuthenticator{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		parser: jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"})),
+		keys:   map[string]interface{}{},
+	}
+}
+
+func (a *jwksAuthenticator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+
+	_, err := a.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return a.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnauthenticated, err.Error())
+	}
+
+	if !claims.VerifyIssuer(a.cfg.Issuer, true) {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrUnauthenticated)
+	}
+
+	if a.cfg.Audience != "" && !claims.VerifyAudience(a.cfg.Audience, true) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrUnauthenticated)
+	}
+
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("%w: token does not expire", ErrUnauthenticated)
+	}
+
+	subject, _ := claims["sub"].(string)
+	tenant, _ := claims[a.cfg.TenantClaim].(string)
+
+	return &Principal{
+		Subject: subject,
+		Tenant:  tenant,
+		Scopes:  claimValues(claims, a.cfg.ScopeClaim),
+		Groups:  claimValues(claims, a.cfg.GroupsClaim),
+	}, nil
+}
+
+//claimValues returns the values of a claim that is either a space separated string, as is
+//customary for scopes, or an array of strings
+func claimValues(claims jwt.MapClaims, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		values := []string{}
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
+
+//key returns the key with the provided id, fetching the JWKS if the key is unknown. The lock is only
+//held while the keys are looked up or swapped, so that requests signed with known keys are never
+//held up by a slow identity provider.
+func (a *jwksAuthenticator) key(ctx context.Context, kid string) (interface{}, error) {
+	a.mu.Lock()
+
+	if key, ok := a.keys[kid]; ok {
+		a.mu.Unlock()
+		return key, nil
+	}
+
+	r := a.refreshing
+	if r == nil {
+		if time.Since(a.lastRefresh) < minRefreshInterval {
+			a.mu.Unlock()
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+
+		a.lastRefresh = time.Now()
+		r = &keyRefresh{done: make(chan struct{})}
+		a.refreshing = r
+
+		go a.refresh(r)
+	}
+
+	a.mu.Unlock()
+
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	a.mu.Lock()
+	key, ok := a.keys[kid]
+	a.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	return key, nil
+}
+
+//refresh fetches the JWKS and swaps in its keys. The fetch is not bound to the request that started
+//it, since other requests may be waiting for it as well, but it is limited by the client timeout.
+func (a *jwksAuthenticator) refresh(r *keyRefresh) {
+	keys, err := a.fetch(context.Background())
+
+	a.mu.Lock()
+	if err == nil {
+		a.keys = keys
+	}
+	a.refreshing = nil
+	a.mu.Unlock()
+
+	r.err = err
+	close(r.done)
+}
+
+func (a *jwksAuthenticator) fetch(ctx context.Context) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.cfg.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch jwks: %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch jwks: unexpected status code %d", resp.StatusCode)
+	}
+
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode jwks: %s", err.Error())
+	}
+
+	keys := map[string]interface{}{}
+
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			// A key that we do not understand should not prevent us from using the others
+			continue
+		}
+
+		keys[k.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
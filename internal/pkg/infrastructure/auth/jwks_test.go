@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/matryer/is"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+)
+
+func newJWKSServer(t *testing.T, kid string, key *rsa.PublicKey) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": kid,
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestThatJWTsAreVerifiedAgainstTheJWKS(t *testing.T) {
+	is := is.New(t)
+
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	server := newJWKSServer(t, "key-1", &key.PublicKey)
+
+	cfg := config.Default().Auth
+	cfg.Issuer = "https://idp.example.com"
+	cfg.Audience = "api-temperature"
+	cfg.JWKSURL = server.URL
+
+	authenticator := NewJWKSAuthenticator(cfg)
+
+	token := signToken(t, key, "key-1", jwt.MapClaims{
+		"iss":    cfg.Issuer,
+		"aud":    cfg.Audience,
+		"sub":    "staff@example.com",
+		"tenant": "schools",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"scope":  "openid temperature.read",
+		"groups": []string{"schools"},
+	})
+
+	p, err := authenticator.Authenticate(context.Background(), token)
+	is.NoErr(err)
+	is.Equal(p.Subject, "staff@example.com")
+	is.Equal(p.Tenant, "schools")           // the tenant should be read from the tenant claim
+	is.True(p.HasScope("temperature.read")) // scopes should be read from a space separated claim
+	is.True(p.MemberOf("schools"))          // and groups from an array claim
+}
+
+func TestThatInvalidJWTsAreRejected(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	server := newJWKSServer(t, "key-1", &key.PublicKey)
+
+	cfg := config.Default().Auth
+	cfg.Issuer = "https://idp.example.com"
+	cfg.JWKSURL = server.URL
+
+	valid := jwt.MapClaims{"iss": cfg.Issuer, "exp": time.Now().Add(time.Hour).Unix()}
+
+	tokens := map[string]string{
+		"expired":        signToken(t, key, "key-1", jwt.MapClaims{"iss": cfg.Issuer, "exp": time.Now().Add(-time.Hour).Unix()}),
+		"wrong issuer":   signToken(t, key, "key-1", jwt.MapClaims{"iss": "https://evil.example.com", "exp": time.Now().Add(time.Hour).Unix()}),
+		"no expiry":      signToken(t, key, "key-1", jwt.MapClaims{"iss": cfg.Issuer}),
+		"wrong key":      signToken(t, otherKey, "key-1", valid),
+		"unknown key id": signToken(t, key, "key-2", valid),
+	}
+
+	authenticator := NewJWKSAuthenticator(cfg)
+
+	for name, token := range tokens {
+		t.Run(name, func(t *testing.T) {
+			_, err := authenticator.Authenticate(context.Background(), token)
+			is.New(t).True(errors.Is(err, ErrUnauthenticated)) // the token should be rejected
+		})
+	}
+}
+
+func TestThatKnownKeysAreUsedWhileTheJWKSIsFetched(t *testing.T) {
+	is := is.New(t)
+
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	jwks := newJWKSServer(t, "key-1", &key.PublicKey)
+
+	fetching := make(chan struct{}, 1)
+	release := make(chan struct{})
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests > 1 {
+			fetching <- struct{}{}
+			<-release
+		}
+		jwks.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	defer close(release)
+
+	cfg := config.Default().Auth
+	cfg.Issuer = "https://idp.example.com"
+	cfg.JWKSURL = server.URL
+
+	authenticator := NewJWKSAuthenticator(cfg)
+	claims := jwt.MapClaims{"iss": cfg.Issuer, "exp": time.Now().Add(time.Hour).Unix()}
+
+	_, err := authenticator.Authenticate(context.Background(), signToken(t, key, "key-1", claims))
+	is.NoErr(err) // the keys should be fetched for the first token
+
+	authenticator.(*jwksAuthenticator).lastRefresh = time.Time{}
+	go authenticator.Authenticate(context.Background(), signToken(t, key, "key-2", claims))
+	<-fetching
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = authenticator.Authenticate(ctx, signToken(t, key, "key-1", claims))
+	is.NoErr(err) // a token signed with a known key should not wait for the keys to be fetched again
+}
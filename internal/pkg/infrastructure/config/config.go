@@ -0,0 +1,535 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/diwise/api-temperature/internal/pkg/application/validation"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/retry"
+)
+
+//FileEnvVar is the environment variable that points out an optional configuration file
+const FileEnvVar = "TEMPERATURE_CONFIG_FILE"
+
+//defaultAMQPPort is the port that the messaging library connects to the broker on
+const defaultAMQPPort = 5672
+
+const redacted = "[REDACTED]"
+
+//Config is the complete configuration of the service
+type Config struct {
+	HTTP           HTTP           `yaml:"http"`
+	Database       Database       `yaml:"database"`
+	Messaging      Messaging      `yaml:"messaging"`
+	Retention      Retention      `yaml:"retention"`
+	Validation     Validation     `yaml:"validation"`
+	CORS           CORS           `yaml:"cors"`
+	Auth           Auth           `yaml:"auth"`
+	RateLimit      RateLimit      `yaml:"rateLimit"`
+	QueryCache     QueryCache     `yaml:"queryCache"`
+	Precision      Precision      `yaml:"precision"`
+	DeviceRegistry DeviceRegistry `yaml:"deviceRegistry"`
+}
+
+//HTTP configures the http server that serves the APIs
+type HTTP struct {
+	Port         int           `yaml:"port"`
+	ReadTimeout  time.Duration `yaml:"readTimeout"`
+	WriteTimeout time.Duration `yaml:"writeTimeout"`
+	IdleTimeout  time.Duration `yaml:"idleTimeout"`
+}
+
+//Database configures the connection, and connection pool, to the postgresql database
+type Database struct {
+	Host            string        `yaml:"host"`
+	User            string        `yaml:"user"`
+	Name            string        `yaml:"name"`
+	Password        string        `yaml:"password"`
+	SSLMode         string        `yaml:"sslMode"`
+	MaxOpenConns    int           `yaml:"maxOpenConns"`
+	MaxIdleConns    int           `yaml:"maxIdleConns"`
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime"`
+	Connect         retry.Policy  `yaml:"connect"`
+}
+
+//Messaging configures how we connect to the message broker. The broker itself is configured
+//with the RABBITMQ_* environment variables that are read by the messaging library, except for
+//its port, which the library does not know about. The library always connects to the default
+//AMQP port, so that is the only port that is accepted until the library can be told otherwise.
+type Messaging struct {
+	Port    int          `yaml:"port"`
+	Connect retry.Policy `yaml:"connect"`
+}
+
+//Retention configures how long readings are kept. A zero MaxAge keeps them forever.
+type Retention struct {
+	MaxAge   time.Duration `yaml:"maxAge"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+//Range is an inclusive interval of temperatures
+type Range struct {
+	Min float64 `yaml:"min"`
+	Max float64 `yaml:"max"`
+}
+
+//Limits are the temperature ranges of a kind of reading
+type Limits struct {
+	Physical  Range `yaml:"physical"`
+	Plausible Range `yaml:"plausible"`
+}
+
+//Validation configures the plausibility checks of incoming readings. The limits of a kind that is
+//present in the config file replace the default limits of that kind entirely.
+type Validation struct {
+	Limits           map[string]Limits `yaml:"limits"`
+	MaxRateOfChange  float64           `yaml:"maxRateOfChange"`
+	MaxClockSkew     time.Duration     `yaml:"maxClockSkew"`
+	RejectNullIsland bool              `yaml:"rejectNullIsland"`
+}
+
+//CORS configures which origins are allowed to make cross origin requests
+type CORS struct {
+	AllowedOrigins   []string `yaml:"allowedOrigins"`
+	AllowCredentials bool     `yaml:"allowCredentials"`
+}
+
+//Auth configures how requests are authenticated and authorized. Callers authenticate with a bearer
+//token, which is either a JWT that is verified against the keys of a JWKS, or one of a set of static
+//keys for local testing. Devices can be put in groups that are only visible to members of the group.
+type Auth struct {
+	Enabled            bool                `yaml:"enabled"`
+	Mode               string              `yaml:"mode"`
+	Issuer             string              `yaml:"issuer"`
+	Audience           string              `yaml:"audience"`
+	JWKSURL            string              `yaml:"jwksURL"`
+	ScopeClaim         string              `yaml:"scopeClaim"`
+	GroupsClaim        string              `yaml:"groupsClaim"`
+	TenantClaim        string              `yaml:"tenantClaim"`
+	ReadScope          string              `yaml:"readScope"`
+	AdminScope         string              `yaml:"adminScope"`
+	AllowAnonymousRead bool                `yaml:"allowAnonymousRead"`
+	StaticKeys         []StaticKey         `yaml:"staticKeys"`
+	DeviceGroups       map[string][]string `yaml:"deviceGroups"`
+}
+
+//RateLimit configures how many requests each client may make to the query APIs. Clients are
+//identified by the subject of their credentials or, if they have not authenticated, by their IP
+//address, which is taken from the X-Forwarded-For header if the service runs behind a trusted proxy.
+type RateLimit struct {
+	Enabled           bool   `yaml:"enabled"`
+	TrustForwardedFor bool   `yaml:"trustForwardedFor"`
+	NGSILD            Budget `yaml:"ngsild"`
+	GraphQL           Budget `yaml:"graphql"`
+	Export            Budget `yaml:"export"`
+}
+
+//QueryCache configures the cache of recent query results. Results are dropped when readings that
+//could be part of them are stored, and after TTL at the latest.
+type QueryCache struct {
+	Enabled    bool          `yaml:"enabled"`
+	TTL        time.Duration `yaml:"ttl"`
+	MaxEntries int           `yaml:"maxEntries"`
+}
+
+//Precision configures the resolution of the sensors that readings come from and how precisely each
+//API presents temperatures. Readings are stored with the precision that they were reported with and
+//the resolution of their sensor, which is that of the first device pattern that matches the device,
+//or else that of the kind of reading, or else the default resolution.
+type Precision struct {
+	DefaultResolution float64            `yaml:"defaultResolution"`
+	Kinds             map[string]float64 `yaml:"kinds"`
+	Devices           []DeviceResolution `yaml:"devices"`
+	NGSILD            Rounding           `yaml:"ngsild"`
+	GraphQL           Rounding           `yaml:"graphql"`
+	Events            Rounding           `yaml:"events"`
+}
+
+//DeviceResolution is the resolution, in degrees, of the sensors of the devices that match a pattern
+type DeviceResolution struct {
+	Pattern    string  `yaml:"pattern"`
+	Resolution float64 `yaml:"resolution"`
+}
+
+//Rounding configures how an API rounds the temperatures that it presents. Temperatures are rounded to
+//the resolution of their readings if ByResolution is set, and to a number of decimals otherwise.
+//Readings that were stored before resolutions were recorded are always rounded to Decimals.
+type Rounding struct {
+	Decimals     int  `yaml:"decimals"`
+	ByResolution bool `yaml:"byResolution"`
+}
+
+//DeviceRegistry configures where the metadata of devices, such as their names, owners, installation
+//sites and sensor models, is fetched from. The devices are fetched from the NGSI-LD API of the
+//registry at startup and then at every refresh interval, and are used to enrich the readings that
+//the APIs present. The devices of each tenant are fetched separately.
+type DeviceRegistry struct {
+	Enabled         bool          `yaml:"enabled"`
+	URL             string        `yaml:"url"`
+	Tenants         []string      `yaml:"tenants"`
+	RefreshInterval time.Duration `yaml:"refreshInterval"`
+	Timeout         time.Duration `yaml:"timeout"`
+}
+
+//Budget is a token bucket that holds at most Burst requests and is refilled with Rate requests per second
+type Budget struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+const (
+	//AuthModeJWKS verifies bearer tokens as JWTs signed by a key in the configured JWKS
+	AuthModeJWKS = "jwks"
+	//AuthModeStatic compares bearer tokens to a set of static keys
+	AuthModeStatic = "static"
+)
+
+//StaticKey is a bearer token that grants a fixed set of scopes and groups, optionally within a single tenant
+type StaticKey struct {
+	Key     string   `yaml:"key"`
+	Subject string   `yaml:"subject"`
+	Tenant  string   `yaml:"tenant"`
+	Scopes  []string `yaml:"scopes"`
+	Groups  []string `yaml:"groups"`
+}
+
+//Default returns the configuration that is used for everything that is not configured explicitly
+func Default() Config {
+	v := validation.DefaultConfig()
+
+	limits := map[string]Limits{}
+	for kind, l := range v.Limits {
+		limits[kind] = Limits{
+			Physical:  Range{Min: l.Physical.Min, Max: l.Physical.Max},
+			Plausible: Range{Min: l.Plausible.Min, Max: l.Plausible.Max},
+		}
+	}
+
+	return Config{
+		HTTP: HTTP{
+			Port:         8880,
+			ReadTimeout:  30 * time.Second,
+			WriteTimeout: 60 * time.Second,
+			IdleTimeout:  120 * time.Second,
+		},
+		Database: Database{
+			SSLMode:         "require",
+			MaxOpenConns:    10,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			Connect:         retry.DefaultPolicy(),
+		},
+		Messaging: Messaging{
+			Port:    defaultAMQPPort,
+			Connect: retry.DefaultPolicy(),
+		},
+		Retention: Retention{
+			Interval: time.Hour,
+		},
+		Validation: Validation{
+			Limits:           limits,
+			MaxRateOfChange:  v.MaxRateOfChange,
+			MaxClockSkew:     v.MaxClockSkew,
+			RejectNullIsland: v.RejectNullIsland,
+		},
+		CORS: CORS{
+			AllowedOrigins:   []string{"*"},
+			AllowCredentials: false,
+		},
+		Auth: Auth{
+			Mode:        AuthModeJWKS,
+			ScopeClaim:  "scope",
+			GroupsClaim: "groups",
+			TenantClaim: "tenant",
+			ReadScope:   "temperature.read",
+			AdminScope:  "temperature.admin",
+		},
+		RateLimit: RateLimit{
+			Enabled: true,
+			NGSILD:  Budget{Rate: 5, Burst: 20},
+			GraphQL: Budget{Rate: 5, Burst: 20},
+			Export:  Budget{Rate: 0.1, Burst: 2},
+		},
+		QueryCache: QueryCache{
+			Enabled:    true,
+			TTL:        30 * time.Second,
+			MaxEntries: 1000,
+		},
+		Precision: Precision{
+			DefaultResolution: 0.1,
+			Kinds:             map[string]float64{},
+			NGSILD:            Rounding{Decimals: 1},
+			GraphQL:           Rounding{Decimals: 1},
+			Events:            Rounding{Decimals: 1},
+		},
+		DeviceRegistry: DeviceRegistry{
+			Tenants:         []string{"default"},
+			RefreshInterval: 5 * time.Minute,
+			Timeout:         10 * time.Second,
+		},
+	}
+}
+
+//Load starts out with the default configuration, overlays the file that TEMPERATURE_CONFIG_FILE
+//points out, if any, then the environment variables, and validates the result
+func Load() (Config, error) {
+	cfg := Default()
+
+	if path := os.Getenv(FileEnvVar); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to open config file: %w", err)
+		}
+		defer f.Close()
+
+		if err := Decode(f, &cfg); err != nil {
+			return cfg, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnvironment(&cfg); err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+//Decode reads YAML into cfg. Keys that are missing keep their current values and unknown keys are an error.
+func Decode(r io.Reader, cfg *Config) error {
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+
+	err := decoder.Decode(cfg)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+//Validate returns an error that describes every problem with the configuration, or nil if there are none
+func (c Config) Validate() error {
+	problems := []string{}
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.HTTP.Port < 1 || c.HTTP.Port > 65535 {
+		problem("http.port must be between 1 and 65535, not %d", c.HTTP.Port)
+	}
+
+	if c.Database.Host == "" {
+		problem("database.host is required")
+	}
+	if c.Database.User == "" {
+		problem("database.user is required")
+	}
+	if c.Database.Name == "" {
+		problem("database.name is required")
+	}
+	if c.Database.MaxOpenConns < 1 {
+		problem("database.maxOpenConns must be at least 1")
+	}
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		problem("database.maxIdleConns must be between 0 and database.maxOpenConns")
+	}
+
+	validatePolicy("database.connect", c.Database.Connect, problem)
+	if c.Messaging.Port != defaultAMQPPort {
+		problem("messaging.port must be %d, as the messaging library always connects to that port, not %d", defaultAMQPPort, c.Messaging.Port)
+	}
+	validatePolicy("messaging.connect", c.Messaging.Connect, problem)
+
+	if c.Retention.MaxAge < 0 {
+		problem("retention.maxAge must not be negative")
+	}
+	if c.Retention.MaxAge > 0 && c.Retention.Interval <= 0 {
+		problem("retention.interval must be positive when retention.maxAge is set")
+	}
+
+	for kind, l := range c.Validation.Limits {
+		if l.Physical.Min > l.Plausible.Min || l.Plausible.Min > l.Plausible.Max || l.Plausible.Max > l.Physical.Max {
+			problem("validation.limits.%s: the plausible range must be within the physical range", kind)
+		}
+	}
+	if c.Validation.MaxRateOfChange < 0 {
+		problem("validation.maxRateOfChange must not be negative")
+	}
+
+	if len(c.CORS.AllowedOrigins) == 0 {
+		problem("cors.allowedOrigins must contain at least one origin")
+	}
+	if c.CORS.AllowCredentials && containsString(c.CORS.AllowedOrigins, "*") {
+		problem("cors.allowCredentials must not be combined with allowing any origin")
+	}
+
+	if c.Auth.Enabled {
+		validateAuth(c.Auth, problem)
+	}
+
+	if c.QueryCache.Enabled {
+		if c.QueryCache.TTL <= 0 {
+			problem("queryCache.ttl must be positive")
+		}
+		if c.QueryCache.MaxEntries < 1 {
+			problem("queryCache.maxEntries must be at least 1")
+		}
+	}
+
+	validatePrecision(c.Precision, problem)
+
+	if c.DeviceRegistry.Enabled {
+		if c.DeviceRegistry.URL == "" {
+			problem("deviceRegistry.url is required when the device registry is enabled")
+		}
+		if len(c.DeviceRegistry.Tenants) == 0 {
+			problem("deviceRegistry.tenants must name at least one tenant")
+		}
+		if c.DeviceRegistry.RefreshInterval <= 0 {
+			problem("deviceRegistry.refreshInterval must be positive")
+		}
+		if c.DeviceRegistry.Timeout <= 0 {
+			problem("deviceRegistry.timeout must be positive")
+		}
+	}
+
+	if c.RateLimit.Enabled {
+		validateBudget("rateLimit.ngsild", c.RateLimit.NGSILD, problem)
+		validateBudget("rateLimit.graphql", c.RateLimit.GraphQL, problem)
+		validateBudget("rateLimit.export", c.RateLimit.Export, problem)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func validateAuth(a Auth, problem func(string, ...interface{})) {
+	switch a.Mode {
+	case AuthModeJWKS:
+		if a.Issuer == "" {
+			problem("auth.issuer is required when auth is enabled")
+		}
+		if a.JWKSURL == "" {
+			problem("auth.jwksURL is required when auth is enabled")
+		}
+	case AuthModeStatic:
+		if len(a.StaticKeys) == 0 {
+			problem("auth.staticKeys must contain at least one key in static mode")
+		}
+		for i, k := range a.StaticKeys {
+			if k.Key == "" {
+				problem("auth.staticKeys[%d].key must not be empty", i)
+			}
+		}
+	default:
+		problem("auth.mode must be either %s or %s, not %q", AuthModeJWKS, AuthModeStatic, a.Mode)
+	}
+
+	if a.ReadScope == "" || a.AdminScope == "" {
+		problem("auth.readScope and auth.adminScope must not be empty")
+	}
+}
+
+func validatePrecision(p Precision, problem func(string, ...interface{})) {
+	if p.DefaultResolution <= 0 {
+		problem("precision.defaultResolution must be positive")
+	}
+	for kind, resolution := range p.Kinds {
+		if resolution <= 0 {
+			problem("precision.kinds.%s must be positive", kind)
+		}
+	}
+	for i, d := range p.Devices {
+		if _, err := path.Match(d.Pattern, ""); err != nil || d.Pattern == "" {
+			problem("precision.devices[%d].pattern is not a valid pattern", i)
+		}
+		if d.Resolution <= 0 {
+			problem("precision.devices[%d].resolution must be positive", i)
+		}
+	}
+
+	validateRounding("precision.ngsild", p.NGSILD, problem)
+	validateRounding("precision.graphql", p.GraphQL, problem)
+	validateRounding("precision.events", p.Events, problem)
+}
+
+func validateRounding(name string, r Rounding, problem func(string, ...interface{})) {
+	if r.Decimals < 0 || r.Decimals > 6 {
+		problem("%s.decimals must be between 0 and 6", name)
+	}
+}
+
+func validateBudget(name string, b Budget, problem func(string, ...interface{})) {
+	if b.Rate <= 0 {
+		problem("%s.rate must be positive", name)
+	}
+	if b.Burst < 1 {
+		problem("%s.burst must be at least 1", name)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func validatePolicy(name string, p retry.Policy, problem func(string, ...interface{})) {
+	if p.MaxAttempts < 1 {
+		problem("%s.maxAttempts must be at least 1", name)
+	}
+	if p.InitialBackoff <= 0 || p.MaxBackoff < p.InitialBackoff {
+		problem("%s backoffs must be positive and maxBackoff must not be shorter than initialBackoff", name)
+	}
+}
+
+//ValidationConfig returns the configuration of the validation rules in the form that the validator expects
+func (c Config) ValidationConfig() validation.Config {
+	limits := map[string]validation.Limits{}
+	for kind, l := range c.Validation.Limits {
+		limits[kind] = validation.Limits{
+			Physical:  validation.Range{Min: l.Physical.Min, Max: l.Physical.Max},
+			Plausible: validation.Range{Min: l.Plausible.Min, Max: l.Plausible.Max},
+		}
+	}
+
+	return validation.Config{
+		Limits:           limits,
+		MaxRateOfChange:  c.Validation.MaxRateOfChange,
+		MaxClockSkew:     c.Validation.MaxClockSkew,
+		RejectNullIsland: c.Validation.RejectNullIsland,
+	}
+}
+
+//Redacted returns a copy of the configuration where secrets have been replaced, so that it can be logged or shown
+func (c Config) Redacted() Config {
+	if c.Database.Password != "" {
+		c.Database.Password = redacted
+	}
+
+	keys := make([]StaticKey, len(c.Auth.StaticKeys))
+	for i, k := range c.Auth.StaticKeys {
+		k.Key = redacted
+		keys[i] = k
+	}
+	c.Auth.StaticKeys = keys
+
+	return c
+}
+
+//Marshal returns the configuration as YAML, in the same format as the config file
+func (c Config) Marshal() ([]byte, error) {
+	return yaml.Marshal(c)
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+const testConfigFile string = `
+http:
+  port: 9090
+database:
+  host: postgresdb
+  user: testuser
+  name: temperature
+  password: testpass
+  maxOpenConns: 20
+retention:
+  maxAge: 8760h
+validation:
+  limits:
+    water:
+      physical: {min: -3, max: 45}
+      plausible: {min: 0, max: 30}
+cors:
+  allowedOrigins: ["https://example.com"]
+`
+
+func writeConfigFile(t *testing.T, contents string) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(contents), 0600)
+	t.Setenv(FileEnvVar, path)
+}
+
+func TestThatConfigIsLoadedFromFile(t *testing.T) {
+	is := is.New(t)
+	writeConfigFile(t, testConfigFile)
+
+	cfg, err := Load()
+	is.NoErr(err)
+
+	is.Equal(cfg.HTTP.Port, 9090)                              // values in the file should be used
+	is.Equal(cfg.HTTP.ReadTimeout, Default().HTTP.ReadTimeout) // and defaults kept for the rest
+	is.Equal(cfg.Database.MaxOpenConns, 20)
+	is.Equal(cfg.Retention.MaxAge, 365*24*time.Hour)                           // durations should be parsed
+	is.Equal(cfg.Validation.Limits["water"].Plausible.Max, 30.0)               // validation rules should be overridden per kind
+	is.Equal(cfg.Validation.Limits["air"], Default().Validation.Limits["air"]) // and kept for the other kinds
+	is.Equal(cfg.CORS.AllowedOrigins, []string{"https://example.com"})
+}
+
+func TestThatEnvironmentOverridesFile(t *testing.T) {
+	is := is.New(t)
+	writeConfigFile(t, testConfigFile)
+
+	t.Setenv("TEMPERATURE_API_PORT", "8881")
+	t.Setenv("TEMPERATURE_DB_CONNECT_MAX_ATTEMPTS", "3")
+	t.Setenv("TEMPERATURE_CORS_ALLOWED_ORIGINS", "https://a.example.com, https://b.example.com")
+
+	cfg, err := Load()
+	is.NoErr(err)
+
+	is.Equal(cfg.HTTP.Port, 8881)
+	is.Equal(cfg.Database.Connect.MaxAttempts, 3)
+	is.Equal(cfg.Database.Host, "postgresdb") // values that are not overridden should be kept
+	is.Equal(cfg.CORS.AllowedOrigins, []string{"https://a.example.com", "https://b.example.com"})
+}
+
+func TestThatConfigCanBeLoadedFromEnvironmentOnly(t *testing.T) {
+	is := is.New(t)
+
+	t.Setenv("TEMPERATURE_DB_HOST", "postgresdb")
+	t.Setenv("TEMPERATURE_DB_USER", "testuser")
+	t.Setenv("TEMPERATURE_DB_NAME", "temperature")
+
+	cfg, err := Load()
+	is.NoErr(err)
+	is.Equal(cfg.Database.Name, "temperature")
+}
+
+func TestThatInvalidEnvironmentValuesAreAnError(t *testing.T) {
+	is := is.New(t)
+
+	t.Setenv("TEMPERATURE_DB_CONNECT_MAX_BACKOFF", "forever")
+
+	_, err := Load()
+	is.True(err != nil)                                                          // should not fall back to the default
+	is.True(strings.Contains(err.Error(), "TEMPERATURE_DB_CONNECT_MAX_BACKOFF")) // and name the offending variable
+}
+
+func TestThatUnknownKeysInFileAreAnError(t *testing.T) {
+	is := is.New(t)
+	writeConfigFile(t, "http:\n  prot: 9090\n")
+
+	_, err := Load()
+	is.True(err != nil) // a misspelled key should not be silently ignored
+}
+
+func TestThatValidationReportsAllProblems(t *testing.T) {
+	is := is.New(t)
+
+	cfg := Default()
+	cfg.HTTP.Port = 0
+	cfg.Messaging.Port = 5673
+	cfg.Auth.Enabled = true
+
+	err := cfg.Validate()
+	is.True(err != nil)
+
+	for _, problem := range []string{"http.port", "messaging.port", "database.host", "auth.issuer", "auth.jwksURL"} {
+		is.True(strings.Contains(err.Error(), problem)) // every problem should be reported
+	}
+}
+
+func TestThatSecretsAreRedacted(t *testing.T) {
+	is := is.New(t)
+
+	cfg := Default()
+	cfg.Database.Password = "testpass"
+	cfg.Auth.StaticKeys = []StaticKey{{Key: "testkey", Subject: "tester"}}
+
+	out, err := cfg.Redacted().Marshal()
+	is.NoErr(err)
+
+	is.True(!strings.Contains(string(out), "testpass"))              // the password should not be shown
+	is.True(strings.Contains(string(out), "password: '[REDACTED]'")) // but it should be clear that one is set
+	is.True(!strings.Contains(string(out), "testkey"))               // and neither should static keys
+	is.Equal(cfg.Database.Password, "testpass")                      // and the original should be left as it was
+	is.Equal(cfg.Auth.StaticKeys[0].Key, "testkey")
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type envSetter func(cfg *Config, value string) error
+
+func setString(field func(*Config) *string) envSetter {
+	return func(cfg *Config, value string) error {
+		*field(cfg) = value
+		return nil
+	}
+}
+
+func setInt(field func(*Config) *int) envSetter {
+	return func(cfg *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		*field(cfg) = n
+		return nil
+	}
+}
+
+func setBool(field func(*Config) *bool) envSetter {
+	return func(cfg *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+		*field(cfg) = b
+		return nil
+	}
+}
+
+func setDuration(field func(*Config) *time.Duration) envSetter {
+	return func(cfg *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("must be a duration, such as 500ms or 5s")
+		}
+		*field(cfg) = d
+		return nil
+	}
+}
+
+func setList(field func(*Config) *[]string) envSetter {
+	return func(cfg *Config, value string) error {
+		list := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(cfg) = list
+		return nil
+	}
+}
+
+//environment lists the environment variables that override the configuration file. The names
+//of the variables that were used before there was a configuration file are kept as they were.
+var environment = []struct {
+	name string
+	set  envSetter
+}{
+	{"TEMPERATURE_API_PORT", setInt(func(c *Config) *int { return &c.HTTP.Port })},
+	{"TEMPERATURE_API_READ_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.HTTP.ReadTimeout })},
+	{"TEMPERATURE_API_WRITE_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.HTTP.WriteTimeout })},
+	{"TEMPERATURE_API_IDLE_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.HTTP.IdleTimeout })},
+
+	{"TEMPERATURE_DB_HOST", setString(func(c *Config) *string { return &c.Database.Host })},
+	{"TEMPERATURE_DB_USER", setString(func(c *Config) *string { return &c.Database.User })},
+	{"TEMPERATURE_DB_NAME", setString(func(c *Config) *string { return &c.Database.Name })},
+	{"TEMPERATURE_DB_PASSWORD", setString(func(c *Config) *string { return &c.Database.Password })},
+	{"TEMPERATURE_DB_SSLMODE", setString(func(c *Config) *string { return &c.Database.SSLMode })},
+	{"TEMPERATURE_DB_MAX_OPEN_CONNS", setInt(func(c *Config) *int { return &c.Database.MaxOpenConns })},
+	{"TEMPERATURE_DB_MAX_IDLE_CONNS", setInt(func(c *Config) *int { return &c.Database.MaxIdleConns })},
+	{"TEMPERATURE_DB_CONN_MAX_LIFETIME", setDuration(func(c *Config) *time.Duration { return &c.Database.ConnMaxLifetime })},
+	{"TEMPERATURE_DB_CONNECT_MAX_ATTEMPTS", setInt(func(c *Config) *int { return &c.Database.Connect.MaxAttempts })},
+	{"TEMPERATURE_DB_CONNECT_INITIAL_BACKOFF", setDuration(func(c *Config) *time.Duration { return &c.Database.Connect.InitialBackoff })},
+	{"TEMPERATURE_DB_CONNECT_MAX_BACKOFF", setDuration(func(c *Config) *time.Duration { return &c.Database.Connect.MaxBackoff })},
+
+	{"RABBITMQ_PORT", setInt(func(c *Config) *int { return &c.Messaging.Port })},
+	{"RABBITMQ_CONNECT_MAX_ATTEMPTS", setInt(func(c *Config) *int { return &c.Messaging.Connect.MaxAttempts })},
+	{"RABBITMQ_CONNECT_INITIAL_BACKOFF", setDuration(func(c *Config) *time.Duration { return &c.Messaging.Connect.InitialBackoff })},
+	{"RABBITMQ_CONNECT_MAX_BACKOFF", setDuration(func(c *Config) *time.Duration { return &c.Messaging.Connect.MaxBackoff })},
+
+	{"TEMPERATURE_RETENTION_MAX_AGE", setDuration(func(c *Config) *time.Duration { return &c.Retention.MaxAge })},
+	{"TEMPERATURE_RETENTION_INTERVAL", setDuration(func(c *Config) *time.Duration { return &c.Retention.Interval })},
+
+	{"TEMPERATURE_CORS_ALLOWED_ORIGINS", setList(func(c *Config) *[]string { return &c.CORS.AllowedOrigins })},
+	{"TEMPERATURE_CORS_ALLOW_CREDENTIALS", setBool(func(c *Config) *bool { return &c.CORS.AllowCredentials })},
+
+	{"TEMPERATURE_AUTH_ENABLED", setBool(func(c *Config) *bool { return &c.Auth.Enabled })},
+	{"TEMPERATURE_AUTH_MODE", setString(func(c *Config) *string { return &c.Auth.Mode })},
+	{"TEMPERATURE_AUTH_ISSUER", setString(func(c *Config) *string { return &c.Auth.Issuer })},
+	{"TEMPERATURE_AUTH_AUDIENCE", setString(func(c *Config) *string { return &c.Auth.Audience })},
+	{"TEMPERATURE_AUTH_JWKS_URL", setString(func(c *Config) *string { return &c.Auth.JWKSURL })},
+	{"TEMPERATURE_AUTH_ALLOW_ANONYMOUS_READ", setBool(func(c *Config) *bool { return &c.Auth.AllowAnonymousRead })},
+
+	{"TEMPERATURE_RATELIMIT_ENABLED", setBool(func(c *Config) *bool { return &c.RateLimit.Enabled })},
+	{"TEMPERATURE_RATELIMIT_TRUST_FORWARDED_FOR", setBool(func(c *Config) *bool { return &c.RateLimit.TrustForwardedFor })},
+
+	{"TEMPERATURE_QUERY_CACHE_ENABLED", setBool(func(c *Config) *bool { return &c.QueryCache.Enabled })},
+	{"TEMPERATURE_QUERY_CACHE_TTL", setDuration(func(c *Config) *time.Duration { return &c.QueryCache.TTL })},
+
+	{"TEMPERATURE_DEVICE_REGISTRY_ENABLED", setBool(func(c *Config) *bool { return &c.DeviceRegistry.Enabled })},
+	{"TEMPERATURE_DEVICE_REGISTRY_URL", setString(func(c *Config) *string { return &c.DeviceRegistry.URL })},
+	{"TEMPERATURE_DEVICE_REGISTRY_TENANTS", setList(func(c *Config) *[]string { return &c.DeviceRegistry.Tenants })},
+	{"TEMPERATURE_DEVICE_REGISTRY_REFRESH_INTERVAL", setDuration(func(c *Config) *time.Duration { return &c.DeviceRegistry.RefreshInterval })},
+}
+
+func applyEnvironment(cfg *Config) error {
+	for _, e := range environment {
+		value, ok := os.LookupEnv(e.name)
+		if !ok {
+			continue
+		}
+
+		if err := e.set(cfg, value); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %s", value, e.name, err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,279 @@
+package deviceregistry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diwise/ngsi-ld-golang/pkg/datamodels/fiware"
+	"github.com/rs/zerolog"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/tenancy"
+)
+
+//pageSize is how many entities are fetched from the registry per request
+const pageSize = 100
+
+//Device is the metadata that the device registry keeps about a device. Any of the fields, except the
+//id, may be empty if the registry does not know them.
+type Device struct {
+	ID    string
+	Name  string
+	Owner string
+	Site  string
+	Model string
+}
+
+//Client keeps the devices of the device registry in memory, so that readings can be enriched with
+//the metadata of their devices without asking the registry for every reading. The devices of each
+//of the configured tenants are kept apart, as the same device id may mean different devices in
+//different tenants.
+type Client struct {
+	cfg    config.DeviceRegistry
+	client *http.Client
+
+	mu      sync.RWMutex
+	devices map[string]map[string]Device
+}
+
+//NewClient creates a Client for the configured registry. It knows no devices until it has been refreshed.
+func NewClient(cfg config.DeviceRegistry) *Client {
+	return &Client{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		devices: map[string]map[string]Device{},
+	}
+}
+
+//Device returns the metadata of a device of a tenant, identified in the same way as the device of a
+//reading, if the registry knows the device. A nil client knows no devices, which is how it is disabled.
+func (c *Client) Device(tenant, id string) (Device, bool) {
+	if c == nil || id == "" {
+		return Device{}, false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	d, ok := c.devices[tenant][id]
+	return d, ok
+}
+
+//Start refreshes the devices once and then at every refresh interval, until ctx is done. The devices
+//that were fetched last are kept when a refresh fails, as stale metadata is better than none.
+func (c *Client) Start(ctx context.Context, log zerolog.Logger) {
+	log = log.With().Str("url", c.cfg.URL).Logger()
+
+	refresh := func() {
+		if err := c.Refresh(ctx); err != nil {
+			log.Error().Err(err).Msg("failed to refresh devices from the device registry")
+			return
+		}
+
+		log.Debug().Int("devices", c.count()).Msg("refreshed devices from the device registry")
+	}
+
+	go func() {
+		ticker := time.NewTicker(c.cfg.RefreshInterval)
+		defer ticker.Stop()
+
+		refresh()
+
+		for {
+			select {
+			case <-ticker.C:
+				refresh()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+//Refresh fetches every device of the configured tenants, and the models that the devices refer to,
+//from the registry and replaces the devices that are kept in memory. The devices of a tenant that
+//can not be fetched are kept as they were, and the last error is returned once the other tenants
+//have been refreshed.
+func (c *Client) Refresh(ctx context.Context) error {
+	var err error
+
+	for _, tenant := range c.cfg.Tenants {
+		devices, tenantErr := c.fetchDevices(ctx, tenant)
+		if tenantErr != nil {
+			err = fmt.Errorf("failed to refresh the devices of tenant %s: %w", tenant, tenantErr)
+			continue
+		}
+
+		c.mu.Lock()
+		c.devices[tenant] = devices
+		c.mu.Unlock()
+	}
+
+	return err
+}
+
+//fetchDevices fetches the devices of a tenant, and the models that the devices refer to, from the registry
+func (c *Client) fetchDevices(ctx context.Context, tenant string) (map[string]Device, error) {
+	models := map[string]string{}
+
+	err := c.fetch(ctx, tenant, "DeviceModel", func(e entity) {
+		name := e.text("name")
+		if name == "" {
+			name = e.text("modelName")
+		}
+		models[e.ID] = name
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	devices := map[string]Device{}
+
+	err = c.fetch(ctx, tenant, "Device", func(e entity) {
+		d := Device{
+			ID:    strings.TrimPrefix(e.ID, fiware.DeviceIDPrefix),
+			Name:  e.text("name"),
+			Owner: e.text("owner"),
+			Site:  e.text("areaServed"),
+		}
+
+		if ref := e.object("refDeviceModel"); ref != "" {
+			d.Model = models[ref]
+			if d.Model == "" {
+				d.Model = strings.TrimPrefix(ref, fiware.DeviceModelIDPrefix)
+			}
+		}
+
+		devices[d.ID] = d
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
+
+func (c *Client) count() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	count := 0
+	for _, devices := range c.devices {
+		count += len(devices)
+	}
+
+	return count
+}
+
+//fetch pages through the entities of a type within a tenant and calls found for each of them
+func (c *Client) fetch(ctx context.Context, tenant, entityType string, found func(entity)) error {
+	for offset := 0; ; offset += pageSize {
+		query := url.Values{}
+		query.Set("type", entityType)
+		query.Set("limit", strconv.Itoa(pageSize))
+		query.Set("offset", strconv.Itoa(offset))
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(c.cfg.URL, "/")+"/ngsi-ld/v1/entities?"+query.Encode(), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/ld+json")
+		req.Header.Set(tenancy.HeaderName, tenant)
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to fetch %s entities: %s", entityType, err.Error())
+		}
+
+		entities := []entity{}
+
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("failed to fetch %s entities: unexpected status code %d", entityType, resp.StatusCode)
+		} else if decodeErr := json.NewDecoder(resp.Body).Decode(&entities); decodeErr != nil {
+			err = fmt.Errorf("failed to decode %s entities: %s", entityType, decodeErr.Error())
+		}
+
+		resp.Body.Close()
+
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entities {
+			found(e)
+		}
+
+		if len(entities) < pageSize {
+			return nil
+		}
+	}
+}
+
+//entity is an NGSI-LD entity in either the normalized or the key values representation
+type entity struct {
+	ID         string
+	attributes map[string]json.RawMessage
+}
+
+func (e *entity) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &e.attributes); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(e.attributes["id"], &e.ID)
+}
+
+//text returns the value of a property as text. The values of a list are joined by commas.
+func (e entity) text(name string) string {
+	raw, ok := e.attributes[name]
+	if !ok {
+		return ""
+	}
+
+	property := struct {
+		Value json.RawMessage `json:"value"`
+	}{}
+
+	if json.Unmarshal(raw, &property) == nil && property.Value != nil {
+		raw = property.Value
+	}
+
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+
+	var list []string
+	if json.Unmarshal(raw, &list) == nil {
+		return strings.Join(list, ", ")
+	}
+
+	return ""
+}
+
+//object returns the id of the entity that a relationship refers to
+func (e entity) object(name string) string {
+	raw, ok := e.attributes[name]
+	if !ok {
+		return ""
+	}
+
+	relationship := struct {
+		Object string `json:"object"`
+	}{}
+
+	if json.Unmarshal(raw, &relationship) == nil && relationship.Object != "" {
+		return relationship.Object
+	}
+
+	var s string
+	json.Unmarshal(raw, &s)
+	return s
+}
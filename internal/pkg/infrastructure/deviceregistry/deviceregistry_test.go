@@ -0,0 +1,150 @@
+package deviceregistry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/rs/zerolog"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+)
+
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	cfg := config.Default().DeviceRegistry
+	cfg.URL = server.URL
+
+	return NewClient(cfg)
+}
+
+func TestThatDevicesAreFetchedFromTheRegistry(t *testing.T) {
+	is := is.New(t)
+
+	client := newTestClient(t, NewStub(
+		Device{ID: "se:servanet:lora:sk-elt-temp-02", Name: "Brygga 2", Owner: "Sundsvalls kommun", Site: "Norra stadsfjärden", Model: "Elsys ELT Lite"},
+		Device{ID: "unnamed"},
+	))
+
+	_, found := client.Device(models.DefaultTenant, "se:servanet:lora:sk-elt-temp-02")
+	is.True(!found) // devices should not be known before the client has been refreshed
+
+	is.NoErr(client.Refresh(context.Background()))
+
+	d, found := client.Device(models.DefaultTenant, "se:servanet:lora:sk-elt-temp-02")
+	is.True(found)                         // the device should be known by the id that readings use
+	is.Equal(d.Name, "Brygga 2")           // with its name
+	is.Equal(d.Owner, "Sundsvalls kommun") // owner
+	is.Equal(d.Site, "Norra stadsfjärden") // installation site
+	is.Equal(d.Model, "Elsys ELT Lite")    // and the name of its model
+
+	d, found = client.Device(models.DefaultTenant, "unnamed")
+	is.True(found)       // devices without metadata should be known as well
+	is.Equal(d.Name, "") // but without a name
+
+	_, found = client.Device(models.DefaultTenant, "unknown")
+	is.True(!found) // devices that the registry does not know should not be found
+}
+
+func TestThatAllPagesOfDevicesAreFetched(t *testing.T) {
+	is := is.New(t)
+
+	devices := []Device{}
+	for i := 0; i < pageSize*2+1; i++ {
+		devices = append(devices, Device{ID: fmt.Sprintf("device-%d", i)})
+	}
+
+	client := newTestClient(t, NewStub(devices...))
+	is.NoErr(client.Refresh(context.Background()))
+
+	_, found := client.Device(models.DefaultTenant, fmt.Sprintf("device-%d", pageSize*2))
+	is.True(found) // the devices on the last page should be known
+}
+
+func TestThatTheDevicesOfEachTenantAreKeptApart(t *testing.T) {
+	is := is.New(t)
+
+	server := httptest.NewServer(NewTenantStub(map[string][]Device{
+		"sundsvall": {{ID: "sensor", Name: "Brygga 2"}},
+		"timra":     {{ID: "sensor", Name: "Bron"}},
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(config.DeviceRegistry{URL: server.URL, Tenants: []string{"sundsvall", "timra"}, Timeout: time.Second})
+	is.NoErr(client.Refresh(context.Background()))
+
+	d, found := client.Device("sundsvall", "sensor")
+	is.True(found)               // the device should be known within its tenant
+	is.Equal(d.Name, "Brygga 2") // with the metadata of that tenant
+
+	d, found = client.Device("timra", "sensor")
+	is.True(found)           // a device with the same id in another tenant
+	is.Equal(d.Name, "Bron") // should keep its own metadata
+
+	_, found = client.Device(models.DefaultTenant, "sensor")
+	is.True(!found) // and tenants that are not configured should know no devices
+}
+
+func TestThatDevicesAreKeptWhenTheRegistryFails(t *testing.T) {
+	is := is.New(t)
+
+	var failing int32
+	stub := NewStub(Device{ID: "sensor", Name: "Sensor"})
+
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		stub.ServeHTTP(w, r)
+	}))
+
+	is.NoErr(client.Refresh(context.Background()))
+
+	atomic.StoreInt32(&failing, 1)
+	is.True(client.Refresh(context.Background()) != nil) // the failure should be reported
+
+	d, found := client.Device(models.DefaultTenant, "sensor")
+	is.True(found)             // and the devices from the last refresh should be kept
+	is.Equal(d.Name, "Sensor") // as they were
+}
+
+func TestThatDevicesAreFetchedWhenTheClientIsStarted(t *testing.T) {
+	is := is.New(t)
+
+	server := httptest.NewServer(NewStub(Device{ID: "sensor", Name: "Sensor"}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(config.DeviceRegistry{URL: server.URL, Tenants: []string{models.DefaultTenant}, RefreshInterval: time.Hour, Timeout: time.Second})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client.Start(ctx, zerolog.Nop())
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, found := client.Device(models.DefaultTenant, "sensor"); found {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	is.Fail() // the devices should be fetched when the client is started
+}
+
+func TestThatANilClientKnowsNoDevices(t *testing.T) {
+	is := is.New(t)
+
+	var client *Client
+	_, found := client.Device(models.DefaultTenant, "sensor")
+	is.True(!found)
+}
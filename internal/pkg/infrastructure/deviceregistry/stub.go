@@ -0,0 +1,101 @@
+package deviceregistry
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/diwise/ngsi-ld-golang/pkg/datamodels/fiware"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/tenancy"
+)
+
+//NewStub returns a handler that serves the provided devices, and the models that they refer to, in
+//the same way as the NGSI-LD API of the device registry does, as the devices of the default tenant.
+//It is meant to stand in for the registry in tests.
+func NewStub(devices ...Device) http.Handler {
+	return NewTenantStub(map[string][]Device{models.DefaultTenant: devices})
+}
+
+//NewTenantStub returns a handler that serves the devices of each tenant, and the models that they
+//refer to, to the requests that ask for the tenant with the NGSILD-Tenant header. Requests without
+//the header ask for the default tenant.
+func NewTenantStub(devices map[string][]Device) http.Handler {
+	tenants := map[string]map[string][]map[string]interface{}{}
+	for tenant, d := range devices {
+		tenants[tenant] = stubEntities(d)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/ngsi-ld/v1/entities" {
+			http.NotFound(w, r)
+			return
+		}
+
+		tenant := r.Header.Get(tenancy.HeaderName)
+		if tenant == "" {
+			tenant = models.DefaultTenant
+		}
+
+		page := tenants[tenant][r.URL.Query().Get("type")]
+
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if offset > len(page) {
+			offset = len(page)
+		}
+		page = page[offset:]
+
+		if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit < len(page) {
+			page = page[:limit]
+		}
+
+		if page == nil {
+			page = []map[string]interface{}{}
+		}
+
+		w.Header().Set("Content-Type", "application/ld+json")
+		json.NewEncoder(w).Encode(page)
+	})
+}
+
+//stubEntities returns the entities of the devices, and of the models that they refer to, by type
+func stubEntities(devices []Device) map[string][]map[string]interface{} {
+	entities := map[string][]map[string]interface{}{}
+	known := map[string]bool{}
+
+	for _, d := range devices {
+		device := map[string]interface{}{
+			"id":   fiware.DeviceIDPrefix + d.ID,
+			"type": "Device",
+		}
+
+		setStubProperty(device, "name", d.Name)
+		setStubProperty(device, "owner", d.Owner)
+		setStubProperty(device, "areaServed", d.Site)
+
+		if d.Model != "" {
+			modelID := fiware.DeviceModelIDPrefix + strings.ReplaceAll(strings.ToLower(d.Model), " ", "-")
+			device["refDeviceModel"] = map[string]interface{}{"type": "Relationship", "object": modelID}
+
+			if !known[modelID] {
+				known[modelID] = true
+
+				model := map[string]interface{}{"id": modelID, "type": "DeviceModel"}
+				setStubProperty(model, "name", d.Model)
+				entities["DeviceModel"] = append(entities["DeviceModel"], model)
+			}
+		}
+
+		entities["Device"] = append(entities["Device"], device)
+	}
+
+	return entities
+}
+
+func setStubProperty(entity map[string]interface{}, name, value string) {
+	if value != "" {
+		entity[name] = map[string]interface{}{"type": "Property", "value": value}
+	}
+}
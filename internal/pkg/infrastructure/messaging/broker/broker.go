@@ -0,0 +1,22 @@
+package broker
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/diwise/messaging-golang/pkg/messaging"
+)
+
+//ConnectionString returns the AMQP URL of the broker that cfg points out, on the provided port. The
+//messaging configuration does not carry a port, so it is configured separately.
+func ConnectionString(cfg messaging.Config, port int) string {
+	u := url.URL{
+		Scheme: "amqp",
+		User:   url.UserPassword(cfg.User, cfg.Password),
+		Host:   net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		Path:   "/",
+	}
+
+	return u.String()
+}
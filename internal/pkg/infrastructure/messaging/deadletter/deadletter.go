@@ -0,0 +1,323 @@
+package deadletter
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/rs/zerolog"
+
+	"github.com/diwise/messaging-golang/pkg/messaging"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/messaging/broker"
+)
+
+//ErrNotFound is returned when a dead-lettered message can not be found
+var ErrNotFound = errors.New("dead-lettered message not found")
+
+const (
+	//ExchangeName is the name of the exchange that dead-lettered messages are published to
+	ExchangeName = "api-temperature.dlx"
+	//QueueName is the name of the durable queue that holds dead-lettered messages
+	QueueName = "api-temperature.deadletters"
+
+	headerReason         = "x-diwise-error"
+	headerRoutingKey     = "x-diwise-routing-key"
+	headerAttempts       = "x-diwise-attempts"
+	headerDeadLetteredAt = "x-diwise-dead-lettered-at"
+)
+
+//Message is a telemetry message that could not be handled, together with the reason why
+type Message struct {
+	ID             string    `json:"id"`
+	RoutingKey     string    `json:"routingKey"`
+	ContentType    string    `json:"contentType"`
+	Body           []byte    `json:"body"`
+	Reason         string    `json:"reason"`
+	Attempts       int       `json:"attempts"`
+	DeadLetteredAt time.Time `json:"deadLetteredAt"`
+}
+
+//Store is an interface that is used to inject the dead letter storage into different handlers
+type Store interface {
+	Add(msg amqp.Delivery, reason error, attempts int) error
+	Get(id string) (Message, error)
+	List() []Message
+	Remove(id string) error
+	IsClosed() bool
+	Close() error
+}
+
+//NewInMemoryStore creates a Store that only keeps dead-lettered messages in memory
+func NewInMemoryStore() Store {
+	return &inMemoryStore{messages: map[string]Message{}}
+}
+
+type inMemoryStore struct {
+	mu       sync.Mutex
+	messages map[string]Message
+}
+
+func (s *inMemoryStore) Add(msg amqp.Delivery, reason error, attempts int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := newMessage(msg, reason, attempts)
+	s.messages[m.ID] = m
+
+	return nil
+}
+
+func (s *inMemoryStore) Get(id string) (Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.messages[id]
+	if !ok {
+		return Message{}, ErrNotFound
+	}
+
+	return m, nil
+}
+
+func (s *inMemoryStore) List() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sortedMessages(s.messages)
+}
+
+func (s *inMemoryStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.messages[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(s.messages, id)
+	return nil
+}
+
+func (s *inMemoryStore) IsClosed() bool {
+	return false
+}
+
+func (s *inMemoryStore) Close() error {
+	return nil
+}
+
+//NewAMQPStore creates a Store that publishes dead-lettered messages to a durable dead letter
+//exchange. Messages in the dead letter queue are consumed without being acknowledged, so that
+//they remain in the queue until they are removed (i.e. replayed) via the Store.
+func NewAMQPStore(cfg messaging.Config, port int, log zerolog.Logger) (Store, error) {
+	conn, err := amqp.Dial(broker.ConnectionString(cfg, port))
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to message queue: %s", err.Error())
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to create an amqp channel: %s", err.Error())
+	}
+
+	s := &amqpStore{
+		conn:     conn,
+		channel:  channel,
+		messages: map[string]amqpMessage{},
+		log:      log.With().Str("queue", QueueName).Logger(),
+	}
+
+	if err = s.declare(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	deliveries, err := channel.Consume(QueueName, "deadletter-consumer", false, false, false, false, nil)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to start consuming dead letters: %s", err.Error())
+	}
+
+	go s.consume(deliveries)
+
+	return s, nil
+}
+
+type amqpMessage struct {
+	Message
+	tag uint64
+}
+
+type amqpStore struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+
+	mu       sync.Mutex
+	messages map[string]amqpMessage
+
+	log zerolog.Logger
+}
+
+func (s *amqpStore) declare() error {
+	err := s.channel.ExchangeDeclare(ExchangeName, amqp.ExchangeFanout, true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("unable to declare dead letter exchange %s: %s", ExchangeName, err.Error())
+	}
+
+	_, err = s.channel.QueueDeclare(QueueName, true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("unable to declare dead letter queue %s: %s", QueueName, err.Error())
+	}
+
+	err = s.channel.QueueBind(QueueName, "", ExchangeName, false, nil)
+	if err != nil {
+		return fmt.Errorf("unable to bind dead letter queue %s to %s: %s", QueueName, ExchangeName, err.Error())
+	}
+
+	return nil
+}
+
+func (s *amqpStore) consume(deliveries <-chan amqp.Delivery) {
+	for d := range deliveries {
+		m := amqpMessage{
+			Message: Message{
+				ID:          d.MessageId,
+				ContentType: d.ContentType,
+				Body:        d.Body,
+			},
+			tag: d.DeliveryTag,
+		}
+
+		if m.ID == "" {
+			m.ID = uuid.New().String()
+		}
+
+		m.RoutingKey, _ = d.Headers[headerRoutingKey].(string)
+		m.Reason, _ = d.Headers[headerReason].(string)
+
+		if attempts, ok := d.Headers[headerAttempts].(int32); ok {
+			m.Attempts = int(attempts)
+		}
+
+		if ts, ok := d.Headers[headerDeadLetteredAt].(time.Time); ok {
+			m.DeadLetteredAt = ts
+		}
+
+		s.mu.Lock()
+		s.messages[m.ID] = m
+		s.mu.Unlock()
+	}
+
+	s.log.Warn().Msg("dead letter consumer stopped")
+}
+
+func (s *amqpStore) Add(msg amqp.Delivery, reason error, attempts int) error {
+	m := newMessage(msg, reason, attempts)
+
+	err := s.channel.Publish(ExchangeName, "", false, false, amqp.Publishing{
+		MessageId:    m.ID,
+		ContentType:  m.ContentType,
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    m.DeadLetteredAt,
+		Headers: amqp.Table{
+			headerReason:         m.Reason,
+			headerRoutingKey:     m.RoutingKey,
+			headerAttempts:       int32(m.Attempts),
+			headerDeadLetteredAt: m.DeadLetteredAt,
+		},
+		Body: m.Body,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish message to dead letter exchange: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (s *amqpStore) Get(id string) (Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.messages[id]
+	if !ok {
+		return Message{}, ErrNotFound
+	}
+
+	return m.Message, nil
+}
+
+func (s *amqpStore) List() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages := map[string]Message{}
+	for id, m := range s.messages {
+		messages[id] = m.Message
+	}
+
+	return sortedMessages(messages)
+}
+
+func (s *amqpStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.messages[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if err := s.channel.Ack(m.tag, false); err != nil {
+		return fmt.Errorf("failed to acknowledge dead letter %s: %s", id, err.Error())
+	}
+
+	delete(s.messages, id)
+	return nil
+}
+
+//Close closes the channel and the connection that the store uses
+//IsClosed returns true if the channel to the broker, or the connection that it belongs to, has been closed
+func (s *amqpStore) IsClosed() bool {
+	return s.channel.IsClosed()
+}
+
+func (s *amqpStore) Close() error {
+	s.channel.Close()
+	return s.conn.Close()
+}
+
+func newMessage(msg amqp.Delivery, reason error, attempts int) Message {
+	m := Message{
+		ID:             uuid.New().String(),
+		RoutingKey:     msg.RoutingKey,
+		ContentType:    msg.ContentType,
+		Body:           msg.Body,
+		Attempts:       attempts,
+		DeadLetteredAt: time.Now().UTC(),
+	}
+
+	if reason != nil {
+		m.Reason = reason.Error()
+	}
+
+	return m
+}
+
+func sortedMessages(messages map[string]Message) []Message {
+	result := make([]Message, 0, len(messages))
+	for _, m := range messages {
+		result = append(result, m)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].DeadLetteredAt.Before(result[j].DeadLetteredAt)
+	})
+
+	return result
+}
@@ -0,0 +1,271 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+const namespace = "temperature"
+
+var (
+	messagesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_received_total",
+		Help:      "Number of temperature messages received per topic, counted once no matter how many times they are retried.",
+	}, []string{"topic"})
+
+	messagesProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_processed_total",
+		Help:      "Number of temperature messages processed per topic and final outcome (stored, duplicate, rejected or failed).",
+	}, []string{"topic", "outcome"})
+
+	insertDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "insert_duration_seconds",
+		Help:      "Time it takes to insert a temperature measurement in the datastore.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	queueLag = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "queue_lag_seconds",
+		Help:      "Time from when a message was published until it was received from the queue.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 15, 30, 60, 300, 900, 3600},
+	}, []string{"topic"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests per route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	graphQLOperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "graphql_operation_duration_seconds",
+		Help:      "Duration of GraphQL operations per operation type (query, mutation, subscription or unknown).",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	datastoreQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "datastore_query_duration_seconds",
+		Help:      "Duration of datastore queries per operation and table.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "table"})
+
+	rateLimitedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ratelimit_requests_total",
+		Help:      "Number of requests per client and budget that were allowed or limited by the rate limiter.",
+	}, []string{"client", "budget", "outcome"})
+
+	queryCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "query_cache_lookups_total",
+		Help:      "Number of lookups in the query cache per outcome (hit or miss).",
+	}, []string{"outcome"})
+
+	newestReadings = newNewestReadingCollector()
+)
+
+func init() {
+	prometheus.MustRegister(newestReadings)
+}
+
+//Handler returns the handler that serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+//MessageReceived counts a message that was received on a topic
+func MessageReceived(topic string) {
+	messagesReceived.WithLabelValues(topic).Inc()
+}
+
+//MessageProcessed counts the outcome of processing a message that was received on a topic
+func MessageProcessed(topic, outcome string) {
+	messagesProcessed.WithLabelValues(topic, outcome).Inc()
+}
+
+//ObserveQueueLag records the time that a message received on a topic has spent on its way to this service
+func ObserveQueueLag(topic string, sent time.Time) {
+	queueLag.WithLabelValues(topic).Observe(time.Since(sent).Seconds())
+}
+
+//ObserveInsert records the time it took to insert a measurement in the datastore
+func ObserveInsert(started time.Time) {
+	insertDuration.Observe(time.Since(started).Seconds())
+}
+
+//ObserveGraphQLOperation records the duration of a GraphQL operation of a type. Operations are not
+//labelled by name, as the names are chosen by the clients and there is no end to them.
+func ObserveGraphQLOperation(operationType string, started time.Time) {
+	switch operationType {
+	case "query", "mutation", "subscription":
+	default:
+		operationType = "unknown"
+	}
+	graphQLOperationDuration.WithLabelValues(operationType).Observe(time.Since(started).Seconds())
+}
+
+//RateLimitDecision counts a request from a client that was either allowed or limited by a budget
+func RateLimitDecision(client, budget string, allowed bool) {
+	outcome := "limited"
+	if allowed {
+		outcome = "allowed"
+	}
+	rateLimitedRequests.WithLabelValues(client, budget, outcome).Inc()
+}
+
+//ForgetRateLimitedClient removes the usage of a client that has not been seen in a while, so that
+//the number of time series does not grow with every client that has ever made a request
+func ForgetRateLimitedClient(client, budget string) {
+	rateLimitedRequests.DeleteLabelValues(client, budget, "allowed")
+	rateLimitedRequests.DeleteLabelValues(client, budget, "limited")
+}
+
+//QueryCacheLookup counts a lookup in the query cache that either found a result or not
+func QueryCacheLookup(hit bool) {
+	outcome := "miss"
+	if hit {
+		outcome = "hit"
+	}
+	queryCacheLookups.WithLabelValues(outcome).Inc()
+}
+
+//ObserveReading keeps track of the newest reading of each kind, so that the age of it can be reported
+func ObserveReading(kind string, timestamp time.Time) {
+	newestReadings.observe(kind, timestamp)
+}
+
+//Middleware records the duration of HTTP requests, labelled with the route pattern that matched
+//the request rather than the path, so that the number of time series stays bounded
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		httpRequestDuration.WithLabelValues(route, r.Method, strconv.Itoa(status)).Observe(time.Since(started).Seconds())
+	})
+}
+
+const startedKey = "metrics:started"
+
+//InstrumentDatastore registers callbacks that record the duration of all queries made through db
+func InstrumentDatastore(db *gorm.DB) error {
+	cb := db.Callback()
+
+	errs := []error{
+		cb.Create().Before("gorm:create").Register("metrics:before_create", startQuery),
+		cb.Create().After("gorm:create").Register("metrics:after_create", endQuery("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", startQuery),
+		cb.Query().After("gorm:query").Register("metrics:after_query", endQuery("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", startQuery),
+		cb.Update().After("gorm:update").Register("metrics:after_update", endQuery("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", startQuery),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", endQuery("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", startQuery),
+		cb.Row().After("gorm:row").Register("metrics:after_row", endQuery("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", startQuery),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", endQuery("raw")),
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func startQuery(db *gorm.DB) {
+	db.InstanceSet(startedKey, time.Now())
+}
+
+func endQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(startedKey)
+		if !ok {
+			return
+		}
+
+		started, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+
+		datastoreQueryDuration.WithLabelValues(operation, table).Observe(time.Since(started).Seconds())
+	}
+}
+
+//newestReadingCollector reports the age of the newest reading of each kind at the time of collection,
+//as the age would otherwise only change when a new reading is received
+type newestReadingCollector struct {
+	desc *prometheus.Desc
+
+	mu     sync.Mutex
+	newest map[string]time.Time
+}
+
+func newNewestReadingCollector() *newestReadingCollector {
+	return &newestReadingCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "newest_reading_age_seconds"),
+			"Age of the newest temperature reading per kind.",
+			[]string{"kind"}, nil,
+		),
+		newest: map[string]time.Time{},
+	}
+}
+
+func (c *newestReadingCollector) observe(kind string, timestamp time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if timestamp.After(c.newest[kind]) {
+		c.newest[kind] = timestamp
+	}
+}
+
+func (c *newestReadingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *newestReadingCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for kind, timestamp := range c.newest {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, now.Sub(timestamp).Seconds(), kind)
+	}
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/matryer/is"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestThatRequestsAreLabelledWithTheirRoutePattern(t *testing.T) {
+	is := is.New(t)
+
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/admin/alertrules/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	httpRequestDuration.Reset()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/admin/alertrules/17", nil))
+
+	count := testutil.CollectAndCount(httpRequestDuration, "temperature_http_request_duration_seconds")
+	is.Equal(count, 1) // should have recorded the request in a single series
+
+	is.True(!httpRequestDuration.DeleteLabelValues("/admin/alertrules/17", http.MethodGet, "204"))  // that is not labelled with the path
+	is.True(httpRequestDuration.DeleteLabelValues("/admin/alertrules/{id}", http.MethodGet, "204")) // but with the route pattern
+}
+
+func TestThatGraphQLOperationsAreLabelledWithTheirType(t *testing.T) {
+	is := is.New(t)
+
+	graphQLOperationDuration.Reset()
+	ObserveGraphQLOperation("query", time.Now())
+	ObserveGraphQLOperation("GetTemperaturesForMyDashboard", time.Now())
+	ObserveGraphQLOperation("", time.Now())
+
+	is.Equal(testutil.CollectAndCount(graphQLOperationDuration), 2) // should only record known types and unknown
+
+	is.True(graphQLOperationDuration.DeleteLabelValues("query"))   // with queries labelled as such
+	is.True(graphQLOperationDuration.DeleteLabelValues("unknown")) // and everything else as unknown
+}
+
+func TestThatTheAgeOfTheNewestReadingIsReportedPerKind(t *testing.T) {
+	is := is.New(t)
+
+	c := newNewestReadingCollector()
+	c.observe("water", time.Now().Add(-2*time.Hour))
+	c.observe("water", time.Now().Add(-1*time.Hour))
+	c.observe("water", time.Now().Add(-3*time.Hour))
+
+	is.Equal(testutil.CollectAndCount(c), 1) // should report a single age for the kind
+
+	age := testutil.ToFloat64(c)
+	is.True(age >= 3600 && age < 3700) // should report the age of the newest reading
+}
+
+func TestThatMetricsAreServed(t *testing.T) {
+	is := is.New(t)
+
+	MessageReceived("telemetry.temperature")
+	MessageProcessed("telemetry.temperature", "stored")
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body, _ := io.ReadAll(w.Body)
+	is.Equal(w.Code, http.StatusOK) // should serve the metrics
+	is.True(strings.Contains(string(body), `temperature_messages_processed_total{outcome="stored",topic="telemetry.temperature"} 1`))
+}
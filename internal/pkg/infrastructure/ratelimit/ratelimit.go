@@ -0,0 +1,157 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/auth"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/metrics"
+)
+
+//sweepInterval is how often buckets that have been refilled to the brim are forgotten
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+//Limiter holds a token bucket per client, which is created full when a client is first seen
+type Limiter struct {
+	name   string
+	budget config.Budget
+	now    func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+//NewLimiter creates a Limiter that gives every client the provided budget
+func NewLimiter(name string, budget config.Budget) *Limiter {
+	return &Limiter{
+		name:    name,
+		budget:  budget,
+		now:     time.Now,
+		buckets: map[string]*bucket{},
+	}
+}
+
+//Allow takes a token from the bucket of a client and returns true, or returns false together with
+//the time until the bucket holds a token again if it is empty
+func (l *Limiter) Allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: float64(l.budget.Burst), last: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens = math.Min(float64(l.budget.Burst), b.tokens+now.Sub(b.last).Seconds()*l.budget.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.budget.Rate * float64(time.Second))
+	return false, wait
+}
+
+//sweep forgets the clients whose buckets would be full by now, as a new full bucket is the same thing
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	refill := time.Duration(float64(l.budget.Burst) / l.budget.Rate * float64(time.Second))
+
+	for client, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, client)
+			metrics.ForgetRateLimitedClient(client, l.name)
+		}
+	}
+}
+
+//route applies a limiter to all requests with paths that start with a prefix
+type route struct {
+	prefix  string
+	limiter *Limiter
+}
+
+//Middleware limits the number of requests that each client may make to the NGSI-LD and GraphQL
+//APIs and to exports, with a separate budget for each. Requests that exceed the budget of the client
+//are answered with 429 Too Many Requests and a Retry-After header. Export endpoints should be
+//registered below /export to be covered by the export budget.
+func Middleware(cfg config.RateLimit) func(http.Handler) http.Handler {
+	routes := []route{
+		{prefix: "/ngsi-ld", limiter: NewLimiter("ngsild", cfg.NGSILD)},
+		{prefix: "/api/graphql", limiter: NewLimiter("graphql", cfg.GraphQL)},
+		{prefix: "/export", limiter: NewLimiter("export", cfg.Export)},
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			for _, rt := range routes {
+				if r.URL.Path != rt.prefix && !strings.HasPrefix(r.URL.Path, rt.prefix+"/") {
+					continue
+				}
+
+				client := clientID(r, cfg.TrustForwardedFor)
+				allowed, wait := rt.limiter.Allow(client)
+				metrics.RateLimitDecision(client, rt.limiter.name, allowed)
+
+				if !allowed {
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+					http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+					return
+				}
+
+				break
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+//clientID identifies the caller of a request by the subject of its credentials, if it has
+//authenticated, or else by its IP address
+func clientID(r *http.Request, trustForwardedFor bool) string {
+	if p := auth.PrincipalFromContext(r.Context()); p != nil && !p.Anonymous {
+		return "key:" + p.Subject
+	}
+
+	if trustForwardedFor {
+		// The first address is the one that the client connected to the first proxy from
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return "ip:" + strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+)
+
+func TestThatBucketsAreEmptiedAndRefilled(t *testing.T) {
+	is := is.New(t)
+
+	now := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter("test", config.Budget{Rate: 0.5, Burst: 2})
+	l.now = func() time.Time { return now }
+
+	ok, _ := l.Allow("a")
+	is.True(ok)
+	ok, _ = l.Allow("a")
+	is.True(ok) // a client should be allowed a burst of requests
+
+	ok, wait := l.Allow("a")
+	is.True(!ok)                  // but not more than that
+	is.Equal(wait, 2*time.Second) // and be told when to come back
+	ok, _ = l.Allow("b")
+	is.True(ok) // while other clients have budgets of their own
+
+	now = now.Add(2 * time.Second)
+	ok, _ = l.Allow("a")
+	is.True(ok) // the bucket should be refilled over time
+}
+
+func TestThatLimitedRequestsAreRejectedWithRetryAfter(t *testing.T) {
+	is := is.New(t)
+
+	cfg := config.Default().RateLimit
+	cfg.NGSILD = config.Budget{Rate: 1, Burst: 1}
+
+	handler := Middleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	is.Equal(serve("/ngsi-ld/v1/entities", "10.0.0.1:1234").Code, http.StatusOK)
+
+	w := serve("/ngsi-ld/v1/entities", "10.0.0.1:4321")
+	is.Equal(w.Code, http.StatusTooManyRequests) // the second request from the same address should be limited
+	is.Equal(w.Header().Get("Retry-After"), "1")
+
+	is.Equal(serve("/ngsi-ld/v1/entities", "10.0.0.2:1234").Code, http.StatusOK) // but not requests from other addresses
+	is.Equal(serve("/api/graphql", "10.0.0.1:1234").Code, http.StatusOK)         // or to other APIs
+	is.Equal(serve("/health/ready", "10.0.0.1:1234").Code, http.StatusOK)        // and probes should never be limited
+}
@@ -5,24 +5,73 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
+	"strings"
 	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/metrics"
 	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/tracing"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 //Datastore is an interface that is used to inject the database into different handlers to improve testability
 type Datastore interface {
-	AddTemperatureMeasurement(device *string, latitude, longitude, temp float64, water bool, when string) (*models.TemperatureV2, error)
-	GetTemperatures(deviceId string, from, to time.Time, geoSpatial string, lat0, lon0, lat1, lon1 float64, resultOffset, resultLimit uint64) ([]models.TemperatureV2, error)
+	AddTemperatureMeasurement(measurement models.TemperatureV2) (*models.TemperatureV2, error)
+	ImportTemperatureMeasurements(measurements []models.TemperatureV2) ([]error, error)
+	GetTemperatures(deviceId string, mediums, quality []string, depth *DepthRange, from, to time.Time, geoSpatial string, lat0, lon0, lat1, lon1 float64, resultOffset, resultLimit uint64) ([]models.TemperatureV2, error)
+	GetDevices() ([]string, error)
+	UpdateTemperatureQuality(id uint, quality, qualityReason string, correctedTemp *float64) (*models.TemperatureV2, error)
+	DeleteTemperaturesBefore(before time.Time) (int64, error)
+
+	CreateAlertRule(rule *models.AlertRule) (*models.AlertRule, error)
+	GetAlertRules() ([]models.AlertRule, error)
+	UpdateAlertRule(id uint, rule *models.AlertRule) (*models.AlertRule, error)
+	DeleteAlertRule(id uint) error
+
+	CreateCalibration(calibration *models.Calibration) (*models.Calibration, error)
+	GetCalibrations(device string) ([]models.Calibration, error)
+	UpdateCalibration(id uint, calibration *models.Calibration) (*models.Calibration, error)
+	DeleteCalibration(id uint) error
+	ApplyCalibration(id uint) (int64, error)
+
+	GetDevicePolicies() ([]models.DevicePolicy, error)
+	SetDevicePolicy(device, visibility string) (*models.DevicePolicy, error)
+	DeleteDevicePolicy(device string) error
+
+	Ping() error
+	MigrationStatus() error
+	Close() error
+
+	WithContext(ctx context.Context) Datastore
+	ForTenant(tenant string) Datastore
+	WithoutDevices(devices []string) Datastore
 }
 
+//DepthRange is an inclusive interval of depths, in metres below the surface, to filter measurements on
+type DepthRange struct {
+	Min float64
+	Max float64
+}
+
+//ErrAlreadyExists is returned when a measurement with the same device and timestamp has already been stored
+var ErrAlreadyExists = errors.New("measurement already exists")
+
+//ErrNotFound is returned when a measurement, or another record, can not be found
+var ErrNotFound = errors.New("not found")
+
+//ErrInvalidTimestamp is returned when a measurement timestamp can not be parsed or is missing
+var ErrInvalidTimestamp = errors.New("invalid timestamp")
+
 var dbCtxKey = &databaseContextKey{"database"}
 
 type databaseContextKey struct {
@@ -53,36 +102,142 @@ func GetFromContext(ctx context.Context) (Datastore, error) {
 }
 
 type myDB struct {
-	impl *gorm.DB
-	log  zerolog.Logger
+	impl   *gorm.DB
+	log    zerolog.Logger
+	ctx    context.Context
+	tenant string
+	hidden []string
+
+	migrationErr error
+}
+
+//WithContext returns a copy of the datastore that makes its queries as part of the trace in ctx
+func (db *myDB) WithContext(ctx context.Context) Datastore {
+	return &myDB{
+		impl:         db.impl.WithContext(ctx),
+		log:          db.log,
+		ctx:          ctx,
+		tenant:       db.tenant,
+		hidden:       db.hidden,
+		migrationErr: db.migrationErr,
+	}
+}
+
+//ForTenant returns a copy of the datastore that only stores and finds the measurements of a tenant.
+//A datastore that has not been scoped to a tenant stores measurements for the default tenant, but
+//finds the measurements of all tenants, which is only meant for maintenance tasks.
+func (db *myDB) ForTenant(tenant string) Datastore {
+	return &myDB{
+		impl:         db.impl,
+		log:          db.log,
+		ctx:          db.ctx,
+		tenant:       tenant,
+		hidden:       db.hidden,
+		migrationErr: db.migrationErr,
+	}
+}
+
+//WithoutDevices returns a copy of the datastore that does not find the measurements of the provided
+//devices, so that devices that a caller may not see are excluded before results are paged
+func (db *myDB) WithoutDevices(devices []string) Datastore {
+	return &myDB{
+		impl:         db.impl,
+		log:          db.log,
+		ctx:          db.ctx,
+		tenant:       db.tenant,
+		hidden:       devices,
+		migrationErr: db.migrationErr,
+	}
+}
+
+//tenantOrDefault returns the tenant that new measurements should be stored for
+func (db *myDB) tenantOrDefault() string {
+	if db.tenant == "" {
+		return models.DefaultTenant
+	}
+	return db.tenant
+}
+
+//scoped restricts a query to the measurements of the tenant of the datastore, if it has one
+func (db *myDB) scoped(impl *gorm.DB) *gorm.DB {
+	if db.tenant == "" {
+		return impl
+	}
+	return impl.Where("tenant = ?", db.tenant)
+}
+
+//visible excludes the measurements of the devices that have been hidden from the datastore
+func (db *myDB) visible(impl *gorm.DB) *gorm.DB {
+	if len(db.hidden) == 0 {
+		return impl
+	}
+	return impl.Where("device NOT IN ?", db.hidden)
 }
 
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
+//Ping checks that the database can be reached
+func (db *myDB) Ping() error {
+	sqlDB, err := db.impl.DB()
+	if err != nil {
+		return err
+	}
+
+	ctx := db.ctx
+	if ctx == nil {
+		ctx = context.Background()
 	}
-	return fallback
+
+	return sqlDB.PingContext(ctx)
+}
+
+//Close closes the connection pool of the database
+func (db *myDB) Close() error {
+	sqlDB, err := db.impl.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
+}
+
+//MigrationStatus returns nil if the schema has been migrated, or the error that the migration failed with
+func (db *myDB) MigrationStatus() error {
+	return db.migrationErr
+}
+
+//startSpan starts a client span for a datastore operation and returns a session that belongs to it
+func (db *myDB) startSpan(operation string, attrs ...attribute.KeyValue) (*gorm.DB, trace.Span) {
+	ctx := db.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	attrs = append(attrs, semconv.DBSystemKey.String(db.impl.Dialector.Name()), semconv.DBOperationKey.String(operation))
+	if db.tenant != "" {
+		attrs = append(attrs, attribute.String("tenant", db.tenant))
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "db."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+
+	return db.impl.WithContext(ctx), span
 }
 
 //ConnectorFunc is used to inject a database connection method into NewDatabaseConnection
 type ConnectorFunc func() (*gorm.DB, zerolog.Logger, error)
 
-//NewPostgreSQLConnector opens a connection to a postgresql database
-func NewPostgreSQLConnector(log zerolog.Logger) ConnectorFunc {
-	dbHost := os.Getenv("TEMPERATURE_DB_HOST")
-	username := os.Getenv("TEMPERATURE_DB_USER")
-	dbName := os.Getenv("TEMPERATURE_DB_NAME")
-	password := os.Getenv("TEMPERATURE_DB_PASSWORD")
-	sslMode := getEnv("TEMPERATURE_DB_SSLMODE", "require")
-
-	dbURI := fmt.Sprintf("host=%s user=%s dbname=%s sslmode=%s password=%s", dbHost, username, dbName, sslMode, password)
+//NewPostgreSQLConnector opens a connection to a postgresql database, retrying according to
+//the connect policy of the configuration if the database is not available yet
+func NewPostgreSQLConnector(log zerolog.Logger, cfg config.Database) ConnectorFunc {
+	dbURI := fmt.Sprintf("host=%s user=%s dbname=%s sslmode=%s password=%s", cfg.Host, cfg.User, cfg.Name, cfg.SSLMode, cfg.Password)
 
 	return func() (*gorm.DB, zerolog.Logger, error) {
-		sublogger := log.With().Str("host", dbHost).Str("database", dbName).Logger()
+		sublogger := log.With().Str("host", cfg.Host).Str("database", cfg.Name).Logger()
+
+		var db *gorm.DB
 
-		for {
+		err := cfg.Connect.Do(context.Background(), sublogger, "connect to the database", func() error {
 			sublogger.Info().Msg("connecting to database host")
-			db, err := gorm.Open(postgres.Open(dbURI), &gorm.Config{
+
+			var err error
+			db, err = gorm.Open(postgres.Open(dbURI), &gorm.Config{
 				Logger: logger.New(
 					&sublogger,
 					logger.Config{
@@ -94,13 +249,22 @@ func NewPostgreSQLConnector(log zerolog.Logger) ConnectorFunc {
 				),
 			})
 
-			if err != nil {
-				sublogger.Fatal().Msg("failed to connect to database")
-				time.Sleep(3 * time.Second)
-			} else {
-				return db, sublogger, nil
-			}
+			return err
+		})
+		if err != nil {
+			return nil, sublogger, err
 		}
+
+		pool, err := db.DB()
+		if err != nil {
+			return nil, sublogger, err
+		}
+
+		pool.SetMaxOpenConns(cfg.MaxOpenConns)
+		pool.SetMaxIdleConns(cfg.MaxIdleConns)
+		pool.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+		return db, sublogger, nil
 	}
 }
 
@@ -126,15 +290,43 @@ func NewDatabaseConnection(connect ConnectorFunc) (Datastore, error) {
 		return nil, err
 	}
 
+	if err := metrics.InstrumentDatastore(impl); err != nil {
+		log.Error().Err(err).Msg("failed to instrument the datastore")
+	}
+
 	db := &myDB{
 		impl: impl.Debug(),
 		log:  log,
 	}
 
-	db.impl.AutoMigrate(&models.Temperature{}, &models.TemperatureV2{})
+	if err := db.impl.AutoMigrate(&models.Temperature{}, &models.TemperatureV2{}, &models.AlertRule{}, &models.DevicePolicy{}, &models.Calibration{}); err != nil {
+		log.Error().Err(err).Msg("failed to migrate the database schema")
+		db.migrationErr = fmt.Errorf("failed to migrate the database schema: %w", err)
+		return db, nil
+	}
+
+	migrateWaterToMedium(db.impl, log, &models.TemperatureV2{}, &models.AlertRule{})
+
+	// The unique index has been replaced by one that also includes the depth of the measurement,
+	// and then by one that also includes the tenant that it was stored for
+	for _, index := range []string{"device_at_time", "device_at_depth_and_time"} {
+		if db.impl.Migrator().HasIndex(&models.TemperatureV2{}, index) {
+			if err := db.impl.Migrator().DropIndex(&models.TemperatureV2{}, index); err != nil {
+				log.Error().Err(err).Str("index", index).Msg("failed to drop an old unique index")
+			}
+		}
+	}
+
+	// Readings that were stored before calibrations were introduced are their own raw readings
+	result := db.impl.Model(&models.TemperatureV2{}).Where("raw_temp IS NULL").Update("raw_temp", gorm.Expr("temp"))
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msg("failed to copy temperatures to raw temperatures")
+	} else if result.RowsAffected > 0 {
+		log.Info().Msgf("copied %d temperatures to raw temperatures", result.RowsAffected)
+	}
 
 	oldtemps := []models.Temperature{}
-	result := db.impl.Order("timestamp2").Limit(100).Find(&oldtemps)
+	result = db.impl.Order("timestamp2").Limit(100).Find(&oldtemps)
 	migrationCount := 0
 	duplicateCount := 0
 
@@ -147,11 +339,16 @@ func NewDatabaseConnection(connect ConnectorFunc) (Datastore, error) {
 				Latitude:  old.Latitude,
 				Longitude: old.Longitude,
 				Device:    old.Device,
-				Temp:      old.Temp,
-				Water:     old.Water,
+				Temp:      float64(old.Temp),
+				RawTemp:   float64(old.Temp),
+				Medium:    models.MediumAir,
 				Timestamp: old.Timestamp2,
 			}
 
+			if old.Water {
+				t.Medium = models.MediumWater
+			}
+
 			r := db.impl.Create(t)
 			if r.Error == nil {
 				migrationCount++
@@ -175,45 +372,153 @@ func NewDatabaseConnection(connect ConnectorFunc) (Datastore, error) {
 	return db, nil
 }
 
-//AddTemperatureMeasurement takes a device, position and a temp and adds a record to the database
-func (db *myDB) AddTemperatureMeasurement(device *string, latitude, longitude, temp float64, water bool, when string) (*models.TemperatureV2, error) {
+//migrateWaterToMedium replaces the water flag of older tables with the medium column
+//that AutoMigrate has added with air as its default value
+func migrateWaterToMedium(impl *gorm.DB, log zerolog.Logger, tables ...interface{}) {
+	for _, table := range tables {
+		migrator := impl.Migrator()
+		if !migrator.HasColumn(table, "water") {
+			continue
+		}
+
+		result := impl.Unscoped().Model(table).Where("water = ?", true).Update("medium", models.MediumWater)
+		if result.Error != nil {
+			log.Error().Err(result.Error).Msg("failed to migrate water flag to medium")
+			continue
+		}
 
-	ts, err := time.Parse(time.RFC3339Nano, when)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse timestamp from %s : (%s)", when, err.Error())
+		if err := migrator.DropColumn(table, "water"); err != nil {
+			log.Error().Err(err).Msg("failed to drop migrated water column")
+			continue
+		}
+
+		log.Info().Msgf("migrated %d rows with a water flag to the medium column", result.RowsAffected)
 	}
+}
+
+//AddTemperatureMeasurement adds a measurement, with the device and position, the temperature and the resolution of
+//its sensor, the depth, medium and time of the measurement and its quality control status, to the database for the
+//tenant of the datastore. The stored measurement is returned with its calibrated temperature.
+func (db *myDB) AddTemperatureMeasurement(m models.TemperatureV2) (*models.TemperatureV2, error) {
+	if m.Timestamp.IsZero() {
+		return nil, fmt.Errorf("%w: measurement has no timestamp", ErrInvalidTimestamp)
+	}
+
+	measurement := &m
+	measurement.ID = 0
+	measurement.Tenant = db.tenantOrDefault()
 
-	measurement := &models.TemperatureV2{
-		Latitude:  latitude,
-		Longitude: longitude,
-		Temp:      float32(temp),
-		Water:     water,
-		Timestamp: ts,
+	if measurement.Quality == "" {
+		measurement.Quality = models.QualityRaw
 	}
 
-	if device != nil {
-		measurement.Device = *device
+	if measurement.Medium == "" {
+		measurement.Medium = models.MediumAir
 	}
 
-	result := db.impl.Create(measurement)
+	impl, span := db.startSpan("AddTemperatureMeasurement", attribute.String("device", measurement.Device), attribute.String("medium", measurement.Medium))
+	defer span.End()
+
+	var err error
+
+	if err = calibrate(impl, measurement); err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	result := impl.Create(measurement)
 	if result.Error != nil {
-		return nil, fmt.Errorf("create failed: %s", result.Error.Error())
+		if isUniqueConstraintViolation(result.Error) {
+			err = fmt.Errorf("%w: %s", ErrAlreadyExists, result.Error.Error())
+		} else {
+			err = fmt.Errorf("create failed: %s", result.Error.Error())
+			tracing.RecordError(span, err)
+		}
+		return nil, err
 	}
 
 	return measurement, nil
 }
 
-func (db *myDB) GetTemperatures(deviceId string, from, to time.Time, geoSpatial string, lat0, lon0, lat1, lon1 float64, resultOffset, resultLimit uint64) ([]models.TemperatureV2, error) {
+//ImportTemperatureMeasurements adds a batch of measurements in a single transaction. The returned slice
+//holds the outcome for each measurement, where measurements that have already been stored are reported
+//as ErrAlreadyExists. If the transaction fails as a whole, nothing is stored and an error is returned.
+func (db *myDB) ImportTemperatureMeasurements(measurements []models.TemperatureV2) ([]error, error) {
+	outcomes := make([]error, len(measurements))
+
+	impl, span := db.startSpan("ImportTemperatureMeasurements", attribute.Int("count", len(measurements)))
+	defer span.End()
+
+	err := impl.Transaction(func(tx *gorm.DB) error {
+		for idx := range measurements {
+			m := &measurements[idx]
+
+			m.Tenant = db.tenantOrDefault()
+
+			if m.Quality == "" {
+				m.Quality = models.QualityRaw
+			}
+
+			if m.Medium == "" {
+				m.Medium = models.MediumAir
+			}
+
+			if err := calibrate(tx, m); err != nil {
+				return err
+			}
+
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(m)
+			if result.Error != nil {
+				return fmt.Errorf("import failed: %s", result.Error.Error())
+			}
+
+			if result.RowsAffected == 0 {
+				m.ID = 0
+				outcomes[idx] = ErrAlreadyExists
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	return outcomes, nil
+}
+
+//GetTemperatures returns the measurements that match the provided filters, of which the empty
+//ones are ignored, ordered by time and depth
+func (db *myDB) GetTemperatures(deviceId string, mediums, quality []string, depth *DepthRange, from, to time.Time, geoSpatial string, lat0, lon0, lat1, lon1 float64, resultOffset, resultLimit uint64) ([]models.TemperatureV2, error) {
 	temps := []models.TemperatureV2{}
-	gorm := db.impl.Order("timestamp")
+
+	impl, span := db.startSpan("GetTemperatures", attribute.String("device", deviceId))
+	defer span.End()
+
+	// Measurements from the same time are ordered by depth, so that they form a profile
+	gorm := db.visible(db.scoped(impl)).Order("timestamp").Order("depth")
 
 	if deviceId != "" {
 		gorm = gorm.Where("device = ?", deviceId)
 	}
 
+	if len(mediums) > 0 {
+		gorm = gorm.Where("medium IN ?", mediums)
+	}
+
+	if len(quality) > 0 {
+		gorm = gorm.Where("quality IN ?", quality)
+	}
+
+	if depth != nil {
+		gorm = gorm.Where("depth >= ? AND depth <= ?", depth.Min, depth.Max)
+	}
+
 	if !from.IsZero() || !to.IsZero() {
 		gorm = insertTemporalSQL(gorm, "timestamp", from, to)
 		if gorm.Error != nil {
+			tracing.RecordError(span, gorm.Error)
 			return nil, gorm.Error
 		}
 	}
@@ -228,12 +533,418 @@ func (db *myDB) GetTemperatures(deviceId string, from, to time.Time, geoSpatial
 
 	result := gorm.Limit(int(resultLimit)).Find(&temps)
 	if result.Error != nil {
+		tracing.RecordError(span, result.Error)
 		return nil, result.Error
 	}
 
 	return temps, nil
 }
 
+//GetDevices returns the devices that measurements have been stored for, in alphabetical order
+func (db *myDB) GetDevices() ([]string, error) {
+	devices := []string{}
+
+	impl, span := db.startSpan("GetDevices")
+	defer span.End()
+
+	result := db.visible(db.scoped(impl)).Model(&models.TemperatureV2{}).Distinct("device").Order("device").Pluck("device", &devices)
+	if result.Error != nil {
+		tracing.RecordError(span, result.Error)
+		return nil, result.Error
+	}
+
+	return devices, nil
+}
+
+func isUniqueConstraintViolation(err error) bool {
+	// sqlite and postgresql report unique constraint violations in different ways
+	msg := err.Error()
+	return strings.Contains(msg, "UNIQUE constraint failed") ||
+		strings.Contains(msg, "duplicate key value violates unique constraint")
+}
+
+//UpdateTemperatureQuality sets the quality control status of a stored measurement and, optionally, corrects its value
+func (db *myDB) UpdateTemperatureQuality(id uint, quality, qualityReason string, correctedTemp *float64) (*models.TemperatureV2, error) {
+	measurement := &models.TemperatureV2{}
+
+	impl, span := db.startSpan("UpdateTemperatureQuality", attribute.Int64("id", int64(id)))
+	defer span.End()
+
+	result := db.scoped(impl).First(measurement, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		tracing.RecordError(span, result.Error)
+		return nil, result.Error
+	}
+
+	measurement.Quality = quality
+	measurement.QualityReason = qualityReason
+
+	if correctedTemp != nil {
+		measurement.Temp = *correctedTemp
+	}
+
+	result = impl.Save(measurement)
+	if result.Error != nil {
+		err := fmt.Errorf("update failed: %s", result.Error.Error())
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	return measurement, nil
+}
+
+//DeleteTemperaturesBefore deletes all measurements that were made before the provided time
+//and returns the number of deleted measurements. The measurements are deleted for good, rather
+//than soft deleted, so that their storage is freed and they can be imported again.
+func (db *myDB) DeleteTemperaturesBefore(before time.Time) (int64, error) {
+	impl, span := db.startSpan("DeleteTemperaturesBefore")
+	defer span.End()
+
+	result := db.scoped(impl).Unscoped().Where("timestamp < ?", before).Delete(&models.TemperatureV2{})
+	if result.Error != nil {
+		err := fmt.Errorf("delete failed: %s", result.Error.Error())
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+
+	return result.RowsAffected, nil
+}
+
+//CreateAlertRule adds a new alert rule for the tenant of the datastore
+func (db *myDB) CreateAlertRule(rule *models.AlertRule) (*models.AlertRule, error) {
+	rule.ID = 0
+	rule.Tenant = db.tenantOrDefault()
+
+	result := db.impl.Create(rule)
+	if result.Error != nil {
+		return nil, fmt.Errorf("create failed: %s", result.Error.Error())
+	}
+
+	return rule, nil
+}
+
+//GetAlertRules returns the alert rules of the tenant of the datastore, or the rules of all
+//tenants if the datastore has not been scoped to a tenant
+func (db *myDB) GetAlertRules() ([]models.AlertRule, error) {
+	rules := []models.AlertRule{}
+
+	result := db.scoped(db.impl).Order("id").Find(&rules)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return rules, nil
+}
+
+//UpdateAlertRule replaces the settings of an existing alert rule
+func (db *myDB) UpdateAlertRule(id uint, rule *models.AlertRule) (*models.AlertRule, error) {
+	existing := &models.AlertRule{}
+
+	result := db.scoped(db.impl).First(existing, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, result.Error
+	}
+
+	rule.Model = existing.Model
+	rule.Tenant = existing.Tenant
+
+	result = db.impl.Save(rule)
+	if result.Error != nil {
+		return nil, fmt.Errorf("update failed: %s", result.Error.Error())
+	}
+
+	return rule, nil
+}
+
+//DeleteAlertRule removes an alert rule
+func (db *myDB) DeleteAlertRule(id uint) error {
+	result := db.scoped(db.impl).Delete(&models.AlertRule{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//GetDevicePolicies returns the device policies of the tenant of the datastore
+func (db *myDB) GetDevicePolicies() ([]models.DevicePolicy, error) {
+	policies := []models.DevicePolicy{}
+
+	result := db.scoped(db.impl).Order("device").Find(&policies)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return policies, nil
+}
+
+//SetDevicePolicy creates or replaces the policy of a device
+func (db *myDB) SetDevicePolicy(device, visibility string) (*models.DevicePolicy, error) {
+	policy := &models.DevicePolicy{}
+
+	result := db.impl.Where("tenant = ? AND device = ?", db.tenantOrDefault(), device).First(policy)
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, result.Error
+	}
+
+	policy.Tenant = db.tenantOrDefault()
+	policy.Device = device
+	policy.Visibility = visibility
+
+	result = db.impl.Save(policy)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to save device policy: %s", result.Error.Error())
+	}
+
+	return policy, nil
+}
+
+//DeleteDevicePolicy removes the policy of a device, which makes the device public
+func (db *myDB) DeleteDevicePolicy(device string) error {
+	// Policies are deleted for real, so that the device can be given a new policy later on
+	result := db.impl.Unscoped().Where("tenant = ? AND device = ?", db.tenantOrDefault(), device).Delete(&models.DevicePolicy{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//calibrate corrects the temperature of a new measurement with the calibration of its device that
+//is valid at the time of the measurement, and keeps the reported temperature as the raw temperature
+func calibrate(impl *gorm.DB, m *models.TemperatureV2) error {
+	m.RawTemp = m.Temp
+
+	calibrations, err := deviceCalibrations(impl, m.Tenant, m.Device)
+	if err != nil {
+		return err
+	}
+
+	m.Temp, m.CalibrationID = corrected(m.RawTemp, calibrationAt(calibrations, m.Timestamp))
+
+	return nil
+}
+
+//deviceCalibrations returns the calibrations of a device, with the one that started most recently first
+func deviceCalibrations(impl *gorm.DB, tenant, device string) ([]models.Calibration, error) {
+	calibrations := []models.Calibration{}
+
+	result := impl.Where("tenant = ? AND device = ?", tenant, device).
+		Order("valid_from DESC").Order("id DESC").Find(&calibrations)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to look up calibrations: %s", result.Error.Error())
+	}
+
+	return calibrations, nil
+}
+
+//calibrationAt returns the calibration that is valid at a point in time, or nil if there is none.
+//The calibration that started most recently wins if there are several valid ones.
+func calibrationAt(calibrations []models.Calibration, at time.Time) *models.Calibration {
+	for i := range calibrations {
+		c := &calibrations[i]
+		if !c.ValidFrom.After(at) && (c.ValidTo == nil || c.ValidTo.After(at)) {
+			return c
+		}
+	}
+
+	return nil
+}
+
+//corrected returns the temperature and calibration id of a reading that is corrected by a calibration,
+//or the raw temperature if the reading is not covered by any calibration
+func corrected(raw float64, calibration *models.Calibration) (float64, uint) {
+	if calibration == nil {
+		return raw, 0
+	}
+
+	return calibration.Correct(raw), calibration.ID
+}
+
+//recalibrate corrects the readings of a device that the query finds in the same way as calibrate
+//corrects new readings, and returns the number of readings that changed. Readings that have been
+//corrected manually are left as they are.
+func recalibrate(tx *gorm.DB, tenant, device string, query *gorm.DB) (int64, error) {
+	calibrations, err := deviceCalibrations(tx, tenant, device)
+	if err != nil {
+		return 0, err
+	}
+
+	var changed int64
+	readings := []models.TemperatureV2{}
+
+	result := query.Where("tenant = ? AND device = ? AND quality <> ?", tenant, device, models.QualityManuallyCorrected).
+		FindInBatches(&readings, 500, func(batch *gorm.DB, _ int) error {
+			for _, r := range readings {
+				temp, calibrationID := corrected(r.RawTemp, calibrationAt(calibrations, r.Timestamp))
+				if temp == r.Temp && calibrationID == r.CalibrationID {
+					continue
+				}
+
+				err := tx.Model(&models.TemperatureV2{}).Where("id = ?", r.ID).
+					Updates(map[string]interface{}{"temp": temp, "calibration_id": calibrationID}).Error
+				if err != nil {
+					return err
+				}
+
+				changed++
+			}
+
+			return nil
+		})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return changed, nil
+}
+
+//CreateCalibration adds a new calibration for the tenant of the datastore. It only applies to new
+//readings until it is applied to historical readings with ApplyCalibration.
+func (db *myDB) CreateCalibration(calibration *models.Calibration) (*models.Calibration, error) {
+	calibration.ID = 0
+	calibration.Tenant = db.tenantOrDefault()
+
+	result := db.impl.Create(calibration)
+	if result.Error != nil {
+		return nil, fmt.Errorf("create failed: %s", result.Error.Error())
+	}
+
+	return calibration, nil
+}
+
+//GetCalibrations returns the calibrations of a device, or of all devices if device is empty
+func (db *myDB) GetCalibrations(device string) ([]models.Calibration, error) {
+	calibrations := []models.Calibration{}
+
+	query := db.scoped(db.impl).Order("device").Order("valid_from")
+	if device != "" {
+		query = query.Where("device = ?", device)
+	}
+
+	result := query.Find(&calibrations)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return calibrations, nil
+}
+
+func (db *myDB) findCalibration(impl *gorm.DB, id uint) (*models.Calibration, error) {
+	calibration := &models.Calibration{}
+
+	result := db.scoped(impl).First(calibration, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, result.Error
+	}
+
+	return calibration, nil
+}
+
+//UpdateCalibration replaces the settings of an existing calibration. Like a new calibration, the
+//new settings only apply to new readings until they are applied with ApplyCalibration.
+func (db *myDB) UpdateCalibration(id uint, calibration *models.Calibration) (*models.Calibration, error) {
+	existing, err := db.findCalibration(db.impl, id)
+	if err != nil {
+		return nil, err
+	}
+
+	calibration.Model = existing.Model
+	calibration.Tenant = existing.Tenant
+
+	result := db.impl.Save(calibration)
+	if result.Error != nil {
+		return nil, fmt.Errorf("update failed: %s", result.Error.Error())
+	}
+
+	return calibration, nil
+}
+
+//DeleteCalibration removes a calibration and corrects the readings that were corrected by it with
+//the calibration that is valid for them without it, or restores their raw temperatures if there is none
+func (db *myDB) DeleteCalibration(id uint) error {
+	impl, span := db.startSpan("DeleteCalibration", attribute.Int64("id", int64(id)))
+	defer span.End()
+
+	err := impl.Transaction(func(tx *gorm.DB) error {
+		calibration, err := db.findCalibration(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err = tx.Delete(calibration).Error; err != nil {
+			return err
+		}
+
+		_, err = recalibrate(tx, calibration.Tenant, calibration.Device, tx.Where("calibration_id = ?", id))
+		if err != nil {
+			return fmt.Errorf("failed to recalibrate readings: %s", err.Error())
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		tracing.RecordError(span, err)
+	}
+
+	return err
+}
+
+//ApplyCalibration corrects the historical readings within the validity period of a calibration and
+//returns the number of readings that changed. Each reading is corrected with the calibration that is
+//valid for it, so a calibration that started later keeps the readings that it covers. Readings that
+//have been corrected manually are left as they are.
+func (db *myDB) ApplyCalibration(id uint) (int64, error) {
+	impl, span := db.startSpan("ApplyCalibration", attribute.Int64("id", int64(id)))
+	defer span.End()
+
+	var changed int64
+
+	err := impl.Transaction(func(tx *gorm.DB) error {
+		calibration, err := db.findCalibration(tx, id)
+		if err != nil {
+			return err
+		}
+
+		query := tx.Where("timestamp >= ?", calibration.ValidFrom)
+		if calibration.ValidTo != nil {
+			query = query.Where("timestamp < ?", *calibration.ValidTo)
+		}
+
+		changed, err = recalibrate(tx, calibration.Tenant, calibration.Device, query)
+		if err != nil {
+			return fmt.Errorf("failed to apply calibration: %s", err.Error())
+		}
+
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			tracing.RecordError(span, err)
+		}
+		return 0, err
+	}
+
+	return changed, nil
+}
+
 func insertTemporalSQL(gorm *gorm.DB, property string, from, to time.Time) *gorm.DB {
 	if !from.IsZero() {
 		gorm = gorm.Where(fmt.Sprintf("%s >= ?", property), from)
@@ -1,15 +1,19 @@
 package database_test
 
 import (
+	"errors"
 	"math"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/matryer/is"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 
 	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/database"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
 	"github.com/diwise/ngsi-ld-golang/pkg/ngsi-ld"
 )
 
@@ -25,11 +29,86 @@ func TestThatAddTemperatureHandlesDuplicates(t *testing.T) {
 	now := time.Now().UTC()
 	deviceName := "mydevice"
 
-	_, err := db.AddTemperatureMeasurement(&deviceName, 64.278, 17.182, 12.7, true, now.Format(time.RFC3339))
+	_, err := db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 12.7, Medium: models.MediumWater, Timestamp: now})
 	is.NoErr(err) // no error expected
 
-	_, err = db.AddTemperatureMeasurement(&deviceName, 64.278, 17.182, 12.7, true, now.Format(time.RFC3339))
-	is.True(err != nil) // second add should return an error
+	_, err = db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 12.7, Medium: models.MediumWater, Timestamp: now})
+	is.True(err != nil)                                // second add should return an error
+	is.True(errors.Is(err, database.ErrAlreadyExists)) // error should be ErrAlreadyExists
+}
+
+func TestThatImportTemperatureMeasurementsReportsDuplicatesPerItem(t *testing.T) {
+	is := is.New(t)
+	log := log.Logger
+	db, _ := database.NewDatabaseConnection(database.NewSQLiteConnector(log))
+
+	now := time.Now().UTC().Truncate(time.Second)
+	deviceName := "mydevice"
+
+	_, err := db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 12.7, Medium: models.MediumWater, Timestamp: now})
+	is.NoErr(err)
+
+	outcomes, err := db.ImportTemperatureMeasurements([]models.TemperatureV2{
+		{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 12.1, Medium: models.MediumWater, Timestamp: now.Add(-time.Hour)},
+		{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 12.7, Medium: models.MediumWater, Timestamp: now},
+	})
+	is.NoErr(err)                                              // the import should succeed
+	is.Equal(len(outcomes), 2)                                 // with one outcome per measurement
+	is.NoErr(outcomes[0])                                      // the new measurement should be stored
+	is.True(errors.Is(outcomes[1], database.ErrAlreadyExists)) // and the existing one reported as a duplicate
+
+	temps, _ := db.GetTemperatures(deviceName, nil, nil, nil, time.Time{}, time.Time{}, "", 0.0, 0.0, 0.0, 0.0, 0, 10)
+	is.Equal(len(temps), 2) // both measurements should be in the database
+}
+
+func TestThatTemperaturesAreIsolatedPerTenant(t *testing.T) {
+	is := is.New(t)
+	log := log.Logger
+	db, _ := database.NewDatabaseConnection(database.NewSQLiteConnector(log))
+
+	now := time.Now().UTC().Truncate(time.Second)
+	deviceName := "mydevice"
+
+	_, err := db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 12.7, Medium: models.MediumWater, Timestamp: now})
+	is.NoErr(err)
+
+	m, err := db.ForTenant("sundsvall").AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 8.1, Medium: models.MediumWater, Timestamp: now})
+	is.NoErr(err) // the same reading should not be a duplicate in another tenant
+	is.Equal(m.Tenant, "sundsvall")
+
+	temps, _ := db.ForTenant("sundsvall").GetTemperatures(deviceName, nil, nil, nil, time.Time{}, time.Time{}, "", 0.0, 0.0, 0.0, 0.0, 0, 10)
+	is.Equal(len(temps), 1) // a tenant should only find its own readings
+	is.Equal(temps[0].Temp, 8.1)
+
+	temps, _ = db.ForTenant(models.DefaultTenant).GetTemperatures(deviceName, nil, nil, nil, time.Time{}, time.Time{}, "", 0.0, 0.0, 0.0, 0.0, 0, 10)
+	is.Equal(len(temps), 1)
+	is.Equal(temps[0].Temp, 12.7)
+
+	temps, _ = db.GetTemperatures(deviceName, nil, nil, nil, time.Time{}, time.Time{}, "", 0.0, 0.0, 0.0, 0.0, 0, 10)
+	is.Equal(len(temps), 2) // while an unscoped datastore should find the readings of all tenants
+}
+
+func TestThatHiddenDevicesAreExcludedBeforeResultsArePaged(t *testing.T) {
+	is := is.New(t)
+	db, _ := database.NewDatabaseConnection(database.NewSQLiteConnector(log.Logger))
+
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, device := range []string{"school-17", "school-17", "lake-1", "lake-2"} {
+		_, err := db.AddTemperatureMeasurement(models.TemperatureV2{Device: device, Latitude: 64.278, Longitude: 17.182, Temp: 12.7, Medium: models.MediumWater, Timestamp: start.Add(time.Duration(i) * time.Minute)})
+		is.NoErr(err)
+	}
+
+	devices, err := db.GetDevices()
+	is.NoErr(err)
+	is.Equal(devices, []string{"lake-1", "lake-2", "school-17"}) // each device should be listed once
+
+	temps, _ := db.WithoutDevices([]string{"school-17"}).GetTemperatures("", nil, nil, nil, time.Time{}, time.Time{}, "", 0.0, 0.0, 0.0, 0.0, 0, 2)
+	is.Equal(len(temps), 2) // the hidden readings should not take up any of the page
+	is.Equal(temps[0].Device, "lake-1")
+	is.Equal(temps[1].Device, "lake-2")
+
+	devices, _ = db.ForTenant(models.DefaultTenant).WithoutDevices([]string{"school-17"}).GetDevices()
+	is.Equal(devices, []string{"lake-1", "lake-2"}) // nor be listed as devices
 }
 
 func TestThatGetTemperaturesWorksWithDeviceIDAndTimeSpan(t *testing.T) {
@@ -41,9 +120,9 @@ func TestThatGetTemperaturesWorksWithDeviceIDAndTimeSpan(t *testing.T) {
 	time3 := time.Now().UTC().Add(3 * time.Hour)
 
 	deviceName := "mydevice"
-	db.AddTemperatureMeasurement(&deviceName, 64.278, 17.182, 12.7, true, time2.Format(time.RFC3339))
+	db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 12.7, Medium: models.MediumWater, Timestamp: time2})
 
-	temps, _ := db.GetTemperatures(deviceName, time1, time3, "", 0.0, 0.0, 0.0, 0.0, 0, 1)
+	temps, _ := db.GetTemperatures(deviceName, nil, nil, nil, time1, time3, "", 0.0, 0.0, 0.0, 0.0, 0, 1)
 	if len(temps) != 1 {
 		t.Errorf("number of returned temperatures differ from expectation. %d != %d", len(temps), 1)
 	}
@@ -58,13 +137,13 @@ func TestThatGetTemperaturesWorksWithTimeSpanAndNearPoint(t *testing.T) {
 	time3 := time.Now().UTC().Add(3 * time.Hour)
 
 	deviceName := "mydevice"
-	db.AddTemperatureMeasurement(&deviceName, 64.278, 17.182, 12.7, true, time2.Format(time.RFC3339))
+	db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 12.7, Medium: models.MediumWater, Timestamp: time2})
 
 	lat, lon := 64.2775, 17.1815
 
 	nw_lat, nw_lon, se_lat, se_lon := getApproximatePoint(lat, lon, 1000)
 
-	temps, _ := db.GetTemperatures("", time1, time3, ngsi.GeoSpatialRelationNearPoint, nw_lat, nw_lon, se_lat, se_lon, 0, 1)
+	temps, _ := db.GetTemperatures("", nil, nil, nil, time1, time3, ngsi.GeoSpatialRelationNearPoint, nw_lat, nw_lon, se_lat, se_lon, 0, 1)
 	if len(temps) != 1 {
 		t.Errorf("number of returned temperatures differ from expectation. %d != %d", len(temps), 1)
 	}
@@ -79,14 +158,306 @@ func TestThatGetTemperaturesWorksWithTimeSpanAndWithinRectangle(t *testing.T) {
 	time3 := time.Now().UTC().Add(3 * time.Hour)
 
 	deviceName := "mydevice"
-	db.AddTemperatureMeasurement(&deviceName, 63.278, 17.185, 12.7, true, time2.Format(time.RFC3339))
+	db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 63.278, Longitude: 17.185, Temp: 12.7, Medium: models.MediumWater, Timestamp: time2})
 
-	temps, _ := db.GetTemperatures("", time1, time3, ngsi.GeoSpatialRelationWithinRect, 64.2775, 17.1815, 62.4354, 17.4748, 0, 1)
+	temps, _ := db.GetTemperatures("", nil, nil, nil, time1, time3, ngsi.GeoSpatialRelationWithinRect, 64.2775, 17.1815, 62.4354, 17.4748, 0, 1)
 	if len(temps) != 1 {
 		t.Errorf("number of returned temperatures differ from expectation. %d != %d", len(temps), 1)
 	}
 }
 
+func TestThatGetTemperaturesCanFilterOnQuality(t *testing.T) {
+	is := is.New(t)
+	log := log.Logger
+	db, _ := database.NewDatabaseConnection(database.NewSQLiteConnector(log))
+
+	time1 := time.Now().UTC()
+	time2 := time.Now().UTC().Add(2 * time.Hour)
+	time3 := time.Now().UTC().Add(3 * time.Hour)
+
+	deviceName := "mydevice"
+	db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 12.7, Medium: models.MediumWater, Timestamp: time2, Quality: models.QualityGood})
+	db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 31.7, Medium: models.MediumWater, Timestamp: time2.Add(time.Minute), Quality: models.QualitySuspect, QualityReason: "too warm"})
+
+	temps, err := db.GetTemperatures(deviceName, nil, []string{models.QualityGood}, nil, time1, time3, "", 0.0, 0.0, 0.0, 0.0, 0, 10)
+	is.NoErr(err)
+	is.Equal(len(temps), 1)                        // only the good reading should be returned
+	is.Equal(temps[0].Quality, models.QualityGood) // returned reading should be good
+
+	temps, _ = db.GetTemperatures(deviceName, nil, nil, nil, time1, time3, "", 0.0, 0.0, 0.0, 0.0, 0, 10)
+	is.Equal(len(temps), 2) // both readings should be returned without a filter
+}
+
+func TestThatGetTemperaturesCanFilterOnDepth(t *testing.T) {
+	is := is.New(t)
+	log := log.Logger
+	db, _ := database.NewDatabaseConnection(database.NewSQLiteConnector(log))
+
+	now := time.Now().UTC().Truncate(time.Second)
+	deviceName := "buoy"
+
+	for _, depth := range []float64{0.0, 2.5, 5.0, 10.0} {
+		_, err := db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 12.7 - depth/2, Depth: depth, Medium: models.MediumWater, Timestamp: now})
+		is.NoErr(err) // measurements at different depths should not collide
+	}
+
+	temps, err := db.GetTemperatures(deviceName, nil, nil, &database.DepthRange{Min: 2.0, Max: 5.0}, time.Time{}, time.Time{}, "", 0.0, 0.0, 0.0, 0.0, 0, 10)
+	is.NoErr(err)
+	is.Equal(len(temps), 2)       // only measurements within the depth range should be returned
+	is.Equal(temps[0].Depth, 2.5) // ordered by depth
+	is.Equal(temps[1].Depth, 5.0)
+}
+
+func TestThatUpdateTemperatureQualityCorrectsValue(t *testing.T) {
+	is := is.New(t)
+	log := log.Logger
+	db, _ := database.NewDatabaseConnection(database.NewSQLiteConnector(log))
+
+	deviceName := "mydevice"
+	m, _ := db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 31.7, Medium: models.MediumWater, Timestamp: time.Now().UTC(), Quality: models.QualitySuspect, QualityReason: "too warm"})
+
+	corrected := 13.7
+	m, err := db.UpdateTemperatureQuality(m.ID, models.QualityManuallyCorrected, "checked against reference", &corrected)
+	is.NoErr(err)
+	is.Equal(m.Quality, models.QualityManuallyCorrected) // quality should be updated
+	is.Equal(m.Temp, 13.7)                               // value should be corrected
+
+	_, err = db.UpdateTemperatureQuality(4711, models.QualityBad, "", nil)
+	is.True(errors.Is(err, database.ErrNotFound)) // updating an unknown measurement should fail
+}
+
+func TestThatCalibrationsCorrectNewReadings(t *testing.T) {
+	is := is.New(t)
+	db, _ := database.NewDatabaseConnection(database.NewSQLiteConnector(log.Logger))
+
+	now := time.Now().UTC().Truncate(time.Second)
+	deviceName := "mydevice"
+
+	_, err := db.CreateCalibration(&models.Calibration{Device: deviceName, Offset: -0.5, Gain: 1.0, ValidFrom: now.Add(-time.Hour)})
+	is.NoErr(err)
+
+	m, err := db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 12.7, Medium: models.MediumWater, Timestamp: now})
+	is.NoErr(err)
+	is.Equal(m.Temp, 12.2)    // the stored temperature should be corrected
+	is.Equal(m.RawTemp, 12.7) // and the reported temperature kept as the raw temperature
+
+	m, err = db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 12.7, Medium: models.MediumWater, Timestamp: now.Add(-2 * time.Hour)})
+	is.NoErr(err)
+	is.Equal(m.Temp, 12.7) // readings from before the calibration should not be corrected
+}
+
+func TestThatCalibrationsCanBeAppliedRetroactivelyAndRemoved(t *testing.T) {
+	is := is.New(t)
+	db, _ := database.NewDatabaseConnection(database.NewSQLiteConnector(log.Logger))
+
+	now := time.Now().UTC().Truncate(time.Second)
+	deviceName := "mydevice"
+
+	db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 10.0, Medium: models.MediumWater, Timestamp: now.Add(-48 * time.Hour)})
+	db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 12.0, Medium: models.MediumWater, Timestamp: now.Add(-time.Hour)})
+
+	validTo := now.Add(-24 * time.Hour)
+	c, err := db.CreateCalibration(&models.Calibration{Device: deviceName, Offset: 1.0, Gain: 2.0, ValidFrom: now.Add(-72 * time.Hour), ValidTo: &validTo})
+	is.NoErr(err)
+
+	updated, err := db.ApplyCalibration(c.ID)
+	is.NoErr(err)
+	is.Equal(updated, int64(1)) // only the reading within the validity period should be corrected
+
+	temps, _ := db.GetTemperatures(deviceName, nil, nil, nil, now.Add(-72*time.Hour), now, "", 0.0, 0.0, 0.0, 0.0, 0, 10)
+	is.Equal(len(temps), 2)
+	is.Equal(temps[0].Temp, 21.0)    // the historical reading should be corrected
+	is.Equal(temps[0].RawTemp, 10.0) // from its raw temperature
+	is.Equal(temps[1].Temp, 12.0)    // while the recent one is left as it was
+
+	_, err = db.ForTenant("sundsvall").ApplyCalibration(c.ID)
+	is.True(errors.Is(err, database.ErrNotFound)) // calibrations should be kept per tenant
+
+	is.NoErr(db.DeleteCalibration(c.ID))
+
+	temps, _ = db.GetTemperatures(deviceName, nil, nil, nil, now.Add(-72*time.Hour), now, "", 0.0, 0.0, 0.0, 0.0, 0, 10)
+	is.Equal(temps[0].Temp, 10.0) // removing the calibration should restore the raw temperature
+
+	calibrations, _ := db.GetCalibrations(deviceName)
+	is.Equal(len(calibrations), 0)
+}
+
+func TestThatTheMostRecentCalibrationWinsWhenCalibrationsOverlap(t *testing.T) {
+	is := is.New(t)
+	db, _ := database.NewDatabaseConnection(database.NewSQLiteConnector(log.Logger))
+
+	now := time.Now().UTC().Truncate(time.Second)
+	deviceName := "mydevice"
+
+	db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 10.0, Medium: models.MediumWater, Timestamp: now.Add(-48 * time.Hour)})
+	db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 12.0, Medium: models.MediumWater, Timestamp: now.Add(-time.Hour)})
+
+	older, _ := db.CreateCalibration(&models.Calibration{Device: deviceName, Offset: 1.0, Gain: 1.0, ValidFrom: now.Add(-72 * time.Hour)})
+	newer, _ := db.CreateCalibration(&models.Calibration{Device: deviceName, Offset: -1.0, Gain: 1.0, ValidFrom: now.Add(-24 * time.Hour)})
+
+	updated, err := db.ApplyCalibration(newer.ID)
+	is.NoErr(err)
+	is.Equal(updated, int64(1)) // the newer calibration should correct the recent reading
+
+	updated, err = db.ApplyCalibration(older.ID)
+	is.NoErr(err)
+	is.Equal(updated, int64(1)) // while the older one should only correct the reading that the newer one does not cover
+
+	temps, _ := db.GetTemperatures(deviceName, nil, nil, nil, now.Add(-72*time.Hour), now, "", 0.0, 0.0, 0.0, 0.0, 0, 10)
+	is.Equal(temps[0].Temp, 11.0) // the historical reading should be corrected by the older calibration
+	is.Equal(temps[1].Temp, 11.0) // and the recent reading should keep the newer one
+
+	is.NoErr(db.DeleteCalibration(newer.ID))
+
+	temps, _ = db.GetTemperatures(deviceName, nil, nil, nil, now.Add(-72*time.Hour), now, "", 0.0, 0.0, 0.0, 0.0, 0, 10)
+	is.Equal(temps[1].Temp, 13.0)              // removing the newer calibration should fall back to the older one
+	is.Equal(temps[1].CalibrationID, older.ID) // which then corrects the reading
+	is.Equal(temps[0].CalibrationID, older.ID) // while the reading that it already corrected is left as it was
+}
+
+//legacyTemperature is the layout of the temperatures table before the medium column was introduced
+type legacyTemperature struct {
+	gorm.Model
+	Device    string
+	Temp      float64
+	Water     bool
+	Timestamp time.Time
+}
+
+func (legacyTemperature) TableName() string {
+	return "temperature_v2"
+}
+
+func TestThatTemperaturesCanBeFilteredByMediumBeforeTheyArePaged(t *testing.T) {
+	is := is.New(t)
+	db, _ := database.NewDatabaseConnection(database.NewSQLiteConnector(log.Logger))
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	db.AddTemperatureMeasurement(models.TemperatureV2{Device: "street", Latitude: 64.278, Longitude: 17.182, Temp: 8.2, Medium: models.MediumAir, Timestamp: now.Add(-time.Hour)})
+	db.AddTemperatureMeasurement(models.TemperatureV2{Device: "lake", Latitude: 64.278, Longitude: 17.182, Temp: 12.7, Medium: models.MediumWater, Timestamp: now})
+
+	temps, err := db.GetTemperatures("", []string{models.MediumWater}, nil, nil, time.Time{}, time.Time{}, "", 0.0, 0.0, 0.0, 0.0, 0, 1)
+	is.NoErr(err)
+	is.Equal(len(temps), 1) // the page should not be taken up by readings of other mediums
+	is.Equal(temps[0].Device, "lake")
+}
+
+func TestThatDeleteTemperaturesBeforeOnlyDeletesOldMeasurements(t *testing.T) {
+	is := is.New(t)
+	db, _ := database.NewDatabaseConnection(database.NewSQLiteConnector(log.Logger))
+
+	now := time.Now().UTC().Truncate(time.Second)
+	deviceName := "mydevice"
+
+	db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 12.1, Medium: models.MediumWater, Timestamp: now.Add(-48 * time.Hour)})
+	db.AddTemperatureMeasurement(models.TemperatureV2{Device: deviceName, Latitude: 64.278, Longitude: 17.182, Temp: 12.7, Medium: models.MediumWater, Timestamp: now})
+
+	deleted, err := db.DeleteTemperaturesBefore(now.Add(-24 * time.Hour))
+	is.NoErr(err)
+	is.Equal(deleted, int64(1)) // only the old measurement should be deleted
+
+	temps, _ := db.GetTemperatures(deviceName, nil, nil, nil, time.Time{}, time.Time{}, "", 0.0, 0.0, 0.0, 0.0, 0, 10)
+	is.Equal(len(temps), 1)
+}
+
+func TestThatDeletedMeasurementsAreGoneForGood(t *testing.T) {
+	is := is.New(t)
+	db, _ := database.NewDatabaseConnection(database.NewSQLiteConnector(log.Logger))
+
+	old := models.TemperatureV2{Device: "mydevice", Latitude: 64.278, Longitude: 17.182, Temp: 12.1, Medium: models.MediumWater, Timestamp: time.Now().UTC().Add(-48 * time.Hour)}
+
+	_, err := db.AddTemperatureMeasurement(old)
+	is.NoErr(err)
+
+	deleted, err := db.DeleteTemperaturesBefore(time.Now().UTC().Add(-24 * time.Hour))
+	is.NoErr(err)
+	is.Equal(deleted, int64(1))
+
+	_, err = db.AddTemperatureMeasurement(old)
+	is.NoErr(err) // a deleted measurement should not count as a duplicate when it is stored again
+
+	outcomes, err := db.ImportTemperatureMeasurements([]models.TemperatureV2{old})
+	is.NoErr(err)
+	is.True(errors.Is(outcomes[0], database.ErrAlreadyExists)) // while the one that was stored again should
+}
+
+func TestThatDevicePoliciesCanBeReplacedAndDeleted(t *testing.T) {
+	is := is.New(t)
+	db, _ := database.NewDatabaseConnection(database.NewSQLiteConnector(log.Logger))
+
+	_, err := db.SetDevicePolicy("school-17", models.VisibilityRestricted)
+	is.NoErr(err)
+	_, err = db.SetDevicePolicy("school-17", models.VisibilityPublic)
+	is.NoErr(err) // setting the policy of a device again should replace it
+
+	_, err = db.ForTenant("sundsvall").SetDevicePolicy("school-17", models.VisibilityRestricted)
+	is.NoErr(err)
+
+	policies, _ := db.ForTenant(models.DefaultTenant).GetDevicePolicies()
+	is.Equal(len(policies), 1) // policies should be kept per tenant
+	is.Equal(policies[0].Visibility, models.VisibilityPublic)
+
+	is.NoErr(db.DeleteDevicePolicy("school-17"))
+	is.True(errors.Is(db.DeleteDevicePolicy("school-17"), database.ErrNotFound)) // a deleted policy should be gone
+
+	_, err = db.SetDevicePolicy("school-17", models.VisibilityRestricted)
+	is.NoErr(err) // and the device should be possible to give a new policy
+}
+
+func TestThatAlertRulesAreIsolatedPerTenant(t *testing.T) {
+	is := is.New(t)
+	db, _ := database.NewDatabaseConnection(database.NewSQLiteConnector(log.Logger))
+
+	rule, err := db.ForTenant("sundsvall").CreateAlertRule(&models.AlertRule{Name: "beach", Medium: models.MediumWater})
+	is.NoErr(err)
+	is.Equal(rule.Tenant, "sundsvall") // the rule should belong to the tenant that created it
+
+	rules, _ := db.ForTenant("timra").GetAlertRules()
+	is.Equal(len(rules), 0) // another tenant should not find the rule
+
+	_, err = db.ForTenant("timra").UpdateAlertRule(rule.ID, &models.AlertRule{Name: "hijacked"})
+	is.True(errors.Is(err, database.ErrNotFound)) // nor be able to change it
+
+	err = db.ForTenant("timra").DeleteAlertRule(rule.ID)
+	is.True(errors.Is(err, database.ErrNotFound)) // or delete it
+
+	rule, err = db.ForTenant("sundsvall").UpdateAlertRule(rule.ID, &models.AlertRule{Name: "harbour"})
+	is.NoErr(err)
+	is.Equal(rule.Tenant, "sundsvall") // an updated rule should stay with its tenant
+
+	rules, _ = db.GetAlertRules()
+	is.Equal(len(rules), 1) // while an unscoped datastore should find the rules of all tenants
+	is.Equal(rules[0].Name, "harbour")
+}
+
+func TestThatWaterFlagIsMigratedToMedium(t *testing.T) {
+	is := is.New(t)
+	now := time.Now().UTC()
+
+	connector := func() (*gorm.DB, zerolog.Logger, error) {
+		impl, logger, err := database.NewSQLiteConnector(log.Logger)()
+		if err == nil {
+			impl.AutoMigrate(&legacyTemperature{})
+			impl.Create(&legacyTemperature{Device: "lake", Temp: 12.7, Water: true, Timestamp: now})
+			impl.Create(&legacyTemperature{Device: "street", Temp: 8.2, Water: false, Timestamp: now})
+		}
+		return impl, logger, err
+	}
+
+	db, err := database.NewDatabaseConnection(connector)
+	is.NoErr(err)
+
+	temps, _ := db.GetTemperatures("lake", nil, nil, nil, time.Time{}, time.Time{}, "", 0.0, 0.0, 0.0, 0.0, 0, 1)
+	is.Equal(len(temps), 1)
+	is.Equal(temps[0].Medium, models.MediumWater) // water readings should be migrated to the water medium
+	is.Equal(temps[0].RawTemp, 12.7)              // and existing readings should be their own raw readings
+
+	temps, _ = db.GetTemperatures("street", nil, nil, nil, time.Time{}, time.Time{}, "", 0.0, 0.0, 0.0, 0.0, 0, 1)
+	is.Equal(len(temps), 1)
+	is.Equal(temps[0].Medium, models.MediumAir) // other readings should be migrated to the air medium
+}
+
 func getApproximatePoint(latitude, longitude float64, distance uint64) (nwLat, neLon, seLat, seLon float64) {
 	// Make a crude estimation of the coordinate offset based on the distance
 	d := float64(distance)
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -18,13 +19,153 @@ type Temperature struct {
 	Timestamp2 time.Time `gorm:"default:'1970-01-01T12:00:00Z'"`
 }
 
+const (
+	//QualityRaw is the quality control status of readings that have not been checked
+	QualityRaw string = "raw"
+	//QualityGood is the quality control status of readings that passed all checks
+	QualityGood string = "good"
+	//QualitySuspect is the quality control status of readings that should be treated with care
+	QualitySuspect string = "suspect"
+	//QualityBad is the quality control status of readings that are known to be wrong
+	QualityBad string = "bad"
+	//QualityManuallyCorrected is the quality control status of readings that have been corrected by hand
+	QualityManuallyCorrected string = "manually-corrected"
+)
+
+//IsValidQuality returns true if the provided string is a known quality control status
+func IsValidQuality(quality string) bool {
+	switch quality {
+	case QualityRaw, QualityGood, QualitySuspect, QualityBad, QualityManuallyCorrected:
+		return true
+	}
+
+	return false
+}
+
+const (
+	//MediumAir is the medium of readings that describe the temperature of the air
+	MediumAir string = "air"
+	//MediumWater is the medium of readings that describe the temperature of water
+	MediumWater string = "water"
+	//MediumSoil is the medium of readings that describe the temperature of the ground
+	MediumSoil string = "soil"
+	//MediumSurface is the medium of readings that describe the temperature of a road surface
+	MediumSurface string = "surface"
+	//MediumIndoor is the medium of readings that describe the temperature of the air indoors
+	MediumIndoor string = "indoor"
+)
+
+//Mediums returns all known mediums
+func Mediums() []string {
+	return []string{MediumAir, MediumWater, MediumSoil, MediumSurface, MediumIndoor}
+}
+
+//IsValidMedium returns true if the provided string is a known medium
+func IsValidMedium(medium string) bool {
+	for _, m := range Mediums() {
+		if m == medium {
+			return true
+		}
+	}
+
+	return false
+}
+
+//DefaultTenant is the tenant of readings that have not been sent on behalf of a specific tenant
+const DefaultTenant string = "default"
+
 //TemperatureV2 defines the structure for our new temperatures table
 type TemperatureV2 struct {
 	gorm.Model
-	Latitude  float64
-	Longitude float64
-	Device    string `gorm:"index;index:device_at_time,unique"`
-	Temp      float32
-	Water     bool
-	Timestamp time.Time `gorm:"index:device_at_time,unique"`
+	Tenant        string `gorm:"index;default:'default';index:tenant_device_at_depth_and_time,unique"`
+	Latitude      float64
+	Longitude     float64
+	Device        string    `gorm:"index;index:tenant_device_at_depth_and_time,unique"`
+	Temp          float64   // the calibrated temperature, or the raw temperature if there is no calibration
+	RawTemp       float64   // the temperature as it was reported by the device
+	Resolution    float64   `gorm:"default:0"` // the resolution of the sensor in degrees, 0 if unknown
+	CalibrationID uint      `gorm:"default:0"`
+	Depth         float64   `gorm:"default:0;index:tenant_device_at_depth_and_time,unique"` // metres below the surface
+	Medium        string    `gorm:"index;default:'air'"`
+	Timestamp     time.Time `gorm:"index:tenant_device_at_depth_and_time,unique"`
+	Quality       string    `gorm:"default:'raw'"`
+	QualityReason string
+}
+
+//Calibration defines the structure for our calibrations table. A calibration corrects the readings
+//of a device as raw*Gain + Offset from ValidFrom until ValidTo, or indefinitely if ValidTo is nil.
+type Calibration struct {
+	gorm.Model
+	Tenant    string `gorm:"default:'default';index:tenant_device_calibration"`
+	Device    string `gorm:"index:tenant_device_calibration"`
+	Offset    float64
+	Gain      float64 `gorm:"default:1"`
+	ValidFrom time.Time
+	ValidTo   *time.Time
+}
+
+//Correct returns the calibrated value of a raw temperature
+func (c *Calibration) Correct(raw float64) float64 {
+	return raw*c.Gain + c.Offset
+}
+
+const (
+	//VisibilityPublic devices have readings that anyone that may query the APIs may see
+	VisibilityPublic string = "public"
+	//VisibilityRestricted devices have readings that only authenticated callers may see
+	VisibilityRestricted string = "restricted"
+)
+
+//IsValidVisibility returns true if the provided string is a known device visibility
+func IsValidVisibility(visibility string) bool {
+	return visibility == VisibilityPublic || visibility == VisibilityRestricted
+}
+
+//DevicePolicy defines the structure for our device policies table. Devices that do not have
+//a policy are public.
+type DevicePolicy struct {
+	gorm.Model
+	Tenant     string `gorm:"default:'default';index:tenant_device_policy,unique"`
+	Device     string `gorm:"index:tenant_device_policy,unique"`
+	Visibility string `gorm:"default:'public'"`
+}
+
+const (
+	//AlertConditionAbove fires an alert when the temperature rises above the threshold
+	AlertConditionAbove string = "above"
+	//AlertConditionBelow fires an alert when the temperature drops below the threshold
+	AlertConditionBelow string = "below"
+)
+
+//AlertRule defines the structure for our alert rules table
+type AlertRule struct {
+	gorm.Model
+	Tenant      string `gorm:"index;default:'default'"`
+	Name        string
+	Devices     string // comma separated list of devices, or empty for all devices
+	Medium      string `gorm:"default:'air'"`
+	Condition   string
+	Threshold   float64
+	Hysteresis  float64
+	MinDuration time.Duration
+	Enabled     bool
+}
+
+//AppliesTo returns true if the rule should be evaluated for the provided device and medium
+func (r *AlertRule) AppliesTo(device string, medium string) bool {
+	if !r.Enabled || r.Medium != medium {
+		return false
+	}
+
+	if r.Devices == "" {
+		return true
+	}
+
+	for _, d := range strings.Split(r.Devices, ",") {
+		if strings.TrimSpace(d) == device {
+			return true
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,64 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+//Policy controls how many times, and how often, an operation is attempted before we give up
+type Policy struct {
+	MaxAttempts    int           `yaml:"maxAttempts"`
+	InitialBackoff time.Duration `yaml:"initialBackoff"`
+	MaxBackoff     time.Duration `yaml:"maxBackoff"`
+}
+
+//DefaultPolicy returns a policy that makes ten attempts with exponential backoff, which gives
+//a dependency that is starting up at the same time as us roughly a minute to become available
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:    10,
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     10 * time.Second,
+	}
+}
+
+func (p Policy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff << uint(attempt-1)
+	if d > p.MaxBackoff || d <= 0 {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+//Do calls fn until it succeeds, the policy runs out of attempts or ctx is done, and returns the
+//last error from fn if it never succeeded
+func (p Policy) Do(ctx context.Context, log zerolog.Logger, what string, fn func() error) error {
+	var err error
+
+	for attempt := 1; attempt <= p.MaxAttempts; attempt++ {
+		err = fn()
+		if err == nil {
+			return nil
+		}
+
+		if attempt == p.MaxAttempts {
+			break
+		}
+
+		backoff := p.backoff(attempt)
+		log.Warn().Err(err).Int("attempt", attempt).Int("maxAttempts", p.MaxAttempts).Msgf("failed to %s, retrying in %s", what, backoff)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("gave up trying to %s: %w", what, ctx.Err())
+		}
+	}
+
+	return fmt.Errorf("failed to %s after %d attempts: %w", what, p.MaxAttempts, err)
+}
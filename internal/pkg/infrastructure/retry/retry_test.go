@@ -0,0 +1,70 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/rs/zerolog"
+)
+
+func TestThatDoRetriesUntilTheOperationSucceeds(t *testing.T) {
+	is := is.New(t)
+
+	p := Policy{MaxAttempts: 5, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	calls := 0
+
+	err := p.Do(context.Background(), zerolog.Nop(), "connect", func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	is.NoErr(err)      // should succeed eventually
+	is.Equal(calls, 3) // should stop calling once the operation succeeds
+}
+
+func TestThatDoGivesUpAfterMaxAttempts(t *testing.T) {
+	is := is.New(t)
+
+	p := Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	refused := errors.New("connection refused")
+	calls := 0
+
+	err := p.Do(context.Background(), zerolog.Nop(), "connect", func() error {
+		calls++
+		return refused
+	})
+
+	is.Equal(calls, 3)               // should make exactly MaxAttempts attempts
+	is.True(errors.Is(err, refused)) // should return the last error
+}
+
+func TestThatDoStopsWhenTheContextIsDone(t *testing.T) {
+	is := is.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := Policy{MaxAttempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+
+	err := p.Do(ctx, zerolog.Nop(), "connect", func() error {
+		cancel()
+		return errors.New("connection refused")
+	})
+
+	is.True(errors.Is(err, context.Canceled)) // should not wait for the backoff
+}
+
+func TestThatBackoffGrowsExponentiallyUpToTheMax(t *testing.T) {
+	is := is.New(t)
+
+	p := Policy{MaxAttempts: 10, InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	is.Equal(p.backoff(1), 1*time.Second)
+	is.Equal(p.backoff(2), 2*time.Second)
+	is.Equal(p.backoff(3), 4*time.Second)
+	is.Equal(p.backoff(4), 5*time.Second)
+}
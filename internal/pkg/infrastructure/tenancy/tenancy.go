@@ -0,0 +1,81 @@
+package tenancy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/auth"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+)
+
+//HeaderName is the header that NGSI-LD clients use to select a tenant
+const HeaderName string = "NGSILD-Tenant"
+
+const maxTenantLength int = 64
+
+//Validate returns an error unless name is a valid tenant name. Tenant names are limited to
+//letters, digits, dashes, underscores and dots so that they are safe to log and to use as labels.
+func Validate(name string) error {
+	if name == "" {
+		return fmt.Errorf("tenant name must not be empty")
+	}
+
+	if len(name) > maxTenantLength {
+		return fmt.Errorf("tenant name must not be longer than %d characters", maxTenantLength)
+	}
+
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return fmt.Errorf("tenant name %q contains the invalid character %q", name, c)
+		}
+	}
+
+	return nil
+}
+
+type tenantContextKey struct{}
+
+//WithTenant returns a copy of ctx that carries the provided tenant
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+//FromContext returns the tenant that ctx belongs to, or the default tenant if there is none
+func FromContext(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantContextKey{}).(string); ok && tenant != "" {
+		return tenant
+	}
+
+	return models.DefaultTenant
+}
+
+//Middleware resolves the tenant of every request from the NGSILD-Tenant header or, if there is
+//no such header, from the tenant of the authenticated caller. Callers that belong to a tenant
+//are not allowed to ask for another one, unless they are administrators.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.Header.Get(HeaderName)
+		principal := auth.PrincipalFromContext(r.Context())
+
+		if principal != nil && principal.Tenant != "" {
+			if tenant == "" {
+				tenant = principal.Tenant
+			} else if tenant != principal.Tenant && !principal.IsAdmin() {
+				http.Error(w, "access to tenant "+tenant+" is not allowed", http.StatusForbidden)
+				return
+			}
+		}
+
+		if tenant == "" {
+			tenant = models.DefaultTenant
+		}
+
+		if err := Validate(tenant); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithTenant(r.Context(), tenant)))
+	})
+}
@@ -0,0 +1,76 @@
+package tenancy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matryer/is"
+
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/auth"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/config"
+	"github.com/diwise/api-temperature/internal/pkg/infrastructure/repositories/models"
+)
+
+func serve(cfg *config.Auth, token, header string) (*httptest.ResponseRecorder, string) {
+	var tenant string
+
+	var handler http.Handler = Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant = FromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	if cfg != nil {
+		handler = auth.Middleware(*cfg, auth.NewStaticKeyAuthenticator(*cfg), auth.DefaultRules(*cfg))(handler)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/ngsi-ld/v1/entities", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if header != "" {
+		req.Header.Set(HeaderName, header)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	return w, tenant
+}
+
+func TestThatTheTenantIsReadFromTheHeader(t *testing.T) {
+	is := is.New(t)
+
+	w, tenant := serve(nil, "", "")
+	is.Equal(w.Code, http.StatusOK)
+	is.Equal(tenant, models.DefaultTenant) // requests without a tenant should use the default tenant
+
+	_, tenant = serve(nil, "", "sundsvall")
+	is.Equal(tenant, "sundsvall")
+
+	w, _ = serve(nil, "", "not a tenant")
+	is.Equal(w.Code, http.StatusBadRequest) // invalid tenant names should be rejected
+}
+
+func TestThatCallersAreConfinedToTheirTenant(t *testing.T) {
+	is := is.New(t)
+
+	cfg := config.Default().Auth
+	cfg.Enabled = true
+	cfg.Mode = config.AuthModeStatic
+	cfg.StaticKeys = []config.StaticKey{
+		{Key: "tenant-reader", Subject: "reader", Tenant: "sundsvall", Scopes: []string{cfg.ReadScope}},
+		{Key: "admin", Subject: "admin", Tenant: "sundsvall", Scopes: []string{cfg.ReadScope, cfg.AdminScope}},
+	}
+
+	w, tenant := serve(&cfg, "tenant-reader", "")
+	is.Equal(w.Code, http.StatusOK)
+	is.Equal(tenant, "sundsvall") // the tenant of the caller should be used when there is no header
+
+	w, _ = serve(&cfg, "tenant-reader", "timra")
+	is.Equal(w.Code, http.StatusForbidden) // callers should not be allowed to read other tenants
+
+	w, tenant = serve(&cfg, "admin", "timra")
+	is.Equal(w.Code, http.StatusOK) // unless they are administrators
+	is.Equal(tenant, "timra")
+}
@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/matryer/is"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestThatTraceContextIsExtractedFromAMQPHeaders(t *testing.T) {
+	is := is.New(t)
+	setupTestTracing(t)
+
+	ctx := ExtractFromAMQP(context.Background(), amqp.Table{"traceparent": traceparent})
+
+	sc := trace.SpanContextFromContext(ctx)
+	is.True(sc.IsRemote())                                              // should have extracted a remote span context
+	is.Equal(sc.TraceID().String(), "4bf92f3577b34da6a3ce929d0e0e4736") // should continue the trace of the publisher
+}
+
+func TestThatHTTPSpansAreNamedAfterTheRouteAndContinueTheCallersTrace(t *testing.T) {
+	is := is.New(t)
+	recorder := setupTestTracing(t)
+
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/ngsi-ld/v1/entities/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/ngsi-ld/v1/entities/urn:ngsi-ld:Device:test", nil)
+	req.Header.Set("traceparent", traceparent)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	is.Equal(len(spans), 1)                                                            // should have recorded one span
+	is.Equal(spans[0].Name(), "GET /ngsi-ld/v1/entities/{id}")                         // should be named after the route
+	is.Equal(spans[0].Parent().TraceID().String(), "4bf92f3577b34da6a3ce929d0e0e4736") // should continue the callers trace
+}
+
+func setupTestTracing(t *testing.T) *tracetest.SpanRecorder {
+	t.Setenv("OTEL_TRACES_EXPORTER", "none")
+	if _, err := Init(context.Background(), "test", log.Logger); err != nil {
+		t.Fatalf("failed to initialize tracing: %s", err.Error())
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	return recorder
+}
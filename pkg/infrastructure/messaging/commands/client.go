@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/rs/zerolog"
+
+	"github.com/diwise/messaging-golang/pkg/messaging"
+)
+
+const (
+	//ServiceName is the routing key that store temperature commands should be sent to
+	ServiceName = "api-temperature"
+	//CommandExchange is the exchange that commands, and the replies to them, are routed through
+	CommandExchange = "iot-cmd-exchange-direct"
+)
+
+//Messenger is the part of messaging.Context that the Client depends on
+type Messenger interface {
+	SendCommandTo(command messaging.CommandMessage, key string) error
+}
+
+//ReplyChannel is the part of an amqp.Channel that the Client uses to receive the results of its commands
+type ReplyChannel interface {
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+}
+
+//Client sends store temperature commands to api-temperature and awaits their results.
+//Results are sent to a reply queue that is exclusive to the client, so that they are
+//never consumed by another replica of the producing service.
+type Client struct {
+	messenger Messenger
+	replyTo   string
+
+	mu      sync.Mutex
+	pending map[string]chan StoreTemperatureUpdateResult
+}
+
+//NewClient creates a new Client that sends its commands with the messenger. The results of the
+//commands are received on an exclusive queue that is declared on the channel and bound to the
+//command exchange with its own name as routing key. The queue is deleted with the channel.
+func NewClient(messenger Messenger, channel ReplyChannel, log zerolog.Logger) (*Client, error) {
+	queue, err := channel.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to declare reply queue: %s", err.Error())
+	}
+
+	err = channel.QueueBind(queue.Name, queue.Name, CommandExchange, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind reply queue %s: %s", queue.Name, err.Error())
+	}
+
+	replies, err := channel.Consume(queue.Name, "", true, true, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume replies from %s: %s", queue.Name, err.Error())
+	}
+
+	c := &Client{
+		messenger: messenger,
+		replyTo:   queue.Name,
+		pending:   map[string]chan StoreTemperatureUpdateResult{},
+	}
+
+	log = log.With().Str("queue", queue.Name).Logger()
+
+	go func() {
+		for reply := range replies {
+			if reply.ContentType != StoreTemperatureUpdateResultType {
+				log.Warn().Str("contentType", reply.ContentType).Msg("ignored reply with unexpected content type")
+				continue
+			}
+
+			if err := c.handleResult(reply.Body, log); err != nil {
+				log.Error().Err(err).Msg("failed to handle reply")
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+//StoreTemperature sends a store temperature command and waits for its result, or for the context to be done
+func (c *Client) StoreTemperature(ctx context.Context, cmd *StoreTemperatureUpdate) (*StoreTemperatureUpdateResult, error) {
+	return c.send(ctx, cmd, &cmd.Reply)
+}
+
+//StoreWaterTemperature sends a store water temperature command and waits for its result, or for the context to be done
+func (c *Client) StoreWaterTemperature(ctx context.Context, cmd *StoreWaterTemperatureUpdate) (*StoreTemperatureUpdateResult, error) {
+	return c.send(ctx, cmd, &cmd.Reply)
+}
+
+//StoreSoilTemperature sends a store soil temperature command and waits for its result, or for the context to be done
+func (c *Client) StoreSoilTemperature(ctx context.Context, cmd *StoreSoilTemperatureUpdate) (*StoreTemperatureUpdateResult, error) {
+	return c.send(ctx, cmd, &cmd.Reply)
+}
+
+//StoreSurfaceTemperature sends a store road surface temperature command and waits for its result, or for the context to be done
+func (c *Client) StoreSurfaceTemperature(ctx context.Context, cmd *StoreSurfaceTemperatureUpdate) (*StoreTemperatureUpdateResult, error) {
+	return c.send(ctx, cmd, &cmd.Reply)
+}
+
+//StoreIndoorTemperature sends a store indoor temperature command and waits for its result, or for the context to be done
+func (c *Client) StoreIndoorTemperature(ctx context.Context, cmd *StoreIndoorTemperatureUpdate) (*StoreTemperatureUpdateResult, error) {
+	return c.send(ctx, cmd, &cmd.Reply)
+}
+
+func (c *Client) send(ctx context.Context, cmd messaging.CommandMessage, reply *Reply) (*StoreTemperatureUpdateResult, error) {
+	reply.ReplyTo = c.replyTo
+	reply.CorrelationID = uuid.New().String()
+
+	result := make(chan StoreTemperatureUpdateResult, 1)
+
+	c.mu.Lock()
+	c.pending[reply.CorrelationID] = result
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, reply.CorrelationID)
+		c.mu.Unlock()
+	}()
+
+	if err := c.messenger.SendCommandTo(cmd, ServiceName); err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-result:
+		return &r, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Client) handleResult(body []byte, log zerolog.Logger) error {
+	result := StoreTemperatureUpdateResult{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("failed to unmarshal result: %s", err.Error())
+	}
+
+	c.mu.Lock()
+	pending, ok := c.pending[result.CorrelationID]
+	c.mu.Unlock()
+
+	if !ok {
+		log.Warn().Str("correlationId", result.CorrelationID).Msg("received result for an unknown or expired command")
+		return nil
+	}
+
+	select {
+	case pending <- result:
+	default:
+		log.Warn().Str("correlationId", result.CorrelationID).Msg("ignored repeated result for a command")
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/diwise/messaging-golang/pkg/messaging"
+	"github.com/matryer/is"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/rs/zerolog"
+)
+
+type channelStub struct {
+	replies chan amqp.Delivery
+	boundTo string
+}
+
+func (ch *channelStub) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	return amqp.Queue{Name: "amq.gen-replica-1"}, nil
+}
+
+func (ch *channelStub) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	ch.boundTo = exchange + "/" + key
+	return nil
+}
+
+func (ch *channelStub) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	return ch.replies, nil
+}
+
+type messengerFunc func(command messaging.CommandMessage, key string) error
+
+func (f messengerFunc) SendCommandTo(command messaging.CommandMessage, key string) error {
+	return f(command, key)
+}
+
+func TestThatResultsAreReceivedOnTheExclusiveReplyQueueOfTheClient(t *testing.T) {
+	is := is.New(t)
+
+	channel := &channelStub{replies: make(chan amqp.Delivery, 2)}
+
+	messenger := messengerFunc(func(command messaging.CommandMessage, key string) error {
+		cmd := command.(*StoreTemperatureUpdate)
+
+		is.Equal(key, ServiceName)                 // the command should be sent to api-temperature
+		is.Equal(cmd.ReplyTo, "amq.gen-replica-1") // and ask for a reply to the queue of the client
+
+		for _, correlationID := range []string{"someone-else", cmd.CorrelationID} {
+			body, _ := json.Marshal(StoreTemperatureUpdateResult{CorrelationID: correlationID, Status: correlationID})
+			channel.replies <- amqp.Delivery{ContentType: StoreTemperatureUpdateResultType, Body: body}
+		}
+
+		return nil
+	})
+
+	client, err := NewClient(messenger, channel, zerolog.Nop())
+	is.NoErr(err)
+	is.Equal(channel.boundTo, CommandExchange+"/amq.gen-replica-1") // the reply queue should be routed to by its name
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cmd := &StoreTemperatureUpdate{}
+	result, err := client.StoreTemperature(ctx, cmd)
+	is.NoErr(err)
+	is.Equal(result.Status, cmd.CorrelationID) // the result should be matched to the command by its correlation id
+}
@@ -1,7 +1,10 @@
 package commands
 
 import (
+	"github.com/diwise/messaging-golang/pkg/messaging"
 	"github.com/diwise/messaging-golang/pkg/messaging/telemetry"
+
+	diwisetelemetry "github.com/diwise/api-temperature/pkg/infrastructure/messaging/telemetry"
 )
 
 const (
@@ -9,11 +12,50 @@ const (
 	StoreTemperatureUpdateType = "application/vnd-diwise-storetemperatureupdate+json"
 	//StoreWaterTemperatureUpdateType is the content type for ...
 	StoreWaterTemperatureUpdateType = "application/vnd-diwise-storewatertemperatureupdate+json"
+	//StoreSoilTemperatureUpdateType is the content type for a StoreSoilTemperatureUpdate command
+	StoreSoilTemperatureUpdateType = "application/vnd-diwise-storesoiltemperatureupdate+json"
+	//StoreSurfaceTemperatureUpdateType is the content type for a StoreSurfaceTemperatureUpdate command
+	StoreSurfaceTemperatureUpdateType = "application/vnd-diwise-storesurfacetemperatureupdate+json"
+	//StoreIndoorTemperatureUpdateType is the content type for a StoreIndoorTemperatureUpdate command
+	StoreIndoorTemperatureUpdateType = "application/vnd-diwise-storeindoortemperatureupdate+json"
+	//StoreTemperatureUpdateResultType is the content type for replies to store temperature commands
+	StoreTemperatureUpdateResultType = "application/vnd-diwise-storetemperatureupdateresult+json"
+	//ImportTemperatureSeriesType is the content type for a batch of historical temperatures
+	ImportTemperatureSeriesType = "application/vnd-diwise-importtemperatureseries+json"
+	//ImportTemperatureSeriesResultType is the content type for replies to import temperature series commands
+	ImportTemperatureSeriesResultType = "application/vnd-diwise-importtemperatureseriesresult+json"
 )
 
+//Reply carries the information needed to reply to a command. A reply is only sent
+//when both a routing key to reply to and a correlation id are provided.
+type Reply struct {
+	ReplyTo       string `json:"replyTo,omitempty"`
+	CorrelationID string `json:"correlationId,omitempty"`
+}
+
+//WantsReply returns true if the sender of a command has asked for a reply
+func (r Reply) WantsReply() bool {
+	return r.ReplyTo != "" && r.CorrelationID != ""
+}
+
+//TenantScope selects the tenant that a command stores its temperatures for. Temperatures
+//in commands without a tenant are stored for the default tenant.
+type TenantScope struct {
+	Tenant string `json:"tenant,omitempty"`
+}
+
+//UnitScope selects the unit that the temperatures of a command are reported in, as one of the
+//UN/CEFACT common codes CEL, FAH or KEL. Temperatures without a unit are in degrees Celsius.
+type UnitScope struct {
+	Unit string `json:"unit,omitempty"`
+}
+
 //StoreTemperatureUpdate is a command that takes info about a temperature update and enqueues it for persistence
 type StoreTemperatureUpdate struct {
 	telemetry.Temperature
+	UnitScope
+	TenantScope
+	Reply
 }
 
 //ContentType returns the content type that this event will be sent as
@@ -21,12 +63,138 @@ func (stu *StoreTemperatureUpdate) ContentType() string {
 	return StoreTemperatureUpdateType
 }
 
-//StoreTemperatureUpdate is a command that takes info about a temperature update and enqueues it for persistence
+//StoreWaterTemperatureUpdate is a command that takes info about a water temperature update and enqueues it for persistence
 type StoreWaterTemperatureUpdate struct {
 	telemetry.WaterTemperature
+	Depth float64 `json:"depth,omitempty"` // metres below the surface
+	UnitScope
+	TenantScope
+	Reply
 }
 
 //ContentType returns the content type that this event will be sent as
 func (stu *StoreWaterTemperatureUpdate) ContentType() string {
 	return StoreWaterTemperatureUpdateType
 }
+
+//StoreSoilTemperatureUpdate is a command that takes info about a soil temperature update and enqueues it for persistence
+type StoreSoilTemperatureUpdate struct {
+	diwisetelemetry.SoilTemperature
+	UnitScope
+	TenantScope
+	Reply
+}
+
+//ContentType returns the content type that this event will be sent as
+func (stu *StoreSoilTemperatureUpdate) ContentType() string {
+	return StoreSoilTemperatureUpdateType
+}
+
+//StoreSurfaceTemperatureUpdate is a command that takes info about a road surface temperature update and enqueues it for persistence
+type StoreSurfaceTemperatureUpdate struct {
+	diwisetelemetry.SurfaceTemperature
+	UnitScope
+	TenantScope
+	Reply
+}
+
+//ContentType returns the content type that this event will be sent as
+func (stu *StoreSurfaceTemperatureUpdate) ContentType() string {
+	return StoreSurfaceTemperatureUpdateType
+}
+
+//StoreIndoorTemperatureUpdate is a command that takes info about an indoor temperature update and enqueues it for persistence
+type StoreIndoorTemperatureUpdate struct {
+	diwisetelemetry.IndoorTemperature
+	UnitScope
+	TenantScope
+	Reply
+}
+
+//ContentType returns the content type that this event will be sent as
+func (stu *StoreIndoorTemperatureUpdate) ContentType() string {
+	return StoreIndoorTemperatureUpdateType
+}
+
+const (
+	//StatusStored means that the temperature was persisted
+	StatusStored = "stored"
+	//StatusDuplicate means that the temperature had already been persisted
+	StatusDuplicate = "duplicate"
+	//StatusRejected means that the temperature was invalid and will never be persisted
+	StatusRejected = "rejected"
+	//StatusFailed means that the temperature could not be persisted, but that it may be sent again
+	StatusFailed = "failed"
+)
+
+//StoreTemperatureUpdateResult is sent as a reply to store temperature commands that ask for one
+type StoreTemperatureUpdateResult struct {
+	CorrelationID string `json:"correlationId"`
+	Status        string `json:"status"`
+	ID            uint   `json:"id,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+//ContentType returns the content type that this response will be sent as
+func (r *StoreTemperatureUpdateResult) ContentType() string {
+	return StoreTemperatureUpdateResultType
+}
+
+const (
+	//KindAir is the kind of series readings that describe the temperature of the air
+	KindAir = "air"
+	//KindWater is the kind of series readings that describe the temperature of water
+	KindWater = "water"
+	//KindSoil is the kind of series readings that describe the temperature of the ground
+	KindSoil = "soil"
+	//KindSurface is the kind of series readings that describe the temperature of a road surface
+	KindSurface = "surface"
+	//KindIndoor is the kind of series readings that describe the temperature of the air indoors
+	KindIndoor = "indoor"
+)
+
+//SeriesReading is a single reading in an ImportTemperatureSeries command
+type SeriesReading struct {
+	messaging.IoTHubMessage
+	Kind  string  `json:"kind"`
+	Temp  float64 `json:"temp"`
+	Depth float64 `json:"depth,omitempty"` // metres below the surface
+	UnitScope
+}
+
+//ImportTemperatureSeries is a command that carries a batch of historical temperatures, for one
+//or more devices, that should be persisted in a single transaction
+type ImportTemperatureSeries struct {
+	Readings []SeriesReading `json:"readings"`
+	TenantScope
+	Reply
+}
+
+//ContentType returns the content type that this command will be sent as
+func (cmd *ImportTemperatureSeries) ContentType() string {
+	return ImportTemperatureSeriesType
+}
+
+//SeriesItemResult is the outcome of a single reading that could not be stored
+type SeriesItemResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+//ImportTemperatureSeriesResult is sent as a reply to import commands that ask for one. Only the
+//readings that were not stored are listed in Items, referenced by their index in the command.
+type ImportTemperatureSeriesResult struct {
+	CorrelationID string             `json:"correlationId"`
+	Status        string             `json:"status"`
+	Reason        string             `json:"reason,omitempty"`
+	Stored        int                `json:"stored"`
+	Duplicates    int                `json:"duplicates"`
+	Rejected      int                `json:"rejected"`
+	Items         []SeriesItemResult `json:"items,omitempty"`
+}
+
+//ContentType returns the content type that this response will be sent as
+func (r *ImportTemperatureSeriesResult) ContentType() string {
+	return ImportTemperatureSeriesResultType
+}
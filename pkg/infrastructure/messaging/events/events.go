@@ -0,0 +1,141 @@
+package events
+
+import (
+	"time"
+)
+
+const (
+	//TemperatureAnomalyDetectedType is the content type for a TemperatureAnomalyDetected event
+	TemperatureAnomalyDetectedType = "application/vnd-diwise-temperatureanomalydetected+json"
+	//TemperatureAnomalyDetectedTopic is the topic that TemperatureAnomalyDetected events are published on
+	TemperatureAnomalyDetectedTopic = "temperature.anomalydetected"
+)
+
+//TemperatureAnomalyDetected is published when a device starts to report readings that
+//look like a sensor malfunction, such as a flatline, a spike or a sudden offset
+type TemperatureAnomalyDetected struct {
+	Tenant      string    `json:"tenant,omitempty"`
+	Device      string    `json:"device"`
+	Depth       float64   `json:"depth,omitempty"`
+	Medium      string    `json:"medium"`
+	Anomaly     string    `json:"anomaly"`
+	Description string    `json:"description"`
+	Temp        float64   `json:"temp"`
+	Expected    float64   `json:"expected"`
+	Since       time.Time `json:"since"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+//ContentType returns the content type that this event will be sent as
+func (e *TemperatureAnomalyDetected) ContentType() string {
+	return TemperatureAnomalyDetectedType
+}
+
+//TopicName returns the name of the topic that this event will be published on
+func (e *TemperatureAnomalyDetected) TopicName() string {
+	return TemperatureAnomalyDetectedTopic
+}
+
+const (
+	//TemperatureAlertFiredType is the content type for a TemperatureAlertFired event
+	TemperatureAlertFiredType = "application/vnd-diwise-temperaturealertfired+json"
+	//TemperatureAlertFiredTopic is the topic that TemperatureAlertFired events are published on
+	TemperatureAlertFiredTopic = "temperature.alert.fired"
+	//TemperatureAlertClearedType is the content type for a TemperatureAlertCleared event
+	TemperatureAlertClearedType = "application/vnd-diwise-temperaturealertcleared+json"
+	//TemperatureAlertClearedTopic is the topic that TemperatureAlertCleared events are published on
+	TemperatureAlertClearedTopic = "temperature.alert.cleared"
+)
+
+//TemperatureAlert contains the information that is common to all alert events.
+//
+//The state of the alerts is only kept in memory by each instance of the service, per rule,
+//tenant, device and depth. After a restart, an alert that was firing will be fired again if
+//the temperature still passes the threshold of the rule, and an alert that was firing will
+//never be cleared if the temperature returned past the threshold while the service was down.
+//Instances of the service do not share this state, each of them evaluates the rules against the
+//readings that it ingests itself.
+type TemperatureAlert struct {
+	RuleID    uint      `json:"ruleId"`
+	RuleName  string    `json:"ruleName"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Device    string    `json:"device"`
+	Depth     float64   `json:"depth,omitempty"`
+	Medium    string    `json:"medium"`
+	Condition string    `json:"condition"`
+	Threshold float64   `json:"threshold"`
+	Temp      float64   `json:"temp"`
+	Since     time.Time `json:"since"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+//TemperatureAlertFired is published when a temperature has passed the threshold of an alert
+//rule for at least the minimum duration of the rule
+type TemperatureAlertFired struct {
+	TemperatureAlert
+}
+
+//ContentType returns the content type that this event will be sent as
+func (e *TemperatureAlertFired) ContentType() string {
+	return TemperatureAlertFiredType
+}
+
+//TopicName returns the name of the topic that this event will be published on
+func (e *TemperatureAlertFired) TopicName() string {
+	return TemperatureAlertFiredTopic
+}
+
+//TemperatureAlertCleared is published when the temperature of a fired alert has returned
+//past the threshold of the rule, including its hysteresis
+type TemperatureAlertCleared struct {
+	TemperatureAlert
+}
+
+//ContentType returns the content type that this event will be sent as
+func (e *TemperatureAlertCleared) ContentType() string {
+	return TemperatureAlertClearedType
+}
+
+//TopicName returns the name of the topic that this event will be published on
+func (e *TemperatureAlertCleared) TopicName() string {
+	return TemperatureAlertClearedTopic
+}
+
+const (
+	//TemperatureStoredSchemaVersion is the version of the TemperatureStored schema. It is bumped
+	//whenever a change is made that is not backwards compatible with existing consumers.
+	TemperatureStoredSchemaVersion = 1
+	//TemperatureStoredType is the content type for a TemperatureStored event
+	TemperatureStoredType = "application/vnd-diwise-temperaturestored.v1+json"
+	//TemperatureStoredTopic is the topic that TemperatureStored events are published on
+	TemperatureStoredTopic = "temperature.stored"
+)
+
+//TemperatureStored is published when a temperature reading has been persisted, or when a reading
+//was found to be a duplicate of a reading that has already been persisted. Readings are stored at
+//full precision, while the temperature of the event is rounded according to the precision.events
+//configuration, i.e. to a fixed number of decimals or to the resolution of the sensor.
+type TemperatureStored struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	ID            uint      `json:"id"`
+	Tenant        string    `json:"tenant,omitempty"`
+	Device        string    `json:"device"`
+	Kind          string    `json:"kind"`
+	Latitude      float64   `json:"latitude"`
+	Longitude     float64   `json:"longitude"`
+	Temp          float64   `json:"temp"`
+	Depth         float64   `json:"depth,omitempty"`
+	Quality       string    `json:"quality"`
+	Duplicate     bool      `json:"duplicate"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+//ContentType returns the content type that this event will be sent as
+func (e *TemperatureStored) ContentType() string {
+	return TemperatureStoredType
+}
+
+//TopicName returns the name of the topic that this event will be published on
+func (e *TemperatureStored) TopicName() string {
+	return TemperatureStoredTopic
+}
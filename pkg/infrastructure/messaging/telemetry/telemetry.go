@@ -0,0 +1,54 @@
+package telemetry
+
+import (
+	"github.com/diwise/messaging-golang/pkg/messaging"
+)
+
+//SoilTemperature is a telemetry type IoTHubMessage for temperatures measured in the ground
+type SoilTemperature struct {
+	messaging.IoTHubMessage
+	Temp  float64 `json:"temp"`
+	Depth float64 `json:"depth,omitempty"` // metres below the surface
+}
+
+//ContentType returns the ContentType for a SoilTemperature telemetry message
+func (msg *SoilTemperature) ContentType() string {
+	return "application/json"
+}
+
+//TopicName returns the name of the topic that a SoilTemperature telemetry message should be posted to
+func (msg *SoilTemperature) TopicName() string {
+	return "telemetry.soiltemperature"
+}
+
+//SurfaceTemperature is a telemetry type IoTHubMessage for temperatures measured on road surfaces
+type SurfaceTemperature struct {
+	messaging.IoTHubMessage
+	Temp float64 `json:"temp"`
+}
+
+//ContentType returns the ContentType for a SurfaceTemperature telemetry message
+func (msg *SurfaceTemperature) ContentType() string {
+	return "application/json"
+}
+
+//TopicName returns the name of the topic that a SurfaceTemperature telemetry message should be posted to
+func (msg *SurfaceTemperature) TopicName() string {
+	return "telemetry.surfacetemperature"
+}
+
+//IndoorTemperature is a telemetry type IoTHubMessage for temperatures measured indoors
+type IndoorTemperature struct {
+	messaging.IoTHubMessage
+	Temp float64 `json:"temp"`
+}
+
+//ContentType returns the ContentType for an IndoorTemperature telemetry message
+func (msg *IndoorTemperature) ContentType() string {
+	return "application/json"
+}
+
+//TopicName returns the name of the topic that an IndoorTemperature telemetry message should be posted to
+func (msg *IndoorTemperature) TopicName() string {
+	return "telemetry.indoortemperature"
+}